@@ -0,0 +1,148 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+
+	"gortle/internal/token"
+)
+
+type NodeType int
+
+const (
+	NODE_InstructionList NodeType = iota
+	NODE_Call
+	NODE_Infix
+	NODE_Negate
+	NODE_Number
+	NODE_Quoted
+	NODE_Word
+	NODE_Variable
+	NODE_List
+	NODE_Array
+	NODE_ProcDef
+)
+
+const DefaultOrigin = 1
+
+// ASTNode is a parsed Logo expression or instruction. Words inside
+// NODE_List and NODE_Array nodes are kept as data and are only parsed
+// as instructions when the list is run.
+type ASTNode struct {
+	Type     NodeType
+	Pos      token.Pos
+	Name     string
+	Text     string
+	Number   float64
+	Escaped  bool
+	Origin   int
+	Children []*ASTNode
+}
+
+func NewInstructionList(pos token.Pos, instrs []*ASTNode) *ASTNode {
+	return &ASTNode{Type: NODE_InstructionList, Pos: pos, Children: instrs}
+}
+
+func NewCall(pos token.Pos, name string, args []*ASTNode) *ASTNode {
+	return &ASTNode{Type: NODE_Call, Pos: pos, Name: name, Children: args}
+}
+
+func NewInfix(pos token.Pos, op string, left, right *ASTNode) *ASTNode {
+	return &ASTNode{Type: NODE_Infix, Pos: pos, Name: op, Children: []*ASTNode{left, right}}
+}
+
+func NewNegate(pos token.Pos, operand *ASTNode) *ASTNode {
+	return &ASTNode{Type: NODE_Negate, Pos: pos, Children: []*ASTNode{operand}}
+}
+
+func NewNumber(pos token.Pos, text string, value float64) *ASTNode {
+	return &ASTNode{Type: NODE_Number, Pos: pos, Text: text, Number: value}
+}
+
+func NewQuoted(pos token.Pos, text string, escaped bool) *ASTNode {
+	return &ASTNode{Type: NODE_Quoted, Pos: pos, Text: text, Escaped: escaped}
+}
+
+func NewWord(pos token.Pos, text string, escaped bool) *ASTNode {
+	return &ASTNode{Type: NODE_Word, Pos: pos, Text: text, Escaped: escaped}
+}
+
+func NewVariable(pos token.Pos, name string) *ASTNode {
+	return &ASTNode{Type: NODE_Variable, Pos: pos, Name: name}
+}
+
+func NewList(pos token.Pos, items []*ASTNode) *ASTNode {
+	return &ASTNode{Type: NODE_List, Pos: pos, Children: items}
+}
+
+func NewArray(pos token.Pos, items []*ASTNode, origin int) *ASTNode {
+	return &ASTNode{Type: NODE_Array, Pos: pos, Children: items, Origin: origin}
+}
+
+// NewProcDef builds a procedure definition. params holds the inputs in
+// the form TEXT reports them, and each body line is a NODE_List of
+// unparsed words.
+func NewProcDef(pos token.Pos, name string, params *ASTNode, lines []*ASTNode) *ASTNode {
+	children := append([]*ASTNode{params}, lines...)
+	return &ASTNode{Type: NODE_ProcDef, Pos: pos, Name: name, Children: children}
+}
+
+func (n *ASTNode) String() string {
+	var sb strings.Builder
+	n.write(&sb)
+	return sb.String()
+}
+
+func (n *ASTNode) write(sb *strings.Builder) {
+	switch n.Type {
+	case NODE_InstructionList:
+		writeAll(sb, n.Children)
+	case NODE_Call:
+		sb.WriteString("(" + n.Name)
+		for _, arg := range n.Children {
+			sb.WriteByte(' ')
+			arg.write(sb)
+		}
+		sb.WriteByte(')')
+	case NODE_Infix:
+		sb.WriteString("(" + n.Name + " ")
+		writeAll(sb, n.Children)
+		sb.WriteByte(')')
+	case NODE_Negate:
+		sb.WriteString("(- ")
+		n.Children[0].write(sb)
+		sb.WriteByte(')')
+	case NODE_Number:
+		sb.WriteString(n.Text)
+	case NODE_Quoted:
+		sb.WriteString("\"" + n.Text)
+	case NODE_Word:
+		sb.WriteString(n.Text)
+	case NODE_Variable:
+		sb.WriteString(":" + n.Name)
+	case NODE_List:
+		sb.WriteByte('[')
+		writeAll(sb, n.Children)
+		sb.WriteByte(']')
+	case NODE_Array:
+		sb.WriteByte('{')
+		writeAll(sb, n.Children)
+		sb.WriteByte('}')
+		if n.Origin != DefaultOrigin {
+			sb.WriteString("@" + strconv.Itoa(n.Origin))
+		}
+	case NODE_ProcDef:
+		sb.WriteString("(to " + n.Name + " ")
+		writeAll(sb, n.Children)
+		sb.WriteByte(')')
+	}
+}
+
+func writeAll(sb *strings.Builder, nodes []*ASTNode) {
+	for i, node := range nodes {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		node.write(sb)
+	}
+}
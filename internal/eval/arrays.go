@@ -0,0 +1,222 @@
+package eval
+
+import (
+	"gortle/internal/thing"
+)
+
+func (in *Interp) addArrays() {
+	in.prim("array", 1, 1, 2, primArray)
+	in.prim("mdarray", 1, 1, 2, primMdarray)
+	in.prim("mditem", 2, 2, 2, primMditem)
+	in.prim("setitem", 3, 3, 3, primSetitem)
+	in.prim(".setitem", 3, 3, 3, primSetitem)
+	in.prim("mdsetitem", 3, 3, 3, primMdsetitem)
+	in.prim("arraytolist", 1, 1, 1, primArraytolist)
+	in.prim("listtoarray", 1, 1, 2, primListtoarray)
+	in.prim("arrayp array?", 1, 1, 1, primArrayp)
+}
+
+// rows returns the members of a one-dimensional array, or the rows of
+// a multi-dimensional one.
+func rows(arr *thing.TArray) []*thing.Thing {
+	dims := arr.Dims()
+	if len(dims) == 1 {
+		return arr.Values()
+	}
+	items := make([]*thing.Thing, dims[0])
+	for i := range items {
+		sub, _ := arr.Sub(arr.Origin() + i)
+		items[i] = thing.New(sub, thing.TagTArray, false)
+	}
+	return items
+}
+
+func (c *call) array(i int) (*thing.TArray, error) {
+	if arr, ok := c.args[i].Value().(*thing.TArray); ok {
+		return arr, nil
+	}
+	return nil, c.badInput(i)
+}
+
+// integer reads a whole number input.
+func (c *call) integer(i int) (int, error) {
+	n, err := c.number(i)
+	if err != nil {
+		return 0, err
+	}
+	if n != float64(int(n)) {
+		return 0, c.badInput(i)
+	}
+	return int(n), nil
+}
+
+// integers reads a list of whole numbers.
+func (c *call) integers(i int) ([]int, error) {
+	lst, err := c.list(i)
+	if err != nil || len(lst) == 0 {
+		return nil, c.badInput(i)
+	}
+	ns := make([]int, len(lst))
+	for j, elt := range lst {
+		n, ok := elt.Number()
+		if !ok || n != float64(int(n)) {
+			return nil, c.badInput(i)
+		}
+		ns[j] = int(n)
+	}
+	return ns, nil
+}
+
+// origin reads the optional origin input at i, which defaults to 1.
+func (c *call) origin(i int) (int, error) {
+	if len(c.args) <= i {
+		return 1, nil
+	}
+	return c.integer(i)
+}
+
+func (c *call) newArray(dims []int, i int) (*thing.Thing, error) {
+	origin, err := c.origin(i)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := thing.NewArray(dims, origin, false)
+	if err != nil {
+		return nil, c.badInput(0)
+	}
+	return arr, nil
+}
+
+func primArray(c *call) (*thing.Thing, error) {
+	size, err := c.integer(0)
+	if err != nil {
+		return nil, err
+	}
+	return c.newArray([]int{size}, 1)
+}
+
+func primMdarray(c *call) (*thing.Thing, error) {
+	dims, err := c.integers(0)
+	if err != nil {
+		return nil, err
+	}
+	return c.newArray(dims, 1)
+}
+
+// primMditem selects with one index per dimension. Fewer indices select
+// a row.
+func primMditem(c *call) (*thing.Thing, error) {
+	coords, err := c.integers(0)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := c.array(1)
+	if err != nil {
+		return nil, err
+	}
+	for len(coords) > 1 && len(arr.Dims()) > 1 {
+		if arr, err = arr.Sub(coords[0]); err != nil {
+			return nil, c.badInput(0)
+		}
+		coords = coords[1:]
+	}
+	if len(arr.Dims()) > 1 {
+		sub, err := arr.Sub(coords[0])
+		if err != nil {
+			return nil, c.badInput(0)
+		}
+		return thing.New(sub, thing.TagTArray, false), nil
+	}
+	value, err := arr.GetAt(coords)
+	if err != nil {
+		return nil, c.badInput(0)
+	}
+	return value, nil
+}
+
+// contains reports whether arr can be reached from t. SETITEM refuses
+// to make an array part of itself, which would loop forever when
+// printed; .SETITEM does not check.
+func contains(t *thing.Thing, arr *thing.TArray) bool {
+	switch v := t.Value().(type) {
+	case *thing.TArray:
+		if v == arr {
+			return true
+		}
+		for _, elt := range v.Values() {
+			if contains(elt, arr) {
+				return true
+			}
+		}
+	case thing.TList:
+		for _, elt := range v {
+			if contains(elt, arr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *call) setAt(coords []int, arr *thing.TArray) (*thing.Thing, error) {
+	value := c.args[2]
+	if c.name == "setitem" || c.name == "mdsetitem" {
+		if contains(value, arr) {
+			return nil, c.badInput(2)
+		}
+	}
+	if err := arr.SetAt(coords, value); err != nil {
+		return nil, c.badInput(0)
+	}
+	return nil, nil
+}
+
+func primSetitem(c *call) (*thing.Thing, error) {
+	i, err := c.integer(0)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := c.array(1)
+	if err != nil || len(arr.Dims()) != 1 {
+		return nil, c.badInput(1)
+	}
+	return c.setAt([]int{i}, arr)
+}
+
+func primMdsetitem(c *call) (*thing.Thing, error) {
+	coords, err := c.integers(0)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := c.array(1)
+	if err != nil {
+		return nil, err
+	}
+	return c.setAt(coords, arr)
+}
+
+func primArraytolist(c *call) (*thing.Thing, error) {
+	arr, err := c.array(0)
+	if err != nil {
+		return nil, err
+	}
+	return thing.New(append(thing.TList{}, rows(arr)...), thing.TagTList, false), nil
+}
+
+func primListtoarray(c *call) (*thing.Thing, error) {
+	lst, err := c.list(0)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := c.newArray([]int{len(lst)}, 1)
+	if err != nil {
+		return nil, err
+	}
+	copy(arr.Value().(*thing.TArray).Values(), lst)
+	return arr, nil
+}
+
+func primArrayp(c *call) (*thing.Thing, error) {
+	_, ok := c.args[0].Value().(*thing.TArray)
+	return boolean(ok), nil
+}
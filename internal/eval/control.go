@@ -0,0 +1,462 @@
+package eval
+
+import (
+	"math"
+	"strings"
+
+	"gortle/internal/ast"
+	"gortle/internal/logoerr"
+	"gortle/internal/parser"
+	"gortle/internal/thing"
+)
+
+// gotoSignal unwinds to the call of proc, which resumes its body at
+// index.
+type gotoSignal struct {
+	proc  *thing.TProc
+	index int
+}
+
+func (s *gotoSignal) Error() string {
+	return "goto outside a procedure"
+}
+
+// testState is the result of the last TEST, which IFTRUE and IFFALSE
+// consult. Each procedure call has its own.
+type testState struct {
+	set, value bool
+}
+
+func (in *Interp) addControl() {
+	in.prim("if", 2, 2, 3, primIf)
+	in.prim("ifelse", 3, 3, 3, primIf)
+	in.prim("test", 1, 1, 1, primTest)
+	in.prim("iftrue ift", 1, 1, 1, primIftrue)
+	in.prim("iffalse iff", 1, 1, 1, primIftrue)
+	in.prim("repeat", 2, 2, 2, primRepeat)
+	in.prim("forever", 1, 1, 1, primForever)
+	in.prim("repcount", 0, 0, 0, primRepcount)
+	in.prim("for", 2, 2, 2, primFor)
+	in.prim("while", 2, 2, 2, primWhile)
+	in.prim("until", 2, 2, 2, primWhile)
+	in.prim("do.while", 2, 2, 2, primDoWhile)
+	in.prim("do.until", 2, 2, 2, primDoWhile)
+	in.prim("case", 2, 2, 2, primCase)
+	in.prim("cond", 1, 1, 1, primCond)
+	in.prim("goto", 1, 1, 1, primGoto)
+	in.prim("tag", 1, 1, 1, primTag)
+	in.prim("not", 1, 1, 1, primNot)
+	in.prim("and", 0, 2, -1, primAnd)
+	in.prim("or", 0, 2, -1, primOr)
+}
+
+func primNot(c *call) (*thing.Thing, error) {
+	cond, err := c.bool(0)
+	if err != nil {
+		return nil, err
+	}
+	return boolean(!cond), nil
+}
+
+// primAnd outputs whether all its inputs are true. An input may be a
+// list that outputs true or false, which is only run if the inputs
+// before it were all true.
+func primAnd(c *call) (*thing.Thing, error) {
+	for i := range c.args {
+		cond, err := c.condition(i)
+		if err != nil || !cond {
+			return boolean(false), err
+		}
+	}
+	return boolean(true), nil
+}
+
+// primOr outputs whether any of its inputs is true, running lists as
+// AND does until one is.
+func primOr(c *call) (*thing.Thing, error) {
+	for i := range c.args {
+		cond, err := c.condition(i)
+		if err != nil || cond {
+			return boolean(cond), err
+		}
+	}
+	return boolean(false), nil
+}
+
+// ifBranch chooses the list an IF or IFELSE runs, or nil when a
+// two-input IF is false.
+func ifBranch(c *call) (*thing.Thing, error) {
+	cond, err := c.bool(0)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(c.args); i++ {
+		if _, err := c.list(i); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case cond:
+		return c.args[1], nil
+	case len(c.args) == 3:
+		return c.args[2], nil
+	}
+	return nil, nil
+}
+
+func primIf(c *call) (*thing.Thing, error) {
+	branch, err := ifBranch(c)
+	if err != nil || branch == nil {
+		return nil, err
+	}
+	return c.in.runList(branch, modeMaybe, false)
+}
+
+// test returns the TEST state of the running procedure, or of the top
+// level.
+func (in *Interp) test() *testState {
+	if in.frame != nil {
+		return &in.frame.test
+	}
+	return &in.topTest
+}
+
+func primTest(c *call) (*thing.Thing, error) {
+	cond, err := c.bool(0)
+	if err != nil {
+		return nil, err
+	}
+	*c.in.test() = testState{set: true, value: cond}
+	return nil, nil
+}
+
+// primIftrue runs its input if the last TEST agreed with it: was true
+// for IFTRUE, false for IFFALSE.
+func primIftrue(c *call) (*thing.Thing, error) {
+	test := c.in.test()
+	if !test.set {
+		return nil, c.errorf(logoerr.ERR_NoTest, "%s without TEST", c.name)
+	}
+	if _, err := c.list(0); err != nil {
+		return nil, err
+	}
+	want := c.in.prims[c.name].name == "iftrue"
+	if test.value != want {
+		return nil, nil
+	}
+	return c.in.runList(c.args[0], modeMaybe, false)
+}
+
+// loop runs body as a command, with REPCOUNT outputting count.
+func (c *call) loop(body *thing.Thing, count int) error {
+	in := c.in
+	outer := in.repcount
+	in.repcount = count
+	defer func() { in.repcount = outer }()
+	_, err := in.runList(body, modeCommand, false)
+	return err
+}
+
+func primRepeat(c *call) (*thing.Thing, error) {
+	n, err := c.integer(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.list(1); err != nil {
+		return nil, err
+	}
+	for i := 1; i <= n; i++ {
+		if err := c.loop(c.args[1], i); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func primForever(c *call) (*thing.Thing, error) {
+	if _, err := c.list(0); err != nil {
+		return nil, err
+	}
+	for i := 1; ; i++ {
+		if err := c.loop(c.args[0], i); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// primRepcount outputs the number of the current pass through the
+// innermost REPEAT or FOREVER, or -1 outside them.
+func primRepcount(c *call) (*thing.Thing, error) {
+	return number(float64(c.in.repcount)), nil
+}
+
+// primFor runs its instructions with a variable stepping from a start
+// to a limit: for [var start limit step] [...]. The start, limit and
+// optional step are expressions. Without a step the variable counts by
+// 1 towards the limit. The number of passes is worked out before the
+// first, and the variable set to start + k*step on each, so that
+// fractional steps do not gather rounding errors. The variable is
+// local to the FOR.
+func primFor(c *call) (*thing.Thing, error) {
+	control, err := c.list(0)
+	if err != nil || len(control) == 0 {
+		return nil, c.badInput(0)
+	}
+	name, ok := control[0].Word()
+	if !ok {
+		return nil, c.badInput(0)
+	}
+	if _, err := c.list(1); err != nil {
+		return nil, err
+	}
+	datum := thing.ToDatum(c.args[0])
+	values, err := c.expressions(ast.NewList(datum.Pos, datum.Children[1:]))
+	if err != nil {
+		return nil, err
+	}
+	if len(values) < 2 || len(values) > 3 {
+		return nil, c.badInput(0)
+	}
+	var nums [3]float64
+	for i, value := range values {
+		n, ok := value.Number()
+		if !ok {
+			return nil, c.badInput(0)
+		}
+		nums[i] = n
+	}
+	start, limit, step := nums[0], nums[1], nums[2]
+	if len(values) == 2 {
+		step = 1
+		if limit < start {
+			step = -1
+		}
+	}
+	if step == 0 {
+		return nil, c.badInput(0)
+	}
+
+	in := c.in
+	sym := thing.Symbol(name)
+	env := in.globals
+	if in.frame != nil {
+		env = in.frame.vars
+	}
+	outer, bound := env.Lookup(sym)
+	defer func() {
+		if bound {
+			env.SetVariable(sym, outer)
+		} else {
+			env.DeleteVariable(sym)
+		}
+	}()
+	passes := math.Floor((limit-start)/step+1e-9) + 1
+	for k := 0.0; k < passes; k++ {
+		env.SetVariable(sym, number(start+k*step))
+		if _, err := in.runList(c.args[1], modeCommand, false); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// expressions evaluates each expression in a list datum.
+func (c *call) expressions(datum *ast.ASTNode) ([]*thing.Thing, error) {
+	instrs, err := parser.ParseList(datum, c.in)
+	if err != nil {
+		return nil, c.in.locate(err, nil)
+	}
+	values := make([]*thing.Thing, len(instrs))
+	for i, instr := range instrs {
+		if values[i], err = c.in.evalArg(instr, c.name); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// condition evaluates a WHILE, UNTIL, AND or OR test, a list of
+// instructions that outputs true or false or one of those words.
+func (c *call) condition(i int) (bool, error) {
+	value := c.args[i]
+	if _, ok := value.Value().(thing.TList); ok {
+		var err error
+		if value, err = c.in.runList(value, modeValue, false); err != nil {
+			return false, err
+		}
+		if value == nil {
+			return false, c.errorf(logoerr.ERR_DidntOutput, "%s didn't output to %s", show(c.args[i]), c.name)
+		}
+	}
+	w, ok := value.Word()
+	switch {
+	case ok && strings.EqualFold(w, "true"):
+		return true, nil
+	case ok && strings.EqualFold(w, "false"):
+		return false, nil
+	}
+	return false, c.errorf(logoerr.ERR_BadInput, "%s doesn't like %s as input", c.name, show(value))
+}
+
+// primWhile runs its instructions as long as the test is true, for
+// WHILE, or until it is true, for UNTIL. The test comes first.
+func primWhile(c *call) (*thing.Thing, error) {
+	if _, err := c.list(1); err != nil {
+		return nil, err
+	}
+	want := c.name == "while"
+	for {
+		cond, err := c.condition(0)
+		if err != nil {
+			return nil, err
+		}
+		if cond != want {
+			return nil, nil
+		}
+		if _, err := c.in.runList(c.args[1], modeCommand, false); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// primDoWhile is WHILE or UNTIL with the instructions first and the
+// test after them, so that they run at least once.
+func primDoWhile(c *call) (*thing.Thing, error) {
+	if _, err := c.list(0); err != nil {
+		return nil, err
+	}
+	want := c.name == "do.while"
+	for {
+		if _, err := c.in.runList(c.args[0], modeCommand, false); err != nil {
+			return nil, err
+		}
+		cond, err := c.condition(1)
+		if err != nil {
+			return nil, err
+		}
+		if cond != want {
+			return nil, nil
+		}
+	}
+}
+
+// clauses reads the clauses of a CASE or COND: lists whose first member
+// selects them and whose rest is run when it does.
+func (c *call) clauses(i int) (thing.TList, error) {
+	lst, err := c.list(i)
+	if err != nil {
+		return nil, err
+	}
+	for _, clause := range lst {
+		if items, ok := clause.Value().(thing.TList); !ok || len(items) == 0 {
+			return nil, c.badInput(i)
+		}
+	}
+	return lst, nil
+}
+
+// runClause runs the rest of a clause, outputting its value if it has
+// one.
+func (c *call) runClause(clause *thing.Thing) (*thing.Thing, error) {
+	datum := thing.ToDatum(clause)
+	instrs, err := parser.ParseList(ast.NewList(datum.Pos, datum.Children[1:]), c.in)
+	if err != nil {
+		return nil, c.in.locate(err, nil)
+	}
+	return c.in.runInstrs(instrs, modeMaybe, false)
+}
+
+func isElse(t *thing.Thing) bool {
+	w, ok := t.Word()
+	return ok && strings.EqualFold(w, "else")
+}
+
+// primCase runs the first clause whose first member is a list containing
+// the value, or is the word ELSE.
+func primCase(c *call) (*thing.Thing, error) {
+	clauses, err := c.clauses(1)
+	if err != nil {
+		return nil, err
+	}
+	for _, clause := range clauses {
+		sel := clause.Value().(thing.TList)[0]
+		if !isElse(sel) {
+			values, ok := sel.Value().(thing.TList)
+			if !ok {
+				return nil, c.badInput(1)
+			}
+			found := false
+			for _, value := range values {
+				if c.in.equal(value, c.args[0]) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		return c.runClause(clause)
+	}
+	return nil, nil
+}
+
+// primCond runs the first clause whose first member is a list of
+// instructions outputting true, or is the word ELSE.
+func primCond(c *call) (*thing.Thing, error) {
+	clauses, err := c.clauses(0)
+	if err != nil {
+		return nil, err
+	}
+	for _, clause := range clauses {
+		sel := clause.Value().(thing.TList)[0]
+		if !isElse(sel) {
+			test := &call{in: c.in, name: c.name, node: c.node, args: []*thing.Thing{sel}}
+			if _, ok := sel.Value().(thing.TList); !ok {
+				return nil, c.badInput(0)
+			}
+			ok, err := test.condition(0)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		return c.runClause(clause)
+	}
+	return nil, nil
+}
+
+// primGoto continues the running procedure from the instruction after
+// TAG with the same input, which must be one of the instructions of its
+// body.
+func primGoto(c *call) (*thing.Thing, error) {
+	tag, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	in := c.in
+	if in.frame == nil {
+		return nil, c.errorf(logoerr.ERR_OnlyInProc, "Can only use %s inside a procedure", c.name)
+	}
+	proc := in.frame.proc
+	body, err := in.procBody(proc)
+	if err != nil {
+		return nil, err
+	}
+	for i, instr := range body {
+		if instr.Type != ast.NODE_Call || instr.Name != "tag" || len(instr.Children) != 1 {
+			continue
+		}
+		if arg := instr.Children[0]; arg.Type == ast.NODE_Quoted && strings.EqualFold(arg.Text, tag) {
+			return nil, &gotoSignal{proc: proc, index: i + 1}
+		}
+	}
+	return nil, c.errorf(logoerr.ERR_BadInput, "%s doesn't like %s as input", c.name, tag)
+}
+
+// primTag marks the place in a procedure a GOTO continues from.
+func primTag(c *call) (*thing.Thing, error) {
+	return nil, nil
+}
@@ -0,0 +1,333 @@
+package eval
+
+import (
+	"math/rand"
+	"strings"
+
+	"gortle/internal/thing"
+)
+
+func (in *Interp) addData() {
+	in.prim("first", 1, 1, 1, primFirst)
+	in.prim("last", 1, 1, 1, primLast)
+	in.prim("butfirst bf", 1, 1, 1, primButfirst)
+	in.prim("butlast bl", 1, 1, 1, primButlast)
+	in.prim("item", 2, 2, 2, primItem)
+	in.prim("fput", 2, 2, 2, primFput)
+	in.prim("lput", 2, 2, 2, primLput)
+	in.prim("sentence se", 0, 2, -1, primSentence)
+	in.prim("word", 0, 2, -1, primWord)
+	in.prim("list", 0, 2, -1, primList)
+	in.prim("count", 1, 1, 1, primCount)
+	in.prim("wordp word?", 1, 1, 1, primWordp)
+	in.prim("listp list?", 1, 1, 1, primListp)
+	in.prim("numberp number?", 1, 1, 1, primNumberp)
+	in.prim("emptyp empty?", 1, 1, 1, primEmptyp)
+	in.prim("memberp member?", 2, 2, 2, primMemberp)
+	in.prim("member", 2, 2, 2, primMember)
+	in.prim("remove", 2, 2, 2, primRemove)
+	in.prim("reverse", 1, 1, 1, primReverse)
+	in.prim("pick", 1, 1, 1, primPick)
+}
+
+// members is a word, list or array taken apart. The members of a word
+// are its characters, as one-character words, and those of a
+// multi-dimensional array are its rows.
+type members struct {
+	tag     thing.Tag
+	items   []*thing.Thing
+	escaped bool
+	origin  int
+}
+
+func (c *call) members(i int) (*members, error) {
+	arg := c.args[i]
+	switch v := arg.Value().(type) {
+	case thing.TList:
+		return &members{tag: thing.TagTList, items: v}, nil
+	case *thing.TArray:
+		return &members{tag: thing.TagTArray, items: rows(v), origin: v.Origin()}, nil
+	}
+	w, ok := arg.Word()
+	if !ok {
+		return nil, c.badInput(i)
+	}
+	m := &members{tag: thing.TagTString, escaped: arg.Escaped()}
+	for _, ch := range w {
+		m.items = append(m.items, thing.NewWord(string(ch), arg.Escaped()))
+	}
+	return m, nil
+}
+
+// make builds a new word, list or array of the same kind as m.
+func (m *members) make(items []*thing.Thing) *thing.Thing {
+	switch m.tag {
+	case thing.TagTList:
+		return thing.New(append(thing.TList{}, items...), thing.TagTList, false)
+	case thing.TagTArray:
+		arr, _ := thing.NewArray([]int{len(items)}, m.origin, false)
+		copy(arr.Value().(*thing.TArray).Values(), items)
+		return arr
+	}
+	var sb strings.Builder
+	for _, item := range items {
+		w, _ := item.Word()
+		sb.WriteString(w)
+	}
+	return thing.NewWord(sb.String(), m.escaped)
+}
+
+// nonEmpty is members for primitives that need at least one member.
+func (c *call) nonEmpty(i int) (*members, error) {
+	m, err := c.members(i)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.items) == 0 {
+		return nil, c.badInput(i)
+	}
+	return m, nil
+}
+
+// primFirst outputs the first member of a word or list. As in UCBLogo,
+// the first of an array is its origin.
+func primFirst(c *call) (*thing.Thing, error) {
+	if arr, ok := c.args[0].Value().(*thing.TArray); ok {
+		return number(float64(arr.Origin())), nil
+	}
+	m, err := c.nonEmpty(0)
+	if err != nil {
+		return nil, err
+	}
+	return m.items[0], nil
+}
+
+func primLast(c *call) (*thing.Thing, error) {
+	m, err := c.nonEmpty(0)
+	if err != nil {
+		return nil, err
+	}
+	return m.items[len(m.items)-1], nil
+}
+
+func primButfirst(c *call) (*thing.Thing, error) {
+	if lst, ok := c.args[0].Value().(thing.TList); ok {
+		rest, err := lst.ButFirst()
+		if err != nil {
+			return nil, c.badInput(0)
+		}
+		return thing.New(thing.TList(rest), thing.TagTList, false), nil
+	}
+	m, err := c.nonEmpty(0)
+	if err != nil {
+		return nil, err
+	}
+	return m.make(m.items[1:]), nil
+}
+
+func primButlast(c *call) (*thing.Thing, error) {
+	if lst, ok := c.args[0].Value().(thing.TList); ok {
+		rest, err := lst.ButLast()
+		if err != nil {
+			return nil, c.badInput(0)
+		}
+		return thing.New(thing.TList(rest), thing.TagTList, false), nil
+	}
+	m, err := c.nonEmpty(0)
+	if err != nil {
+		return nil, err
+	}
+	return m.make(m.items[:len(m.items)-1]), nil
+}
+
+// primItem counts from 1 in words and lists and from the origin in
+// arrays.
+func primItem(c *call) (*thing.Thing, error) {
+	n, err := c.number(0)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.members(1)
+	if err != nil {
+		return nil, err
+	}
+	first := 1
+	if m.tag == thing.TagTArray {
+		first = m.origin
+	}
+	i := int(n) - first
+	if n != float64(int(n)) || i < 0 || i >= len(m.items) {
+		return nil, c.badInput(0)
+	}
+	return m.items[i], nil
+}
+
+// put adds the first input to the front or back of the second: a list,
+// or a word if the first input is a one-character word.
+func put(c *call, front bool) (*thing.Thing, error) {
+	if lst, ok := c.args[1].Value().(thing.TList); ok {
+		item := thing.TList{c.args[0]}
+		if front {
+			return item.CombineWith(lst, false), nil
+		}
+		return lst.CombineWith(item, false), nil
+	}
+	w, ok := c.args[1].Word()
+	if !ok {
+		return nil, c.badInput(1)
+	}
+	ch, ok := c.args[0].Word()
+	if !ok || len([]rune(ch)) != 1 {
+		return nil, c.badInput(0)
+	}
+	escaped := c.args[0].Escaped() || c.args[1].Escaped()
+	if front {
+		return thing.NewWord(ch+w, escaped), nil
+	}
+	return thing.NewWord(w+ch, escaped), nil
+}
+
+func primFput(c *call) (*thing.Thing, error) {
+	return put(c, true)
+}
+
+func primLput(c *call) (*thing.Thing, error) {
+	return put(c, false)
+}
+
+// primSentence outputs a list of its inputs, with the members of list
+// inputs in place of the lists.
+func primSentence(c *call) (*thing.Thing, error) {
+	se := thing.TList{}
+	for _, arg := range c.args {
+		if lst, ok := arg.Value().(thing.TList); ok {
+			se = append(se, lst...)
+		} else {
+			se = append(se, arg)
+		}
+	}
+	return thing.New(se, thing.TagTList, false), nil
+}
+
+func primWord(c *call) (*thing.Thing, error) {
+	var sb strings.Builder
+	escaped := false
+	for i := range c.args {
+		w, err := c.word(i)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(w)
+		escaped = escaped || c.args[i].Escaped()
+	}
+	return thing.NewWord(sb.String(), escaped), nil
+}
+
+func primList(c *call) (*thing.Thing, error) {
+	lst := make(thing.TList, len(c.args))
+	copy(lst, c.args)
+	return thing.New(lst, thing.TagTList, false), nil
+}
+
+func primCount(c *call) (*thing.Thing, error) {
+	m, err := c.members(0)
+	if err != nil {
+		return nil, err
+	}
+	return number(float64(len(m.items))), nil
+}
+
+func primWordp(c *call) (*thing.Thing, error) {
+	_, ok := c.args[0].Word()
+	return boolean(ok), nil
+}
+
+func primListp(c *call) (*thing.Thing, error) {
+	_, ok := c.args[0].Value().(thing.TList)
+	return boolean(ok), nil
+}
+
+// primNumberp outputs whether its input is a number, or a word that
+// reads as one.
+func primNumberp(c *call) (*thing.Thing, error) {
+	_, ok := c.args[0].Number()
+	return boolean(ok), nil
+}
+
+func primEmptyp(c *call) (*thing.Thing, error) {
+	if lst, ok := c.args[0].Value().(thing.TList); ok {
+		return boolean(len(lst) == 0), nil
+	}
+	w, ok := c.args[0].Word()
+	return boolean(ok && w == ""), nil
+}
+
+// index returns the position of the first member of the second input
+// equal to the first, or -1.
+func (c *call) index() (*members, int, error) {
+	m, err := c.members(1)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i, item := range m.items {
+		if c.in.equal(c.args[0], item) {
+			return m, i, nil
+		}
+	}
+	return m, -1, nil
+}
+
+func primMemberp(c *call) (*thing.Thing, error) {
+	_, i, err := c.index()
+	if err != nil {
+		return nil, err
+	}
+	return boolean(i >= 0), nil
+}
+
+// primMember outputs the part of its second input starting with the
+// first input, or an empty word or list.
+func primMember(c *call) (*thing.Thing, error) {
+	m, i, err := c.index()
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 {
+		return m.make(nil), nil
+	}
+	return m.make(m.items[i:]), nil
+}
+
+func primRemove(c *call) (*thing.Thing, error) {
+	m, err := c.members(1)
+	if err != nil {
+		return nil, err
+	}
+	var kept []*thing.Thing
+	for _, item := range m.items {
+		if !c.in.equal(c.args[0], item) {
+			kept = append(kept, item)
+		}
+	}
+	return m.make(kept), nil
+}
+
+func primReverse(c *call) (*thing.Thing, error) {
+	m, err := c.members(0)
+	if err != nil {
+		return nil, err
+	}
+	rev := make([]*thing.Thing, len(m.items))
+	for i, item := range m.items {
+		rev[len(rev)-1-i] = item
+	}
+	return m.make(rev), nil
+}
+
+func primPick(c *call) (*thing.Thing, error) {
+	m, err := c.nonEmpty(0)
+	if err != nil {
+		return nil, err
+	}
+	return m.items[rand.Intn(len(m.items))], nil
+}
@@ -0,0 +1,266 @@
+package eval
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"gortle/internal/lexer"
+	"gortle/internal/logoerr"
+	"gortle/internal/parser"
+	"gortle/internal/thing"
+)
+
+// throwSignal unwinds to the CATCH for tag, or to top level if tag is
+// "toplevel".
+type throwSignal struct {
+	tag   string
+	value *thing.Thing
+}
+
+// continueSignal ends the innermost PAUSE.
+type continueSignal struct {
+	value *thing.Thing
+}
+
+func (s *throwSignal) Error() string {
+	return "Can't find catch tag for " + s.tag
+}
+
+func (s *continueSignal) Error() string {
+	return "continue outside a pause"
+}
+
+func (in *Interp) addErrors() {
+	in.prim("catch", 2, 2, 2, primCatch)
+	in.prim("throw", 1, 1, 2, primThrow)
+	in.prim("error", 0, 0, 0, primError)
+	in.prim("pause", 0, 0, 0, primPause)
+	in.prim("continue co", 0, 0, 1, primContinue)
+}
+
+// SetInput gives PAUSE somewhere to read instructions from. Without
+// one, PAUSE returns at once.
+func (in *Interp) SetInput(r io.Reader) {
+	if rr, ok := r.(io.RuneReader); ok {
+		in.input = rr
+		return
+	}
+	in.input = bufio.NewReader(r)
+}
+
+func (in *Interp) catching(tag string) bool {
+	for _, t := range in.catches {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// hold stops OUTPUT in the current frame from making tail calls until
+// the returned function is called.
+func (in *Interp) hold() func() {
+	f := in.frame
+	if f == nil {
+		return func() {}
+	}
+	f.held++
+	return func() { f.held-- }
+}
+
+// errorAction runs ERRACT, if it is set, for an error that nothing is
+// going to catch. A value it outputs replaces the value of the failed
+// expression when the error allows it; otherwise the program goes back
+// to top level.
+func (in *Interp) errorAction(e *logoerr.Error) (*thing.Thing, error) {
+	in.reported = e
+	if in.erring || in.catching("error") {
+		return nil, e
+	}
+	act, ok := in.lookup("erract")
+	if !ok || act == nil {
+		return nil, e
+	}
+	if w, ok := act.Word(); ok && (w == "" || strings.EqualFold(w, "false")) {
+		return nil, e
+	}
+	if lst, ok := act.Value().(thing.TList); ok && len(lst) == 0 {
+		return nil, e
+	}
+
+	in.lastError = e
+	if _, err := fmt.Fprintln(in.out, e.Error()); err != nil {
+		return nil, e
+	}
+	in.erring = true
+	value, err := in.runList(act, modeMaybe, false)
+	in.erring = false
+	if err != nil {
+		return nil, err
+	}
+	if value != nil && e.Recoverable() {
+		return value, nil
+	}
+	return nil, &throwSignal{tag: "toplevel"}
+}
+
+// primCatch runs its instructions, stopping them at a THROW to its
+// tag. The tag "error" also catches errors, which ERROR then reports.
+func primCatch(c *call) (*thing.Thing, error) {
+	tag, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	tag = strings.ToLower(tag)
+	in := c.in
+	in.catches = append(in.catches, tag)
+	release := in.hold()
+	value, err := in.runList(c.args[1], modeMaybe, false)
+	release()
+	in.catches = in.catches[:len(in.catches)-1]
+
+	if sig, ok := err.(*throwSignal); ok && sig.tag == tag {
+		return sig.value, nil
+	}
+	if e, ok := err.(*logoerr.Error); ok && tag == "error" {
+		in.lastError = e
+		return nil, nil
+	}
+	return value, err
+}
+
+func primThrow(c *call) (*thing.Thing, error) {
+	tag, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	var value *thing.Thing
+	if len(c.args) > 1 {
+		value = c.args[1]
+	}
+	switch lower := strings.ToLower(tag); {
+	case lower == "error":
+		msg := "Throw \"Error"
+		if value != nil {
+			msg = printed(value)
+		}
+		return nil, c.errorf(logoerr.ERR_ThrowError, "%s", msg)
+	case lower == "toplevel" || c.in.catching(lower):
+		return nil, &throwSignal{tag: lower, value: value}
+	}
+	return nil, c.errorf(logoerr.ERR_NoCatchTag, "Can't find catch tag for %s", tag)
+}
+
+// primError outputs the last error caught as a list of its code,
+// message, procedure and position, or the empty list if there is none.
+func primError(c *call) (*thing.Thing, error) {
+	e := c.in.lastError
+	c.in.lastError = nil
+	if e == nil {
+		return thing.New(thing.TList{}, thing.TagTList, false), nil
+	}
+	empty := thing.New(thing.TList{}, thing.TagTList, false)
+	proc, pos := empty, empty
+	if e.Proc != "" {
+		proc = thing.NewWord(e.Proc, false)
+	}
+	if e.Pos.IsValid() {
+		pos = thing.NewWord(e.Pos.String(), false)
+	}
+	lst := thing.TList{number(float64(e.Code)), thing.NewWord(e.Message, false), proc, pos}
+	return thing.New(lst, thing.TagTList, false), nil
+}
+
+// primPause reads and runs instructions in the scope of the running
+// procedure until CONTINUE or the end of input. Errors are reported and
+// do not end the pause.
+func primPause(c *call) (*thing.Thing, error) {
+	in := c.in
+	if in.input == nil {
+		return nil, nil
+	}
+	prompt := "? "
+	if in.frame != nil {
+		prompt = in.frame.proc.Name() + prompt
+	}
+	in.pauses++
+	release := in.hold()
+	defer func() {
+		release()
+		in.pauses--
+	}()
+
+	for {
+		if _, err := io.WriteString(in.out, prompt); err != nil {
+			return nil, c.errorf(logoerr.ERR_FileSystem, "%v", err)
+		}
+		line, err := readLine(in.input)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, c.errorf(logoerr.ERR_FileSystem, "%v", err)
+		}
+		err = in.runLine(line)
+		if sig, ok := err.(*continueSignal); ok {
+			return sig.value, nil
+		}
+		if e, ok := err.(*logoerr.Error); ok {
+			if _, err := fmt.Fprintln(in.out, e.Error()); err != nil {
+				return nil, e
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// runLine runs the instructions on one line typed at a pause, stopping
+// at the first error.
+func (in *Interp) runLine(line string) error {
+	p := parser.New(lexer.New("pause", line, lexer.LexTopLevel), in)
+	for {
+		node, err := p.ParseInstruction()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return in.locate(err, nil)
+		}
+		if err := in.execute(node); err != nil {
+			return err
+		}
+	}
+}
+
+func readLine(r io.RuneReader) (string, error) {
+	var sb strings.Builder
+	for {
+		ch, _, err := r.ReadRune()
+		if err == io.EOF && sb.Len() > 0 {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if ch == '\n' {
+			return sb.String(), nil
+		}
+		sb.WriteRune(ch)
+	}
+}
+
+func primContinue(c *call) (*thing.Thing, error) {
+	if c.in.pauses == 0 {
+		return nil, c.errorf(logoerr.ERR_OnlyInProc, "Can only use %s inside a pause", c.name)
+	}
+	var value *thing.Thing
+	if len(c.args) > 0 {
+		value = c.args[0]
+	}
+	return nil, &continueSignal{value: value}
+}
@@ -0,0 +1,699 @@
+package eval
+
+import (
+	"io"
+	"strings"
+
+	"gortle/internal/ast"
+	"gortle/internal/lexer"
+	"gortle/internal/logoerr"
+	"gortle/internal/parser"
+	"gortle/internal/thing"
+)
+
+// maxParsed bounds the cache of run lists so that programs building
+// code at run time do not grow it without limit.
+const maxParsed = 4096
+
+// Turtle is the drawing state the graphics primitives act on. Angles
+// are in the turtle's own convention: degrees counterclockwise from
+// east.
+type Turtle interface {
+	Forward(dist float64) error
+	Back(dist float64) error
+	Left(angle float64)
+	Right(angle float64)
+	PenUp()
+	PenDown()
+	ShowTurtle()
+	HideTurtle()
+	Clear()
+	SetAngle(angle float64)
+	SetPenSize(size uint)
+	SetForegroundColor(r, g, b, a uint8)
+	SetBackgroundColor(r, g, b, a uint8)
+	GetForegroundColor() (uint8, uint8, uint8, uint8)
+	GetX() float64
+	GetY() float64
+	GetAngle() float64
+	GetPenSize() uint
+	GetTurtleVisibility() bool
+	PrintLabel(label string) error
+	BucketFill() error
+	Filled(r, g, b, a uint8, body func())
+	SavePicture(w io.Writer, format string) error
+	LoadPicture(r io.Reader) error
+	SetPaper(spec string) error
+}
+
+type primitive struct {
+	name           string
+	min, dflt, max int
+	// special primitives receive their inputs unevaluated in c.node.
+	special bool
+	fn      func(c *call) (*thing.Thing, error)
+}
+
+// call is one invocation of a primitive.
+type call struct {
+	in   *Interp
+	name string
+	node *ast.ASTNode
+	args []*thing.Thing
+}
+
+// frame holds the local variables of one procedure invocation. Lookups
+// walk the frames from the innermost outward, which gives Logo its
+// dynamic scope.
+type frame struct {
+	proc   *thing.TProc
+	vars   thing.TEnv
+	parent *frame
+	// held counts the CATCHes and PAUSEs running in this frame. While
+	// there are any, OUTPUT must not hand a tail call back to callProc,
+	// which would run it outside of them.
+	held int
+	test testState
+}
+
+// mode says what an instruction must do with its value.
+type mode int
+
+const (
+	modeCommand mode = iota // must not output
+	modeValue               // must output
+	modeMaybe               // may output or not
+)
+
+// outputSignal and stopSignal unwind to the enclosing procedure call.
+type outputSignal struct {
+	value *thing.Thing
+}
+
+type stopSignal struct{}
+
+// tailCall unwinds to the enclosing procedure call, which then runs
+// proc in its own place instead of nesting a new call. mode is what the
+// caller would have required of the value of the call.
+type tailCall struct {
+	proc *thing.TProc
+	args []*thing.Thing
+	node *ast.ASTNode
+	mode mode
+}
+
+type Interp struct {
+	globals thing.TEnv
+	frame   *frame
+	procs   map[string]*thing.Thing
+	prims   map[string]*primitive
+	plists  map[string]*plist
+	turtle  Turtle
+	out     io.Writer
+	parsed  map[*ast.ASTNode][]*ast.ASTNode
+	bodies  map[*thing.TProc][]*ast.ASTNode
+
+	catches   []string
+	lastError *logoerr.Error
+	// reported is the last error ERRACT has seen, so that it is acted on
+	// only by the innermost evaluation it escapes from.
+	reported *logoerr.Error
+	erring   bool
+	input    io.RuneReader
+	pauses   int
+	// slots are the inputs of the templates being run, innermost last.
+	slots    []*slots
+	repcount int
+	topTest  testState
+	poll     func() error
+}
+
+// New makes an interpreter that prints to out and draws with t. t may
+// be nil, in which case the graphics primitives fail.
+func New(out io.Writer, t Turtle) *Interp {
+	in := &Interp{
+		globals: make(thing.TEnv),
+		procs:   make(map[string]*thing.Thing),
+		prims:   make(map[string]*primitive),
+		plists:  make(map[string]*plist),
+		turtle:  t,
+		out:     out,
+		parsed:  make(map[*ast.ASTNode][]*ast.ASTNode),
+		bodies:  make(map[*thing.TProc][]*ast.ASTNode),
+
+		repcount: -1,
+	}
+	in.addPrimitives()
+	in.addData()
+	in.addArrays()
+	in.addPlists()
+	in.addRead()
+	in.addWorkspace()
+	in.addControl()
+	in.addTemplates()
+	in.addErrors()
+	in.addGraphics()
+	if t != nil {
+		t.SetAngle(90)
+	}
+	return in
+}
+
+func (s *outputSignal) Error() string {
+	return "output outside a procedure"
+}
+
+func (s *stopSignal) Error() string {
+	return "stop outside a procedure"
+}
+
+func (s *tailCall) Error() string {
+	return "tail call outside a procedure"
+}
+
+// errorf makes a Logo error raised by n in the running procedure.
+func (in *Interp) errorf(n *ast.ASTNode, code logoerr.Code, format string, args ...interface{}) error {
+	return in.locate(logoerr.Errorf(code, format, args...), n)
+}
+
+// locate fills in where a Logo error happened, if it does not say
+// already. Other errors, such as those from the operating system, are
+// turned into Logo errors.
+func (in *Interp) locate(err error, n *ast.ASTNode) error {
+	e, ok := logoerr.As(err)
+	if !ok {
+		e = logoerr.New(logoerr.ERR_Fatal, err.Error())
+	}
+	if !e.Pos.IsValid() && n != nil {
+		e.Pos = n.Pos
+	}
+	if e.Proc == "" && in.frame != nil {
+		e.Proc = in.frame.proc.Name()
+	}
+	return e
+}
+
+// Arity implements parser.ProcTable.
+func (in *Interp) Arity(name string) (min, dflt, max int, ok bool) {
+	if prim, ok := in.prims[name]; ok {
+		return prim.min, prim.dflt, prim.max, true
+	}
+	if proc, ok := in.procs[name]; ok {
+		min, dflt, max := proc.Value().(*thing.TProc).Arity()
+		return min, dflt, max, true
+	}
+	return 0, 0, 0, false
+}
+
+// Run reads and executes instructions from r until the end of input or
+// the first error.
+func (in *Interp) Run(name string, r io.Reader) error {
+	p := parser.New(lexer.NewReader(name, r, lexer.LexTopLevel), in)
+	for {
+		node, err := p.ParseInstruction()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := in.Execute(node); err != nil {
+			return err
+		}
+	}
+}
+
+func (in *Interp) RunString(name, src string) error {
+	return in.Run(name, strings.NewReader(src))
+}
+
+// SetPoll has poll called before each instruction is run, so that a
+// user interface can stay responsive while a program runs. An error
+// from poll abandons everything running and is returned by Execute;
+// CATCH does not see it.
+func (in *Interp) SetPoll(poll func() error) {
+	in.poll = poll
+}
+
+func (in *Interp) checkPoll() error {
+	if in.poll == nil {
+		return nil
+	}
+	return in.poll()
+}
+
+// Execute runs one top-level instruction or procedure definition.
+func (in *Interp) Execute(node *ast.ASTNode) error {
+	err := in.execute(node)
+	if sig, ok := err.(*throwSignal); ok && sig.tag == "toplevel" {
+		return nil
+	}
+	return err
+}
+
+func (in *Interp) execute(node *ast.ASTNode) error {
+	if err := in.checkPoll(); err != nil {
+		return err
+	}
+	if node.Type == ast.NODE_ProcDef {
+		return in.define(node)
+	}
+	value, err := in.eval(node)
+	if err != nil {
+		return err
+	}
+	if value != nil {
+		return in.errorf(node, logoerr.ERR_DontSay, "You don't say what to do with %s", show(value))
+	}
+	return nil
+}
+
+func (in *Interp) define(node *ast.ASTNode) error {
+	if _, ok := in.prims[node.Name]; ok {
+		return in.errorf(node, logoerr.ERR_IsPrimitive, "%s is a primitive", node.Name)
+	}
+	proc, err := thing.NewProcFromDef(node)
+	if err != nil {
+		return in.locate(err, node)
+	}
+	in.setProc(node.Name, proc)
+	return nil
+}
+
+// setProc installs a procedure. Cached parses may depend on the old
+// arity of the name, so they are all dropped.
+func (in *Interp) setProc(name string, proc *thing.Thing) {
+	in.procs[name] = proc
+	in.parsed = make(map[*ast.ASTNode][]*ast.ASTNode)
+	in.bodies = make(map[*thing.TProc][]*ast.ASTNode)
+}
+
+// eraseProc removes a procedure, dropping cached parses as setProc
+// does.
+func (in *Interp) eraseProc(name string) {
+	delete(in.procs, name)
+	in.parsed = make(map[*ast.ASTNode][]*ast.ASTNode)
+	in.bodies = make(map[*thing.TProc][]*ast.ASTNode)
+}
+
+func (in *Interp) eval(n *ast.ASTNode) (*thing.Thing, error) {
+	value, err := in.evalNode(n)
+	if e, ok := err.(*logoerr.Error); ok && e != in.reported {
+		return in.errorAction(e)
+	}
+	return value, err
+}
+
+func (in *Interp) evalNode(n *ast.ASTNode) (*thing.Thing, error) {
+	switch n.Type {
+	case ast.NODE_Number:
+		return thing.NewNumber(thing.TNumber(n.Number), false), nil
+	case ast.NODE_Quoted:
+		return thing.NewWord(n.Text, n.Escaped), nil
+	case ast.NODE_Variable:
+		value, ok := in.lookup(n.Name)
+		if !ok || value == nil {
+			return nil, in.errorf(n, logoerr.ERR_NoValue, "%s has no value", n.Name)
+		}
+		return value, nil
+	case ast.NODE_List, ast.NODE_Array:
+		return thing.FromDatum(n), nil
+	case ast.NODE_Call:
+		return in.evalCall(n)
+	case ast.NODE_Infix:
+		return in.evalInfix(n)
+	case ast.NODE_Negate:
+		arg, err := in.evalArg(n.Children[0], "minus")
+		if err != nil {
+			return nil, err
+		}
+		return in.apply("minus", []*thing.Thing{arg}, n)
+	case ast.NODE_ProcDef:
+		return nil, in.errorf(n, logoerr.ERR_ToInProc, "can't use to inside a procedure")
+	}
+	return nil, in.errorf(n, logoerr.ERR_Fatal, "I don't know how to evaluate %s", n)
+}
+
+var infixPrims = map[string]string{
+	"+":  "sum",
+	"-":  "difference",
+	"*":  "product",
+	"/":  "quotient",
+	"=":  "equalp",
+	"<>": "notequalp",
+	"<":  "lessp",
+	">":  "greaterp",
+	"<=": "lessequalp",
+	">=": "greaterequalp",
+}
+
+func (in *Interp) evalInfix(n *ast.ASTNode) (*thing.Thing, error) {
+	name := infixPrims[n.Name]
+	args := make([]*thing.Thing, 2)
+	for i, child := range n.Children {
+		arg, err := in.evalArg(child, name)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = arg
+	}
+	return in.apply(name, args, n)
+}
+
+func (in *Interp) evalCall(n *ast.ASTNode) (*thing.Thing, error) {
+	if prim, ok := in.prims[n.Name]; ok && prim.special {
+		return prim.fn(&call{in: in, name: n.Name, node: n})
+	}
+	args, err := in.evalArgs(n)
+	if err != nil {
+		return nil, err
+	}
+	return in.apply(n.Name, args, n)
+}
+
+func (in *Interp) evalArgs(n *ast.ASTNode) ([]*thing.Thing, error) {
+	args := make([]*thing.Thing, len(n.Children))
+	for i, child := range n.Children {
+		arg, err := in.evalArg(child, n.Name)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+// evalTail evaluates n as the last thing the current procedure does,
+// with m saying what the procedure's caller needs from it. A call to a
+// user procedure is not made here but handed back to callProc as a
+// tailCall, so that tail recursion runs in constant space. IF and
+// IFELSE pass the tail position on to the last instruction of the
+// branch they choose.
+func (in *Interp) evalTail(n *ast.ASTNode, m mode) (*thing.Thing, error) {
+	if n.Type != ast.NODE_Call || in.frame == nil {
+		return in.eval(n)
+	}
+	switch n.Name {
+	case "if", "ifelse":
+		args, err := in.evalArgs(n)
+		if err != nil {
+			return nil, err
+		}
+		branch, err := ifBranch(&call{in: in, name: n.Name, node: n, args: args})
+		if err != nil || branch == nil {
+			return nil, err
+		}
+		return in.runList(branch, m, true)
+	}
+	proc, ok := in.procs[n.Name]
+	if !ok {
+		return in.eval(n)
+	}
+	args, err := in.evalArgs(n)
+	if err != nil {
+		return nil, err
+	}
+	return nil, &tailCall{proc: proc.Value().(*thing.TProc), args: args, node: n, mode: m}
+}
+
+// evalArg evaluates an input to caller, which must produce a value.
+func (in *Interp) evalArg(n *ast.ASTNode, caller string) (*thing.Thing, error) {
+	value, err := in.eval(n)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		name := n.Name
+		if n.Type == ast.NODE_Infix {
+			name = infixPrims[n.Name]
+		}
+		return nil, in.errorf(n, logoerr.ERR_DidntOutput, "%s didn't output to %s", name, caller)
+	}
+	return value, nil
+}
+
+// apply calls the primitive or procedure name with evaluated inputs. n
+// is the node responsible for the call and positions any error.
+func (in *Interp) apply(name string, args []*thing.Thing, n *ast.ASTNode) (*thing.Thing, error) {
+	if prim, ok := in.prims[name]; ok {
+		return prim.fn(&call{in: in, name: name, node: n, args: args})
+	}
+	if proc, ok := in.procs[name]; ok {
+		return in.callProc(proc.Value().(*thing.TProc), args, n)
+	}
+	return nil, in.errorf(n, logoerr.ERR_DontKnowHow, "I don't know how to %s", name)
+}
+
+// callProc runs a user procedure. Tail calls made by its body reuse
+// the same frame: the new inputs are bound over the old variables,
+// which stay visible as they would have been to a nested call.
+func (in *Interp) callProc(proc *thing.TProc, args []*thing.Thing, n *ast.ASTNode) (*thing.Thing, error) {
+	f := &frame{vars: make(thing.TEnv), parent: in.frame}
+	in.frame = f
+	defer func() { in.frame = f.parent }()
+
+	chain := tailChain{want: modeMaybe}
+	for {
+		f.proc = proc
+		body, err := in.procBody(proc)
+		if err != nil {
+			return nil, err
+		}
+		if err := in.bindInputs(f, args, n); err != nil {
+			return nil, err
+		}
+
+		err = in.runBody(proc, body)
+		var value *thing.Thing
+		switch sig := err.(type) {
+		case *tailCall:
+			chain.add(proc, sig)
+			proc, args, n = sig.proc, sig.args, sig.node
+			continue
+		case *outputSignal:
+			value = sig.value
+		case *stopSignal, nil:
+		default:
+			return nil, err
+		}
+		return value, chain.finish(proc, value)
+	}
+}
+
+// runBody runs the body of proc, continuing after the TAG that a GOTO
+// names.
+func (in *Interp) runBody(proc *thing.TProc, body []*ast.ASTNode) error {
+	instrs := body
+	for {
+		_, err := in.runInstrs(instrs, modeCommand, true)
+		sig, ok := err.(*gotoSignal)
+		if !ok || sig.proc != proc {
+			return err
+		}
+		instrs = body[sig.index:]
+	}
+}
+
+// tailChain checks the value at the end of a run of tail calls against
+// what each caller in the run required. Only the innermost requirement
+// and the innermost conflict between requirements need to be kept.
+type tailChain struct {
+	want   mode
+	node   *ast.ASTNode
+	proc   string
+	broken func(value *thing.Thing) error
+}
+
+// add records that proc made the tail call tc.
+func (ch *tailChain) add(proc *thing.TProc, tc *tailCall) {
+	switch {
+	case tc.mode == modeMaybe:
+		return
+	case tc.mode == modeValue && ch.want == modeCommand:
+		outer := *ch
+		ch.broken = func(value *thing.Thing) error {
+			return outer.errorf(logoerr.ERR_DontSay, "You don't say what to do with %s", show(value))
+		}
+	case tc.mode == modeCommand && ch.want == modeValue:
+		outer, name := *ch, proc.Name()
+		ch.broken = func(*thing.Thing) error {
+			return outer.errorf(logoerr.ERR_DidntOutput, "%s didn't output to output", name)
+		}
+	}
+	ch.want, ch.node, ch.proc = tc.mode, tc.node, proc.Name()
+}
+
+// finish checks value, the result of proc, the last procedure called.
+func (ch *tailChain) finish(proc *thing.TProc, value *thing.Thing) error {
+	switch {
+	case ch.want == modeCommand && value != nil:
+		return ch.errorf(logoerr.ERR_DontSay, "You don't say what to do with %s", show(value))
+	case ch.want == modeValue && value == nil:
+		return ch.errorf(logoerr.ERR_DidntOutput, "%s didn't output to output", proc.Name())
+	case ch.broken != nil:
+		return ch.broken(value)
+	}
+	return nil
+}
+
+// errorf reports an error in the instruction that made the last tail
+// call recorded.
+func (ch *tailChain) errorf(code logoerr.Code, format string, args ...interface{}) error {
+	err := logoerr.Errorf(code, format, args...)
+	err.Pos, err.Proc = ch.node.Pos, ch.proc
+	return err
+}
+
+// bindInputs makes the inputs of f.proc local to f. Defaults of
+// optional inputs are evaluated inside the new frame, so they can
+// refer to the inputs before them.
+func (in *Interp) bindInputs(f *frame, args []*thing.Thing, n *ast.ASTNode) error {
+	for i, param := range f.proc.Params() {
+		sym := param.Symbol()
+		switch {
+		case param.Rest():
+			rest := thing.TList{}
+			if i < len(args) {
+				rest = append(rest, args[i:]...)
+			}
+			f.vars.SetVariable(sym, thing.New(rest, thing.TagTList, true))
+		case i < len(args):
+			f.vars.SetVariable(sym, args[i])
+		case param.Optional():
+			value, err := in.runList(param.Default(), modeValue, false)
+			if err != nil {
+				return err
+			}
+			if value == nil {
+				return in.errorf(n, logoerr.ERR_DidntOutput, "default for %s didn't output", param.Name())
+			}
+			f.vars.SetVariable(sym, value)
+		default:
+			return in.errorf(n, logoerr.ERR_NotEnoughInputs, "not enough inputs to %s", f.proc.Name())
+		}
+	}
+	return nil
+}
+
+func (in *Interp) procBody(proc *thing.TProc) ([]*ast.ASTNode, error) {
+	if body, ok := in.bodies[proc]; ok {
+		return body, nil
+	}
+	var body []*ast.ASTNode
+	for _, line := range proc.Lines() {
+		instrs, err := in.parse(line)
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, instrs...)
+	}
+	in.bodies[proc] = body
+	return body, nil
+}
+
+// parse reads a list datum as instructions, reusing earlier parses of
+// the same literal.
+func (in *Interp) parse(datum *ast.ASTNode) ([]*ast.ASTNode, error) {
+	if instrs, ok := in.parsed[datum]; ok {
+		return instrs, nil
+	}
+	instrs, err := parser.ParseList(datum, in)
+	if err != nil {
+		return nil, in.locate(err, nil)
+	}
+	if len(in.parsed) >= maxParsed {
+		in.parsed = make(map[*ast.ASTNode][]*ast.ASTNode)
+	}
+	in.parsed[datum] = instrs
+	return instrs, nil
+}
+
+// runList runs a list (or a single word) as instructions. m says what
+// the last instruction must do with its value, which is returned. With
+// tail set the last instruction is in tail position.
+func (in *Interp) runList(lst *thing.Thing, m mode, tail bool) (*thing.Thing, error) {
+	datum := thing.ToDatum(lst)
+	if lst.Tag() != thing.TagTList {
+		datum = ast.NewList(datum.Pos, []*ast.ASTNode{datum})
+	}
+	instrs, err := in.parse(datum)
+	if err != nil {
+		return nil, err
+	}
+	return in.runInstrs(instrs, m, tail)
+}
+
+func (in *Interp) runInstrs(instrs []*ast.ASTNode, m mode, tail bool) (*thing.Thing, error) {
+	for i, instr := range instrs {
+		if err := in.checkPoll(); err != nil {
+			return nil, err
+		}
+		last := i == len(instrs)-1
+		var value *thing.Thing
+		var err error
+		if last && tail {
+			value, err = in.evalTail(instr, m)
+		} else {
+			value, err = in.eval(instr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			if last && m != modeCommand {
+				return value, nil
+			}
+			return nil, in.errorf(instr, logoerr.ERR_DontSay, "You don't say what to do with %s", show(value))
+		}
+	}
+	return nil, nil
+}
+
+// lookup finds a variable, searching the active procedures from the
+// innermost outward and then the global workspace. A variable that
+// exists without a value is reported as found with a nil value.
+func (in *Interp) lookup(name string) (*thing.Thing, bool) {
+	sym := thing.Symbol(name)
+	env := in.binding(sym)
+	if env == nil {
+		return nil, false
+	}
+	return env.Lookup(sym)
+}
+
+// binding returns the environment holding sym, or nil.
+func (in *Interp) binding(sym thing.TSymbol) thing.TEnv {
+	for f := in.frame; f != nil; f = f.parent {
+		if _, ok := f.vars.Lookup(sym); ok {
+			return f.vars
+		}
+	}
+	if _, ok := in.globals.Lookup(sym); ok {
+		return in.globals
+	}
+	return nil
+}
+
+// setVariable assigns to the innermost existing binding of name, or
+// creates a global one.
+func (in *Interp) setVariable(name string, value *thing.Thing) {
+	sym := thing.Symbol(name)
+	env := in.binding(sym)
+	if env == nil {
+		env = in.globals
+	}
+	env.SetVariable(sym, value)
+}
+
+// makeLocal creates name without a value in the current procedure,
+// hiding any variable of the same name outside it.
+func (in *Interp) makeLocal(name string) {
+	sym := thing.Symbol(name)
+	if in.frame != nil {
+		in.frame.vars.SetVariable(sym, nil)
+		return
+	}
+	if _, ok := in.globals.Lookup(sym); !ok {
+		in.globals.SetVariable(sym, nil)
+	}
+}
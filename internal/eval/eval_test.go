@@ -0,0 +1,712 @@
+package eval
+
+import (
+	"errors"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"gortle/internal/turtle"
+)
+
+// fakeTurtle tracks position and heading and records every line drawn.
+type fakeTurtle struct {
+	x, y, angle float64
+	penDown     bool
+	hidden      bool
+	size        uint
+	r, g, b, a  uint8
+	lines       [][4]float64
+	labels      []string
+	paper       string
+}
+
+// newFakeTurtle makes a fake turtle in the state turtle.NewTurtle starts
+// in: shown at home with a white pen down.
+func newFakeTurtle() *fakeTurtle {
+	return &fakeTurtle{penDown: true, size: 1, r: 255, g: 255, b: 255, a: 255}
+}
+
+func (t *fakeTurtle) Forward(dist float64) error {
+	rad := t.angle * math.Pi / 180
+	x, y := t.x+dist*math.Cos(rad), t.y+dist*math.Sin(rad)
+	if t.penDown {
+		t.lines = append(t.lines, [4]float64{t.x, t.y, x, y})
+	}
+	t.x, t.y = x, y
+	return nil
+}
+
+func (t *fakeTurtle) Back(dist float64) error { return t.Forward(-dist) }
+func (t *fakeTurtle) Left(angle float64)      { t.angle += angle }
+func (t *fakeTurtle) Right(angle float64)     { t.angle -= angle }
+func (t *fakeTurtle) PenUp()                  { t.penDown = false }
+func (t *fakeTurtle) PenDown()                { t.penDown = true }
+func (t *fakeTurtle) ShowTurtle()             { t.hidden = false }
+func (t *fakeTurtle) HideTurtle()             { t.hidden = true }
+func (t *fakeTurtle) Clear() {
+	t.x, t.y, t.angle = 0, 0, 0
+	t.penDown = true
+	t.lines = nil
+}
+func (t *fakeTurtle) SetAngle(angle float64)              { t.angle = angle }
+func (t *fakeTurtle) SetPenSize(size uint)                { t.size = size }
+func (t *fakeTurtle) SetForegroundColor(r, g, b, a uint8) { t.r, t.g, t.b, t.a = r, g, b, a }
+func (t *fakeTurtle) SetBackgroundColor(r, g, b, a uint8) {}
+func (t *fakeTurtle) GetForegroundColor() (uint8, uint8, uint8, uint8) {
+	return t.r, t.g, t.b, t.a
+}
+func (t *fakeTurtle) GetX() float64                        { return t.x }
+func (t *fakeTurtle) GetY() float64                        { return t.y }
+func (t *fakeTurtle) GetAngle() float64                    { return t.angle }
+func (t *fakeTurtle) GetPenSize() uint                     { return t.size }
+func (t *fakeTurtle) GetTurtleVisibility() bool            { return !t.hidden }
+func (t *fakeTurtle) PrintLabel(label string) error        { t.labels = append(t.labels, label); return nil }
+func (t *fakeTurtle) BucketFill() error                    { return nil }
+func (t *fakeTurtle) Filled(r, g, b, a uint8, body func()) { body() }
+func (t *fakeTurtle) SavePicture(w io.Writer, format string) error {
+	_, err := io.WriteString(w, format)
+	return err
+}
+func (t *fakeTurtle) SetPaper(spec string) error {
+	if spec == "" || strings.HasPrefix(spec, "bad") {
+		return errors.New("unknown paper")
+	}
+	t.paper = spec
+	return nil
+}
+func (t *fakeTurtle) LoadPicture(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	t.labels = append(t.labels, "picture "+string(data))
+	return err
+}
+
+func run(t *testing.T, src string) (string, error) {
+	t.Helper()
+	var out strings.Builder
+	in := New(&out, newFakeTurtle())
+	err := in.RunString("test", src)
+	return out.String(), err
+}
+
+func expectOutput(t *testing.T, src, want string) {
+	t.Helper()
+	got, err := run(t, src)
+	if err != nil {
+		t.Errorf("Running %q failed: %v", src, err)
+		return
+	}
+	if got != want {
+		t.Errorf("Expected output %q for %q, got %q", want, src, got)
+	}
+}
+
+func expectError(t *testing.T, src, want string) {
+	t.Helper()
+	_, err := run(t, src)
+	if err == nil || err.Error() != want {
+		t.Errorf("Expected error %q for %q, got %v", want, src, err)
+	}
+}
+
+// TestArithmetic tests infix operators, precedence and number printing
+func TestArithmetic(t *testing.T) {
+	expectOutput(t, "print 1 + 2 * 3", "7\n")
+	expectOutput(t, "print (sum 1 2 3 4)", "10\n")
+	expectOutput(t, "print 7 / 2", "3.5\n")
+	expectOutput(t, "print -3 - -4", "1\n")
+	expectOutput(t, "print 2 < 3", "true\n")
+	expectOutput(t, "print \"abc = \"ABC", "true\n")
+	expectOutput(t, "print [1 2] = [1 2.0]", "true\n")
+	expectOutput(t, "print remainder -7 2 print modulo -7 2", "-1\n1\n")
+}
+
+// TestPrinting tests PRINT, TYPE and SHOW on words and lists
+func TestPrinting(t *testing.T) {
+	expectOutput(t, "print [a [b c] d]", "a [b c] d\n")
+	expectOutput(t, "show [a [b c] d]", "[a [b c] d]\n")
+	expectOutput(t, "type \"a type \"b print \"c", "abc\n")
+	expectOutput(t, "(print \"a [b] 3)", "a b 3\n")
+}
+
+// TestVariables tests MAKE, THING, NAMEP and dynamic scope
+func TestVariables(t *testing.T) {
+	expectOutput(t, "make \"x 3 print :x + 1 print thing \"x", "4\n3\n")
+	expectOutput(t, "print namep \"x make \"x 1 print namep \"x", "false\ntrue\n")
+
+	src := `
+to outer :x
+local "y
+make "y 2
+inner
+print :y
+end
+to inner
+print :x
+make "y :y + 1
+make "z 10
+end
+make "x 99
+outer 1
+print :x
+print :z
+`
+	expectOutput(t, src, "1\n3\n99\n10\n")
+
+	src = `
+to f
+localmake "v 5
+g
+end
+to g
+print :v
+end
+f
+`
+	expectOutput(t, src, "5\n")
+	expectError(t, "to f\nlocal \"v\nprint :v\nend\nf", "test:3:7: v has no value in f")
+}
+
+// TestProcedures tests OUTPUT, STOP, .MAYBEOUTPUT and optional inputs
+func TestProcedures(t *testing.T) {
+	src := `
+to square :x
+output :x * :x
+end
+print square 7 + 1
+print square :x-1
+`
+	expectOutput(t, "make \"x 4\n"+src, "64\n9\n")
+
+	src = `
+to early
+print "a
+stop
+print "b
+end
+early
+`
+	expectOutput(t, src, "a\n")
+
+	src = `
+to opt :a [:b :a * 2] [:more]
+print :b
+show :more
+end
+opt 1
+(opt 1 5 6 7)
+`
+	expectOutput(t, src, "2\n[]\n5\n[6 7]\n")
+
+	src = `
+to maybe :x
+.maybeoutput thing "x
+end
+to nothing
+end
+to pass
+.maybeoutput nothing
+end
+print maybe 3
+pass
+`
+	expectOutput(t, src, "3\n")
+}
+
+// TestIf tests IF and IFELSE as commands and as operations
+func TestIf(t *testing.T) {
+	expectOutput(t, "if 1 < 2 [print \"yes]", "yes\n")
+	expectOutput(t, "if 1 > 2 [print \"yes]", "")
+	expectOutput(t, "ifelse 1 > 2 [print \"yes] [print \"no]", "no\n")
+	expectOutput(t, "print ifelse \"true [1] [2]", "1\n")
+	expectOutput(t, "(if \"false [print 1] [print 2])", "2\n")
+	expectError(t, "if \"maybe [print 1]", "test:1:1: if doesn't like maybe as input")
+}
+
+// TestTailCalls tests that tail calls in command position, after
+// OUTPUT and in IF branches reuse the caller's frame
+func TestTailCalls(t *testing.T) {
+	src := `
+to loop :n
+if :n = 0 [stop]
+loop :n - 1
+end
+to tally :n :acc
+if :n = 0 [output :acc]
+output tally :n - 1 :acc + 1
+end
+to down :n
+ifelse :n = 0 [print "done] [down :n - 1]
+end
+to even :n
+output ifelse :n = 0 ["true] [odd :n - 1]
+end
+to odd :n
+output ifelse :n = 0 ["false] [even :n - 1]
+end
+`
+	var out strings.Builder
+	in := New(&out, nil)
+	if err := in.RunString("test", src); err != nil {
+		t.Fatalf("Defining procedures failed: %v", err)
+	}
+
+	// Without tail calls each level would take several Go frames, far
+	// more than this limit allows for a million levels.
+	defer debug.SetMaxStack(debug.SetMaxStack(8 << 20))
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	for _, instr := range []string{
+		"loop 1000000",
+		"print tally 1000000 0",
+		"down 1000000",
+		"print even 1000001",
+	} {
+		if err := in.RunString("test", instr); err != nil {
+			t.Errorf("%s failed: %v", instr, err)
+		}
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if growth := int64(after.HeapAlloc) - int64(before.HeapAlloc); growth > 1<<20 {
+		t.Errorf("Expected constant memory, heap grew by %d bytes", growth)
+	}
+	if want := "1000000\ndone\nfalse\n"; out.String() != want {
+		t.Errorf("Expected output %q, got %q", want, out.String())
+	}
+}
+
+// TestTailCallErrors tests that tail calls report the same errors as
+// nested calls would
+func TestTailCallErrors(t *testing.T) {
+	src := `
+to value
+output 1
+end
+to command
+print "c
+end
+to f
+value
+end
+to g
+output command
+end
+`
+	expectError(t, src+"f", "test:9:1: You don't say what to do with 1 in f")
+	expectError(t, src+"print g", "test:12:8: command didn't output to output in g")
+	expectOutput(t, src+"to h\noutput value\nend\nprint h", "1\n")
+}
+
+// TestDefine tests DEFINE, TEXT and FULLTEXT
+func TestDefine(t *testing.T) {
+	expectOutput(t, "define \"twice [[x] [output 2 * :x]] print twice 4", "8\n")
+	expectOutput(t, "define \"twice [[x] [output 2 * :x]] show text \"twice", "[[x] [output 2 * :x]]\n")
+	expectOutput(t, "to hi\nprint \"hi\nend\nshow fulltext \"hi", "[to hi print \"hi end]\n")
+	expectError(t, "define \"print [[] [stop]]", "test:1:1: print is a primitive")
+}
+
+// TestErrors tests the messages for common run-time mistakes
+func TestErrors(t *testing.T) {
+	expectError(t, "print :nope", "test:1:7: nope has no value")
+	expectError(t, "sum 1 2", "test:1:1: You don't say what to do with 3")
+	expectError(t, "to f\nend\nprint f", "test:3:7: f didn't output to print")
+	expectError(t, "print \"a + 1", "test:1:10: sum doesn't like a as input")
+	expectError(t, "output 1", "test:1:1: Can only use output inside a procedure")
+	expectError(t, "to f\nfoo\nend\nf", "test:2:1: I don't know how to foo in f")
+}
+
+// TestTurtle tests that graphics primitives drive the turtle with Logo
+// headings
+func TestTurtle(t *testing.T) {
+	ft := newFakeTurtle()
+	var out strings.Builder
+	in := New(&out, ft)
+	err := in.RunString("test", `
+fd 100 rt 90 fd 50
+print heading
+print round xcor print round ycor
+setxy 0 0
+setheading 180
+print heading
+pu fd 10 pd
+print round ycor
+`)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if want := "90\n50\n100\n180\n-10\n"; out.String() != want {
+		t.Errorf("Expected output %q, got %q", want, out.String())
+	}
+	if len(ft.lines) != 3 {
+		t.Errorf("Expected 3 lines drawn, got %d", len(ft.lines))
+	}
+}
+
+// TestTurtleScreen runs drawing instructions on a real turtle, started as
+// the command line starts it, and checks that they leave visible pixels
+func TestTurtleScreen(t *testing.T) {
+	black := color.RGBA{0, 0, 0, 255}
+	tests := []struct {
+		src  string
+		bg   color.RGBA
+		drew bool
+	}{
+		{"fd 50", black, true},
+		{"pu fd 50", black, false},
+		{"cs fd 50", black, true},
+		{"cs cs", black, false},
+		{"cs cs fd 50", black, true},
+		{"setbg 4 cs cs", color.RGBA{255, 0, 0, 255}, false},
+	}
+	for _, tt := range tests {
+		r := turtle.NewRaster(100, 100)
+		tu := turtle.NewTurtle(r)
+		tu.Clear()
+		var out strings.Builder
+		if err := New(&out, tu).RunString("test", tt.src); err != nil {
+			t.Fatalf("%s: %v", tt.src, err)
+		}
+		img := r.Image()
+		bg, drawn := 0, 0
+		for i := 0; i < len(img.Pix); i += 4 {
+			c := color.RGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]}
+			switch {
+			case c == tt.bg:
+				bg++
+			case c.A == 255:
+				drawn++
+			}
+		}
+		if bg+drawn != 100*100 {
+			t.Errorf("%s: expected every pixel opaque, %d are not", tt.src, 100*100-bg-drawn)
+		}
+		if tt.drew && drawn < 50 || !tt.drew && drawn != 0 {
+			t.Errorf("%s: expected drawing %v, got %d pixels drawn", tt.src, tt.drew, drawn)
+		}
+	}
+}
+
+// TestCatch tests CATCH, THROW and ERROR
+func TestCatch(t *testing.T) {
+	expectOutput(t, "print catch \"x [(throw \"x 5) print 1]", "5\n")
+	expectOutput(t, "catch \"X [print 1 throw \"x print 2] print 3", "1\n3\n")
+	expectOutput(t, "catch \"error [print :nope] show error show error", "[11 nope has no value [] test:1:21]\n[]\n")
+	expectOutput(t, "catch \"error [(throw \"error [oops])] show error", "[21 oops [] test:1:16]\n")
+	expectOutput(t, "catch \"error [apply \"nope []] print first error", "13\n")
+
+	src := `
+to f
+catch "x [g]
+print "after
+end
+to g
+throw "x
+print "never
+end
+f
+`
+	expectOutput(t, src, "after\n")
+
+	src = `
+to f
+output catch "x [output g]
+end
+to g
+(throw "x 7)
+end
+print f
+`
+	expectOutput(t, src, "7\n")
+	expectError(t, "throw \"nowhere", "test:1:1: Can't find catch tag for nowhere")
+	expectOutput(t, "throw \"toplevel", "")
+}
+
+// TestErract tests that ERRACT runs when an error is not caught
+func TestErract(t *testing.T) {
+	expectOutput(t, "make \"erract [7] print :nope + 1", "test:1:24: nope has no value\n8\n")
+	expectOutput(t, "make \"erract [print \"handled] print :nope\nprint 2", "test:1:37: nope has no value\nhandled\n2\n")
+	expectOutput(t, "make \"erract [7] catch \"error [print :nope] print 1", "1\n")
+	expectOutput(t, "make \"erract [7] print (apply \"nope []) + 1", "test:1:25: I don't know how to nope\n8\n")
+}
+
+// TestPause tests PAUSE and CONTINUE, at a call and on an error
+func TestPause(t *testing.T) {
+	var out strings.Builder
+	in := New(&out, nil)
+	in.SetInput(strings.NewReader("print :x\nprint :y\n(continue 4)\n(co 2)\n"))
+	err := in.RunString("test", `
+to f :x
+print pause + 1
+end
+f 3
+make "erract [pause]
+print :nope * 3
+`)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want := "f? 3\nf? pause:1:7: y has no value in f\nf? 5\ntest:7:7: nope has no value\n? 6\n"
+	if out.String() != want {
+		t.Errorf("Expected output %q, got %q", want, out.String())
+	}
+	expectError(t, "continue", "test:1:1: Can only use continue inside a pause")
+}
+
+// TestWordsAndLists tests the data primitives on words, lists and arrays
+func TestWordsAndLists(t *testing.T) {
+	expectOutput(t, "print first \"hello print last [a b c] print first {x y}@0", "h\nc\n0\n")
+	expectOutput(t, "show bf [a b c] show bl \"hello show bf {a b c}", "[b c]\nhell\n{b c}\n")
+	expectOutput(t, "print item 2 \"abc print item 3 [a b c] print item 0 {x y}@0", "b\nc\nx\n")
+	expectOutput(t, "show fput \"a [b c] show lput \"d [b c] show fput \"a \"bc", "[a b c]\n[b c d]\nabc\n")
+	expectOutput(t, "show (sentence \"a [b [c]] [] \"d) show (list \"a [b]) show (word \"a 1 \"c)", "[a b [c] d]\n[a [b]]\na1c\n")
+	expectOutput(t, "print count \"hello print count [a [b c]] print count {1 2 3}", "5\n2\n3\n")
+	expectOutput(t, "print emptyp [] print emptyp \"|| print empty? [a]", "true\ntrue\nfalse\n")
+	expectOutput(t, "print (list wordp \"a wordp 3 wordp [a] listp [a] list? \"a numberp \"a number? 3.5 numberp [1])",
+		"true true false true false false true false\n")
+	expectOutput(t, "print memberp \"B [a b c] print memberp \"l \"hello print memberp [b] [a b]", "true\ntrue\nfalse\n")
+	expectOutput(t, "show member \"b [a b c] show member \"l \"hello show member \"z [a]", "[b c]\nllo\n[]\n")
+	expectOutput(t, "show remove \"a [a b a c] show remove \"l \"hello", "[b c]\nheo\n")
+	expectOutput(t, "show reverse [a [b c] d] show reverse \"abc show reverse {1 2}", "[d [b c] a]\ncba\n{2 1}\n")
+	expectOutput(t, "print memberp pick [a b c] [a b c] print (first 123) + 1", "true\n2\n")
+	expectError(t, "print first []", "test:1:7: first doesn't like [] as input")
+	expectError(t, "print item 4 [a b c]", "test:1:7: item doesn't like 4 as input")
+	expectError(t, "print fput \"ab \"cd", "test:1:7: fput doesn't like ab as input")
+}
+
+// TestArrays tests array primitives, literals and shared mutation
+func TestArrays(t *testing.T) {
+	expectOutput(t, "show array 3 show (array 2 0) show {a b}@0 show listtoarray [x y]", "{[] [] []}\n{[] []}@0\n{a b}@0\n{x y}\n")
+	expectOutput(t, "make \"a {a b c} make \"b :a setitem 2 :b \"z show :a", "{a z c}\n")
+	expectOutput(t, "make \"a (array 2 0) .setitem 0 :a 5 print item 0 :a show arraytolist :a", "5\n[5 []]\n")
+
+	src := `
+make "grid (mdarray [2 3] 0)
+mdsetitem [1 2] :grid "x
+show :grid
+print mditem [1 2] :grid
+make "row item 1 :grid
+setitem 0 :row "y
+show mditem [1] :grid
+print count :grid
+`
+	expectOutput(t, src, "{{[] [] []}@0 {[] [] x}@0}@0\nx\n{y [] x}@0\n2\n")
+	expectError(t, "make \"a array 2 setitem 1 :a :a", "test:1:17: setitem doesn't like {[] []} as input")
+	expectError(t, "print item 3 {a b}", "test:1:7: item doesn't like 3 as input")
+	expectError(t, "print mditem [1 5] mdarray [2 2]", "test:1:7: mditem doesn't like [1 5] as input")
+}
+
+// TestPropertyLists tests property lists and their case-insensitive
+// names
+func TestPropertyLists(t *testing.T) {
+	expectOutput(t, "pprop \"Alice \"color \"red print gprop \"alice \"COLOR show gprop \"alice \"size", "red\n[]\n")
+	expectOutput(t, "pprop \"t \"a 1 pprop \"t \"b 2 pprop \"t \"A 3 show plist \"t", "[a 3 b 2]\n")
+	expectOutput(t, "pprop \"t \"a 1 remprop \"t \"a print plistp \"t show plist \"t", "false\n[]\n")
+	expectOutput(t, "pprops \"t [x 1 y [2 3]] show plist \"T print plistp \"t", "[x 1 y [2 3]]\ntrue\n")
+	expectOutput(t, "pprop \"b \"k \"v pprop \"a \"n 5 popls show plists erpls show plists",
+		"pprop \"a \"n 5\npprop \"b \"k \"v\n[[] [] [a b]]\n[[] [] []]\n")
+	expectError(t, "pprops \"t [x]", "test:1:1: pprops doesn't like [x] as input")
+}
+
+// TestEquality tests EQUALP, CASEIGNOREDP, .EQ and variable case
+func TestEquality(t *testing.T) {
+	expectOutput(t, "print equalp \"3.0 3 print \"abc = \"ABC print [a [b]] = [A [B]]", "true\ntrue\ntrue\n")
+	expectOutput(t, "make \"caseignoredp \"false print \"abc = \"ABC print memberp \"A [a b]", "false\nfalse\n")
+	expectOutput(t, "make \"a [x] make \"b :a print .eq :a :b print .eq :a [x] print :a = [x]", "true\nfalse\ntrue\n")
+	expectOutput(t, "make \"Foo 1 print :foo make \"FOO 2 print :Foo", "1\n2\n")
+	expectOutput(t, "to f :Side\nprint :side\nend\nf 5", "5\n")
+}
+
+// TestReading tests PARSE, RUNPARSE, READLIST, FULLPRINTP and number
+// formatting
+func TestReading(t *testing.T) {
+	expectOutput(t, "show runparse [fd :n+1 (print \"a) [x+y]] show runparse \"|print 3+4|", "[fd :n + 1 ( print \"a ) [x+y]]\n[print 3 + 4]\n")
+	expectOutput(t, "show parse \"|a [b c] {d}@0 1.50|", "[a [b c] {d}@0 1.50]\n")
+	expectOutput(t, "make \"l [print :x*-2] print equalp runparse :l runparse runparse :l", "true\n")
+	expectOutput(t, "show (list \"|a b| \"||) make \"fullprintp \"true show (list \"|a b| \"|| \"c) print \"|a b|", "[a b ]\n[|a b| || c]\n|a b|\n")
+	expectOutput(t, "print 1 / 3 print power 2 70 print 0.1 + 0.2 print 1e-7 print 2.50", "0.333333333333333\n1.18059162071741e+21\n0.3\n1e-07\n2.5\n")
+	expectOutput(t, "(type \"a \"b [c d]) print \"||", "abc d\n")
+
+	var out strings.Builder
+	in := New(&out, nil)
+	in.SetInput(strings.NewReader("a [b c] 1.50\n"))
+	if err := in.RunString("test", "show readlist show readlist"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if want := "[a [b c] 1.50]\n\n"; out.String() != want {
+		t.Errorf("Expected output %q, got %q", want, out.String())
+	}
+}
+
+// TestTemplates tests the higher-order primitives with procedure names,
+// explicit-slot templates and lambdas
+func TestTemplates(t *testing.T) {
+	expectOutput(t, "to double :x\noutput :x * 2\nend\nshow map \"double [1 2 3] show map [? * 2] [1 2 3] show map [[x] :x * 2] [1 2 3]", "[2 4 6]\n[2 4 6]\n[2 4 6]\n")
+	expectOutput(t, "show (map [?1 + ?2] [1 2] [10 20]) show map [word ? #] \"abc show map.se [list ? ?] [a b] show map \"FIRST {ab cd}", "[11 22]\na1b2c3\n[a a b b]\n{a c}\n")
+	expectOutput(t, "show filter [? > 2] [1 3 2 4] show filter \"emptyp [a [] b] show find [[w] (count :w) = 2] [abc de f] show find \"emptyp [[a]]", "[3 4]\n[[]]\nde\n[]\n")
+	expectOutput(t, "show reduce \"sum [1 2 3] show reduce [word ?2 ?1] [a b c] show reduce \"list [a]", "6\ncba\na\n")
+	expectOutput(t, "(foreach [a b] [1 2] [type ?1 type ?2]) print \"|| foreach [x y] [[w] type :w type #] print \"||", "a1b2\nx1y2\n")
+	expectOutput(t, "show apply \"sum [1 2 3] show apply [[a b] :a - :b] [5 3] print (invoke \"word \"a \"b) print (invoke [?1 * ?2] 3 4)", "6\n2\nab\n12\n")
+	expectOutput(t, "show runresult [sum 1 2] show runresult [make \"x 1] run [print \"hi] print run [3 + 4]", "[3]\n[]\nhi\n7\n")
+	expectOutput(t, "show crossmap [word ?1 ?2] [[a b] [1 2]] show (crossmap [list ?1 ?2] [x] [1 2])", "[a1 a2 b1 b2]\n[[x 1] [x 2]]\n")
+	expectOutput(t, "print cascade 5 [? * 2] 1 print cascade [? > 100] [? * 3] 1 print (cascade 3 [?1 + ?2] 1 [?1] 0 [?2 * 10])", "32\n243\n20\n")
+	expectOutput(t, "show transfer [] [fput ?in ?out] [a b c] show transfer [equalp ?in \"stop] [lput ?in ?out] [a b stop c]", "[c b a]\n[a b]\n")
+
+	expectError(t, "show map [[x y] :x] [1 2]", "test:1:6: not enough inputs to [[x y] :x]")
+	expectError(t, "show map \"print [1 2]", "test:1:6: print didn't output to map")
+	expectError(t, "show filter [?] [1]", "test:1:6: filter doesn't like 1 as input")
+	expectError(t, "show apply \"difference [1]", "test:1:6: not enough inputs to difference")
+	expectError(t, "show apply \"output [1]", "test:1:6: apply doesn't like output as input")
+	expectError(t, "print ?", "test:1:7: Can only use ? inside a template")
+}
+
+// TestControl tests the looping and conditional primitives
+func TestControl(t *testing.T) {
+	expectOutput(t, "repeat 3 [type repcount] print \"|| print repcount", "123\n-1\n")
+	expectOutput(t, "for [i 1 2 0.5] [type :i type \"| |] print \"||", "1 1.5 2 \n")
+	expectOutput(t, "make \"i \"outer for [i 1 3] [] print :i", "outer\n")
+	expectOutput(t, "to f\nlocal \"i\nmake \"i 7\nfor [i 1 2] []\noutput :i\nend\nprint f", "7\n")
+	expectOutput(t, "print case \"B [[[a] 1] [[b] 2]] case 3 [[[1] print \"one]]", "2\n")
+	expectOutput(t, "to f :x\nif :x > 2 [goto \"big]\noutput \"small\ntag \"big\noutput \"big\nend\nprint (list f 1 f 5)", "small big\n")
+
+	expectError(t, "iftrue [print 1]", "test:1:1: iftrue without TEST")
+	expectError(t, "repeat 2.5 [print 1]", "test:1:1: repeat doesn't like 2.5 as input")
+	expectError(t, "for [i 1] [print :i]", "test:1:1: for doesn't like [i 1] as input")
+	expectError(t, "while [1] [print 1]", "test:1:1: while doesn't like 1 as input")
+	expectError(t, "goto \"top", "test:1:1: Can only use goto inside a procedure")
+	expectError(t, "to f\ngoto \"nowhere\nend\nf", "test:2:1: goto doesn't like nowhere as input in f")
+	expectError(t, "repeat 2 [3]", "test:1:11: You don't say what to do with 3")
+}
+
+// TestPrograms runs each testdata/*.lg program and compares what it
+// prints with the matching .out file
+func TestPrograms(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.lg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(strings.TrimSuffix(file, ".lg") + ".out")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			in := New(&out, newFakeTurtle())
+			if err := in.RunString(file, string(src)); err != nil {
+				t.Fatalf("Run failed: %v\noutput so far:\n%s", err, out.String())
+			}
+			if out.String() != string(want) {
+				t.Errorf("Output differs\nexpected:\n%s\ngot:\n%s", want, out.String())
+			}
+		})
+	}
+}
+
+// TestWorkspace tests listing, erasing and burying procedures,
+// variables and property lists
+func TestWorkspace(t *testing.T) {
+	defs := "to sq :n\noutput :n * :n\nend\nto hi\nprint \"hi\nend\nmake \"x [a |b c|] make \"y 3 pprop \"p \"k \"v\n"
+	expectOutput(t, defs+"show contents", "[[hi sq] [x y] [p]]\n")
+	expectOutput(t, defs+"po \"sq po [[] [x] [p]]", "to sq :n\noutput :n * :n\nend\n\nmake \"x [a |b c|]\npprop \"p \"k \"v\n")
+	expectOutput(t, defs+"pots pons", "to hi\nto sq :n\nmake \"x [a |b c|]\nmake \"y 3\n")
+	expectOutput(t, defs+"bury [[sq] [y] [p]] show contents show buried print buriedp \"sq print buriedp [[] [y]]",
+		"[[hi] [x] []]\n[[sq] [y] [p]]\ntrue\ntrue\n")
+	expectOutput(t, defs+"bury [[sq] [y]] make \"y 4 print buriedp [[] [y]] erall show contents print :y print sq 2 unbury \"sq print buriedp \"sq",
+		"true\n[[] [] []]\n4\n4\nfalse\n")
+	expectOutput(t, defs+"erase [[hi] [x]] show contents erns erps erpls show contents", "[[sq] [y] [p]]\n[[] [] []]\n")
+
+	expectError(t, "po \"print", "test:1:1: print is a primitive")
+	expectError(t, "erase \"nothing", "test:1:1: I don't know how to nothing")
+	expectError(t, "load \"/nonexistent/file.lg", "test:1:1: open /nonexistent/file.lg: no such file or directory")
+}
+
+// TestSaveLoad tests that SAVE writes a workspace LOAD reads back,
+// leaving out what is buried
+func TestSaveLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ws.lg")
+	var out strings.Builder
+	in := New(&out, nil)
+	src := "to greet [:who \"world] 0\nprint sentence \"hello :who\nend\n" +
+		"make \"words [a |b c| {1 2}] make \"name \"|two words| make \"secret 1 bury [[] [secret]]\n" +
+		"pprop \"cat \"sound \"meow\n" +
+		"save \"" + file + "\nerall erase [[] [secret]]\nshow contents\nload \"" + file + "\n" +
+		"show contents greet show :words print count :words print :name print gprop \"cat \"sound"
+	if err := in.RunString("test", src); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want := "[[] [] []]\n[[greet] [name words] [cat]]\nhello world\n[a b c {1 2}]\n3\ntwo words\nmeow\n"
+	if out.String() != want {
+		t.Errorf("Expected output %q, got %q", want, out.String())
+	}
+}
+
+// TestPictures tests that SAVEPICT picks the format from the file name,
+// SVGPICT and EPSPICT always write theirs, SETPAPER passes the paper on
+// and LOADPICT hands the file to the turtle
+func TestPictures(t *testing.T) {
+	dir := t.TempDir()
+	var out strings.Builder
+	ft := newFakeTurtle()
+	in := New(&out, ft)
+	src := "savepict \"" + filepath.Join(dir, "a.PPM") + "\nloadpict \"" + filepath.Join(dir, "a.PPM")
+	if err := in.RunString("test", src); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(ft.labels) != 1 || ft.labels[0] != "picture ppm" {
+		t.Errorf("Expected the saved picture loaded, got %q", ft.labels)
+	}
+
+	expectError := func(src, want string) {
+		t.Helper()
+		err := in.RunString("test", src)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected error %q, got %v", src, want, err)
+		}
+	}
+	ft.labels = nil
+	src = "savepict \"" + filepath.Join(dir, "b.svg") + "\nsvgpict \"" + filepath.Join(dir, "c.txt") +
+		"\nloadpict \"" + filepath.Join(dir, "b.svg") + "\nloadpict \"" + filepath.Join(dir, "c.txt")
+	if err := in.RunString("test", src); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(ft.labels) != 2 || ft.labels[0] != "picture svg" || ft.labels[1] != "picture svg" {
+		t.Errorf("Expected SVG pictures, got %q", ft.labels)
+	}
+
+	ft.labels = nil
+	src = "savepict \"" + filepath.Join(dir, "d.pdf") + "\nepspict \"" + filepath.Join(dir, "e.ps") +
+		"\nloadpict \"" + filepath.Join(dir, "d.pdf") + "\nloadpict \"" + filepath.Join(dir, "e.ps")
+	if err := in.RunString("test", src); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(ft.labels) != 2 || ft.labels[0] != "picture pdf" || ft.labels[1] != "picture eps" {
+		t.Errorf("Expected PDF and EPS pictures, got %q", ft.labels)
+	}
+
+	if err := in.RunString("test", "setpaper \"letter"); err != nil || ft.paper != "letter" {
+		t.Errorf("Expected letter paper, got %q (%v)", ft.paper, err)
+	}
+	if err := in.RunString("test", "setpaper [a3 18]"); err != nil || ft.paper != "a3 18" {
+		t.Errorf("Expected a3 paper, got %q (%v)", ft.paper, err)
+	}
+	expectError("setpaper \"bad", "setpaper doesn't like bad as input")
+	expectError("setpaper [[a4]]", "setpaper doesn't like [[a4]] as input")
+	expectError("savepict \"pic.gif", "savepict doesn't like pic.gif as input")
+	expectError("savepict \"noext", "savepict doesn't like noext as input")
+	expectError("loadpict \""+filepath.Join(dir, "missing.png"), "no such file")
+}
@@ -0,0 +1,529 @@
+package eval
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gortle/internal/logoerr"
+	"gortle/internal/thing"
+)
+
+// palette holds the standard UCBLogo colors, numbered 0 to 15.
+var palette = [][3]uint8{
+	{0, 0, 0},       // black
+	{0, 0, 255},     // blue
+	{0, 255, 0},     // green
+	{0, 255, 255},   // cyan
+	{255, 0, 0},     // red
+	{255, 0, 255},   // magenta
+	{255, 255, 0},   // yellow
+	{255, 255, 255}, // white
+	{155, 96, 59},   // brown
+	{197, 136, 18},  // tan
+	{100, 162, 64},  // forest
+	{120, 187, 187}, // aqua
+	{255, 149, 119}, // salmon
+	{144, 113, 208}, // purple
+	{255, 163, 0},   // orange
+	{183, 183, 183}, // grey
+}
+
+func (in *Interp) addGraphics() {
+	in.prim("forward fd", 1, 1, 1, turtleMove(Turtle.Forward))
+	in.prim("back bk", 1, 1, 1, turtleMove(Turtle.Back))
+	in.prim("left lt", 1, 1, 1, turtleTurn(Turtle.Left))
+	in.prim("right rt", 1, 1, 1, turtleTurn(Turtle.Right))
+	in.prim("penup pu", 0, 0, 0, turtleCmd(Turtle.PenUp))
+	in.prim("pendown pd", 0, 0, 0, turtleCmd(Turtle.PenDown))
+	in.prim("showturtle st", 0, 0, 0, turtleCmd(Turtle.ShowTurtle))
+	in.prim("hideturtle ht", 0, 0, 0, turtleCmd(Turtle.HideTurtle))
+	in.prim("fill", 0, 0, 0, primFill)
+	in.prim("home", 0, 0, 0, primHome)
+	in.prim("clearscreen cs", 0, 0, 0, primClearscreen)
+
+	in.prim("setxy", 2, 2, 2, primSetxy)
+	in.prim("setpos", 1, 1, 1, primSetpos)
+	in.prim("setx", 1, 1, 1, primSetx)
+	in.prim("sety", 1, 1, 1, primSety)
+	in.prim("setheading seth", 1, 1, 1, primSetheading)
+	in.prim("pos", 0, 0, 0, primPos)
+	in.prim("xcor", 0, 0, 0, primXcor)
+	in.prim("ycor", 0, 0, 0, primYcor)
+	in.prim("heading", 0, 0, 0, primHeading)
+	in.prim("towards", 1, 1, 1, primTowards)
+	in.prim("shownp shown?", 0, 0, 0, primShownp)
+
+	in.prim("setpencolor setpc", 1, 1, 1, primSetpencolor)
+	in.prim("setbackground setbg", 1, 1, 1, primSetbackground)
+	in.prim("pencolor pc", 0, 0, 0, primPencolor)
+	in.prim("setpensize", 1, 1, 1, primSetpensize)
+	in.prim("pensize", 0, 0, 0, primPensize)
+	in.prim("label", 1, 1, 1, primLabel)
+	in.prim("filled", 2, 2, 2, primFilled)
+	in.prim("savepict", 1, 1, 1, primSavepict)
+	in.prim("svgpict", 1, 1, 1, primSvgpict)
+	in.prim("epspict", 1, 1, 1, primEpspict)
+	in.prim("setpaper", 1, 1, 1, primSetpaper)
+	in.prim("loadpict", 1, 1, 1, primLoadpict)
+}
+
+func (c *call) turtle() (Turtle, error) {
+	if c.in.turtle == nil {
+		return nil, c.errorf(logoerr.ERR_Graphics, "%s needs turtle graphics", c.name)
+	}
+	return c.in.turtle, nil
+}
+
+func turtleCmd(fn func(Turtle)) func(c *call) (*thing.Thing, error) {
+	return func(c *call) (*thing.Thing, error) {
+		t, err := c.turtle()
+		if err != nil {
+			return nil, err
+		}
+		fn(t)
+		return nil, nil
+	}
+}
+
+func turtleMove(fn func(Turtle, float64) error) func(c *call) (*thing.Thing, error) {
+	return func(c *call) (*thing.Thing, error) {
+		t, err := c.turtle()
+		if err != nil {
+			return nil, err
+		}
+		n, err := c.number(0)
+		if err != nil {
+			return nil, err
+		}
+		return nil, c.check(fn(t, n))
+	}
+}
+
+func turtleTurn(fn func(Turtle, float64)) func(c *call) (*thing.Thing, error) {
+	return func(c *call) (*thing.Thing, error) {
+		t, err := c.turtle()
+		if err != nil {
+			return nil, err
+		}
+		n, err := c.number(0)
+		if err != nil {
+			return nil, err
+		}
+		fn(t, n)
+		return nil, nil
+	}
+}
+
+// check places an error reported by the turtle at the call.
+func (c *call) check(err error) error {
+	if err == nil {
+		return nil
+	}
+	return c.in.locate(err, c.node)
+}
+
+// heading converts the turtle's angle to a Logo heading: degrees
+// clockwise from north.
+func heading(t Turtle) float64 {
+	h := math.Mod(90-t.GetAngle(), 360)
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// moveTo moves the turtle in a straight line, drawing if the pen is
+// down, and leaves its heading unchanged.
+func moveTo(t Turtle, x, y float64) error {
+	dx, dy := x-t.GetX(), y-t.GetY()
+	if dx == 0 && dy == 0 {
+		return nil
+	}
+	angle := t.GetAngle()
+	t.SetAngle(math.Atan2(dy, dx) * 180 / math.Pi)
+	err := t.Forward(math.Hypot(dx, dy))
+	t.SetAngle(angle)
+	return err
+}
+
+func primFill(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.check(t.BucketFill())
+}
+
+func primHome(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	err = moveTo(t, 0, 0)
+	t.SetAngle(90)
+	return nil, c.check(err)
+}
+
+func primClearscreen(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	t.Clear()
+	t.SetAngle(90)
+	return nil, nil
+}
+
+func primSetxy(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	x, y, err := c.numbers()
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.check(moveTo(t, x, y))
+}
+
+// point reads an [x y] list input.
+func (c *call) point(i int) (x, y float64, err error) {
+	lst, err := c.list(i)
+	if err != nil || len(lst) != 2 {
+		return 0, 0, c.badInput(i)
+	}
+	x, xok := lst[0].Number()
+	y, yok := lst[1].Number()
+	if !xok || !yok {
+		return 0, 0, c.badInput(i)
+	}
+	return x, y, nil
+}
+
+func primSetpos(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	x, y, err := c.point(0)
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.check(moveTo(t, x, y))
+}
+
+func primSetx(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	x, err := c.number(0)
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.check(moveTo(t, x, t.GetY()))
+}
+
+func primSety(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	y, err := c.number(0)
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.check(moveTo(t, t.GetX(), y))
+}
+
+func primSetheading(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	h, err := c.number(0)
+	if err != nil {
+		return nil, err
+	}
+	t.SetAngle(90 - h)
+	return nil, nil
+}
+
+func primPos(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	return thing.New(thing.TList{number(t.GetX()), number(t.GetY())}, thing.TagTList, false), nil
+}
+
+func primXcor(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	return number(t.GetX()), nil
+}
+
+func primYcor(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	return number(t.GetY()), nil
+}
+
+func primHeading(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	return number(heading(t)), nil
+}
+
+func primTowards(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	x, y, err := c.point(0)
+	if err != nil {
+		return nil, err
+	}
+	h := math.Mod(90-math.Atan2(y-t.GetY(), x-t.GetX())*180/math.Pi, 360)
+	if h < 0 {
+		h += 360
+	}
+	return number(h), nil
+}
+
+func primShownp(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	return boolean(t.GetTurtleVisibility()), nil
+}
+
+// color reads a palette number or an [r g b] or [r g b a] list with
+// components from 0 to 255.
+func (c *call) color(i int) (r, g, b, a uint8, err error) {
+	if n, ok := c.args[i].Number(); ok {
+		if n != math.Trunc(n) || n < 0 || int(n) >= len(palette) {
+			return 0, 0, 0, 0, c.badInput(i)
+		}
+		rgb := palette[int(n)]
+		return rgb[0], rgb[1], rgb[2], 255, nil
+	}
+	lst, err := c.list(i)
+	if err != nil || len(lst) < 3 || len(lst) > 4 {
+		return 0, 0, 0, 0, c.badInput(i)
+	}
+	rgba := [4]uint8{255, 255, 255, 255}
+	for j, elt := range lst {
+		n, ok := elt.Number()
+		if !ok || n < 0 || n > 255 {
+			return 0, 0, 0, 0, c.badInput(i)
+		}
+		rgba[j] = uint8(n)
+	}
+	return rgba[0], rgba[1], rgba[2], rgba[3], nil
+}
+
+func primSetpencolor(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	r, g, b, a, err := c.color(0)
+	if err != nil {
+		return nil, err
+	}
+	t.SetForegroundColor(r, g, b, a)
+	return nil, nil
+}
+
+func primSetbackground(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	r, g, b, a, err := c.color(0)
+	if err != nil {
+		return nil, err
+	}
+	t.SetBackgroundColor(r, g, b, a)
+	return nil, nil
+}
+
+// primPencolor outputs the palette number of the pen color if it has
+// one, and an [r g b] list otherwise.
+func primPencolor(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	r, g, b, _ := t.GetForegroundColor()
+	for i, rgb := range palette {
+		if rgb == [3]uint8{r, g, b} {
+			return number(float64(i)), nil
+		}
+	}
+	return thing.New(thing.TList{number(float64(r)), number(float64(g)), number(float64(b))}, thing.TagTList, false), nil
+}
+
+func primSetpensize(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	n, err := c.number(0)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, c.badInput(0)
+	}
+	t.SetPenSize(uint(n))
+	return nil, nil
+}
+
+func primPensize(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	return number(float64(t.GetPenSize())), nil
+}
+
+func primLabel(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.check(t.PrintLabel(printed(c.args[0])))
+}
+
+// primFilled runs its instructions and fills the shape they trace.
+func primFilled(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	r, g, b, a, err := c.color(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.list(1); err != nil {
+		return nil, err
+	}
+	var runErr error
+	t.Filled(r, g, b, a, func() {
+		_, runErr = c.in.runList(c.args[1], modeCommand, false)
+	})
+	return nil, runErr
+}
+
+// pictFormats are the file types SAVEPICT can write, by extension.
+var pictFormats = map[string]bool{
+	"png": true, "bmp": true, "ppm": true, "svg": true, "eps": true, "pdf": true,
+}
+
+// primSavepict writes the drawing to a file, in the format its extension
+// names.
+func primSavepict(c *call) (*thing.Thing, error) {
+	name, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if !pictFormats[format] {
+		return nil, c.badInput(0)
+	}
+	return nil, savePicture(c, name, format)
+}
+
+// primSvgpict writes the drawing to a file as SVG, whatever the file is
+// called.
+func primSvgpict(c *call) (*thing.Thing, error) {
+	name, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	return nil, savePicture(c, name, "svg")
+}
+
+// primEpspict writes the drawing to a file as Encapsulated PostScript,
+// whatever the file is called.
+func primEpspict(c *call) (*thing.Thing, error) {
+	name, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	return nil, savePicture(c, name, "eps")
+}
+
+// primSetpaper sets the paper EPS and PDF pictures are fitted to: a name
+// such as "a4 or "letter, or a list of a width and height in points,
+// either followed by a margin in points.
+func primSetpaper(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	spec, ok := c.args[0].Word()
+	if !ok {
+		lst, err := c.list(0)
+		if err != nil {
+			return nil, err
+		}
+		words := make([]string, len(lst))
+		for i, elt := range lst {
+			if words[i], ok = elt.Word(); !ok {
+				return nil, c.badInput(0)
+			}
+		}
+		spec = strings.Join(words, " ")
+	}
+	if err := t.SetPaper(spec); err != nil {
+		return nil, c.badInput(0)
+	}
+	return nil, nil
+}
+
+func savePicture(c *call, name, format string) error {
+	t, err := c.turtle()
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return c.errorf(logoerr.ERR_FileSystem, "%v", err)
+	}
+	err = c.check(t.SavePicture(f, format))
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = c.errorf(logoerr.ERR_FileSystem, "%v", cerr)
+	}
+	return err
+}
+
+// primLoadpict draws a PNG, BMP or PPM file in the middle of the
+// screen.
+func primLoadpict(c *call) (*thing.Thing, error) {
+	t, err := c.turtle()
+	if err != nil {
+		return nil, err
+	}
+	name, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, c.errorf(logoerr.ERR_FileSystem, "%v", err)
+	}
+	defer f.Close()
+	return nil, c.check(t.LoadPicture(f))
+}
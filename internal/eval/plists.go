@@ -0,0 +1,173 @@
+package eval
+
+import (
+	"strings"
+
+	"gortle/internal/thing"
+)
+
+// plist is the property list of one name, kept under the spelling the
+// name was first given.
+type plist struct {
+	name   string
+	props  *thing.TPropList
+	buried bool
+}
+
+func (in *Interp) addPlists() {
+	in.prim("pprop", 3, 3, 3, primPprop)
+	in.prim("gprop", 2, 2, 2, primGprop)
+	in.prim("remprop", 2, 2, 2, primRemprop)
+	in.prim("plist", 1, 1, 1, primPlist)
+	in.prim("pprops", 2, 2, 2, primPprops)
+	in.prim("plistp plist?", 1, 1, 1, primPlistp)
+	in.prim("plists", 0, 0, 0, primPlists)
+	in.prim("popls", 0, 0, 0, primPopls)
+	in.prim("erpls", 0, 0, 0, primErpls)
+}
+
+// plist finds the property list of the name at input i. With create set
+// a missing list is made; otherwise it is nil.
+func (c *call) plist(i int, create bool) (*plist, error) {
+	name, err := c.word(i)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.ToLower(name)
+	pl, ok := c.in.plists[key]
+	if !ok && create {
+		props := thing.NewPropList(false).Value().(*thing.TPropList)
+		pl = &plist{name: name, props: props}
+		c.in.plists[key] = pl
+	}
+	return pl, nil
+}
+
+func (c *call) propName(i int) (*thing.Thing, error) {
+	if _, err := c.word(i); err != nil {
+		return nil, err
+	}
+	return c.args[i], nil
+}
+
+func primPprop(c *call) (*thing.Thing, error) {
+	prop, err := c.propName(1)
+	if err != nil {
+		return nil, err
+	}
+	pl, err := c.plist(0, true)
+	if err != nil {
+		return nil, err
+	}
+	pl.props.SetPropValue(prop, c.args[2])
+	return nil, nil
+}
+
+// primGprop outputs the empty list for a property that is not set.
+func primGprop(c *call) (*thing.Thing, error) {
+	prop, err := c.propName(1)
+	if err != nil {
+		return nil, err
+	}
+	pl, err := c.plist(0, false)
+	if err != nil {
+		return nil, err
+	}
+	if pl != nil {
+		if value, err := pl.props.GetPropValue(prop); err == nil {
+			return value, nil
+		}
+	}
+	return thing.New(thing.TList{}, thing.TagTList, false), nil
+}
+
+func primRemprop(c *call) (*thing.Thing, error) {
+	prop, err := c.propName(1)
+	if err != nil {
+		return nil, err
+	}
+	pl, err := c.plist(0, false)
+	if err != nil || pl == nil {
+		return nil, err
+	}
+	pl.props.RemoveProp(prop)
+	if pl.props.Len() == 0 {
+		delete(c.in.plists, strings.ToLower(pl.name))
+	}
+	return nil, nil
+}
+
+func primPlist(c *call) (*thing.Thing, error) {
+	pl, err := c.plist(0, false)
+	if err != nil {
+		return nil, err
+	}
+	if pl == nil {
+		return thing.New(thing.TList{}, thing.TagTList, false), nil
+	}
+	return pl.props.ToList(), nil
+}
+
+// primPprops sets each property in a list of alternating names and
+// values, the form PLIST outputs.
+func primPprops(c *call) (*thing.Thing, error) {
+	lst, err := c.list(1)
+	if err != nil || len(lst)%2 != 0 {
+		return nil, c.badInput(1)
+	}
+	for i := 0; i < len(lst); i += 2 {
+		if _, ok := lst[i].Word(); !ok {
+			return nil, c.badInput(1)
+		}
+	}
+	pl, err := c.plist(0, true)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(lst); i += 2 {
+		pl.props.SetPropValue(lst[i], lst[i+1])
+	}
+	if pl.props.Len() == 0 {
+		delete(c.in.plists, strings.ToLower(pl.name))
+	}
+	return nil, nil
+}
+
+func primPlistp(c *call) (*thing.Thing, error) {
+	pl, err := c.plist(0, false)
+	if err != nil {
+		return nil, err
+	}
+	return boolean(pl != nil), nil
+}
+
+// primPlists outputs a contents list naming every property list that
+// is not buried.
+func primPlists(c *call) (*thing.Thing, error) {
+	empty := thing.New(thing.TList{}, thing.TagTList, false)
+	names := words(c.in.workspace(false).plists)
+	return thing.New(thing.TList{empty, empty, names}, thing.TagTList, false), nil
+}
+
+// primPopls prints every property list that is not buried as the PPROP
+// instructions that would rebuild it.
+func primPopls(c *call) (*thing.Thing, error) {
+	return nil, c.printContents(c.in.out, contents{plists: c.in.workspace(false).plists})
+}
+
+func primErpls(c *call) (*thing.Thing, error) {
+	return nil, c.erase(contents{plists: c.in.workspace(false).plists})
+}
+
+// quoted writes a value as an expression that outputs it: words get a
+// quote, and words and lists are escaped where they need to be to read
+// back the same.
+func quoted(t *thing.Thing) string {
+	if t.Tag() == thing.TagTNumber {
+		return show(t)
+	}
+	if _, ok := t.Word(); ok {
+		return "\"" + t.Format(false, true)
+	}
+	return t.Format(true, true)
+}
@@ -0,0 +1,452 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gortle/internal/logoerr"
+	"gortle/internal/thing"
+)
+
+// prim registers a primitive under each of the space-separated names.
+// max is -1 for primitives that take any number of inputs.
+func (in *Interp) prim(names string, min, dflt, max int, fn func(c *call) (*thing.Thing, error)) *primitive {
+	fields := strings.Fields(names)
+	p := &primitive{name: fields[0], min: min, dflt: dflt, max: max, fn: fn}
+	for _, name := range fields {
+		in.prims[name] = p
+	}
+	return p
+}
+
+func (in *Interp) addPrimitives() {
+	in.prim("make", 2, 2, 2, primMake)
+	in.prim("name", 2, 2, 2, primName)
+	in.prim("local", 1, 1, -1, primLocal)
+	in.prim("localmake", 2, 2, 2, primLocalmake)
+	in.prim("thing", 1, 1, 1, primThing)
+	in.prim("namep name?", 1, 1, 1, primNamep)
+
+	in.prim("output op", 1, 1, 1, primOutput).special = true
+	in.prim("stop", 0, 0, 0, primStop)
+	in.prim(".maybeoutput", 1, 1, 1, primMaybeoutput).special = true
+	in.prim("define", 2, 2, 2, primDefine)
+	in.prim("text", 1, 1, 1, primText)
+	in.prim("fulltext", 1, 1, 1, primFulltext)
+
+	in.prim("print pr", 0, 1, -1, primPrint)
+	in.prim("type", 0, 1, -1, primType)
+	in.prim("show", 0, 1, -1, primShow)
+
+	in.prim("sum", 0, 2, -1, primSum)
+	in.prim("difference", 2, 2, 2, primDifference)
+	in.prim("product", 0, 2, -1, primProduct)
+	in.prim("quotient", 1, 2, 2, primQuotient)
+	in.prim("remainder", 2, 2, 2, primRemainder)
+	in.prim("modulo", 2, 2, 2, primModulo)
+	in.prim("minus", 1, 1, 1, primMinus)
+	in.prim("abs", 1, 1, 1, mathFunc(math.Abs))
+	in.prim("int", 1, 1, 1, mathFunc(math.Trunc))
+	in.prim("round", 1, 1, 1, mathFunc(math.Round))
+	in.prim("sqrt", 1, 1, 1, primSqrt)
+	in.prim("power", 2, 2, 2, primPower)
+
+	in.prim("equalp equal?", 2, 2, 2, primEqualp)
+	in.prim("notequalp notequal?", 2, 2, 2, primNotequalp)
+	in.prim(".eq", 2, 2, 2, primEq)
+	in.prim("lessp less?", 2, 2, 2, compare(func(a, b float64) bool { return a < b }))
+	in.prim("greaterp greater?", 2, 2, 2, compare(func(a, b float64) bool { return a > b }))
+	in.prim("lessequalp lessequal?", 2, 2, 2, compare(func(a, b float64) bool { return a <= b }))
+	in.prim("greaterequalp greaterequal?", 2, 2, 2, compare(func(a, b float64) bool { return a >= b }))
+}
+
+func (c *call) errorf(code logoerr.Code, format string, args ...interface{}) error {
+	return c.in.errorf(c.node, code, format, args...)
+}
+
+func (c *call) badInput(i int) error {
+	return c.errorf(logoerr.ERR_BadInput, "%s doesn't like %s as input", c.name, show(c.args[i]))
+}
+
+func (c *call) number(i int) (float64, error) {
+	if n, ok := c.args[i].Number(); ok {
+		return n, nil
+	}
+	return 0, c.badInput(i)
+}
+
+func (c *call) word(i int) (string, error) {
+	if w, ok := c.args[i].Word(); ok {
+		return w, nil
+	}
+	return "", c.badInput(i)
+}
+
+func (c *call) list(i int) (thing.TList, error) {
+	if lst, ok := c.args[i].Value().(thing.TList); ok {
+		return lst, nil
+	}
+	return nil, c.badInput(i)
+}
+
+func (c *call) bool(i int) (bool, error) {
+	w, ok := c.args[i].Word()
+	switch {
+	case ok && strings.EqualFold(w, "true"):
+		return true, nil
+	case ok && strings.EqualFold(w, "false"):
+		return false, nil
+	}
+	return false, c.badInput(i)
+}
+
+func number(n float64) *thing.Thing {
+	return thing.NewNumber(thing.TNumber(n), false)
+}
+
+func boolean(b bool) *thing.Thing {
+	if b {
+		return thing.NewWord("true", false)
+	}
+	return thing.NewWord("false", false)
+}
+
+func primMake(c *call) (*thing.Thing, error) {
+	name, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	c.in.setVariable(name, c.args[1])
+	return nil, nil
+}
+
+func primName(c *call) (*thing.Thing, error) {
+	c.args[0], c.args[1] = c.args[1], c.args[0]
+	return primMake(c)
+}
+
+func primLocal(c *call) (*thing.Thing, error) {
+	for i, arg := range c.args {
+		names := thing.TList{arg}
+		if lst, ok := arg.Value().(thing.TList); ok {
+			names = lst
+		}
+		for _, name := range names {
+			word, ok := name.Word()
+			if !ok {
+				return nil, c.badInput(i)
+			}
+			c.in.makeLocal(word)
+		}
+	}
+	return nil, nil
+}
+
+func primLocalmake(c *call) (*thing.Thing, error) {
+	name, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	c.in.makeLocal(name)
+	c.in.setVariable(name, c.args[1])
+	return nil, nil
+}
+
+func primThing(c *call) (*thing.Thing, error) {
+	name, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := c.in.lookup(name)
+	if !ok || value == nil {
+		return nil, c.errorf(logoerr.ERR_NoValue, "%s has no value", name)
+	}
+	return value, nil
+}
+
+func primNamep(c *call) (*thing.Thing, error) {
+	name, ok := c.args[0].Word()
+	if !ok {
+		return boolean(false), nil
+	}
+	value, ok := c.in.lookup(name)
+	return boolean(ok && value != nil), nil
+}
+
+// primOutput evaluates its input in tail position: a procedure call
+// there is made in place of the current procedure.
+func primOutput(c *call) (*thing.Thing, error) {
+	return c.output(modeValue)
+}
+
+func primStop(c *call) (*thing.Thing, error) {
+	if c.in.frame == nil {
+		return nil, c.errorf(logoerr.ERR_OnlyInProc, "Can only use %s inside a procedure", c.name)
+	}
+	return nil, &stopSignal{}
+}
+
+// primMaybeoutput outputs the value of its input if it has one and
+// otherwise stops, so a procedure can pass on whatever RUN gives it.
+func primMaybeoutput(c *call) (*thing.Thing, error) {
+	return c.output(modeMaybe)
+}
+
+func (c *call) output(m mode) (*thing.Thing, error) {
+	if c.in.frame == nil {
+		return nil, c.errorf(logoerr.ERR_OnlyInProc, "Can only use %s inside a procedure", c.name)
+	}
+	arg := c.node.Children[0]
+	var value *thing.Thing
+	var err error
+	if c.in.frame.held > 0 {
+		value, err = c.in.eval(arg)
+	} else {
+		value, err = c.in.evalTail(arg, m)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value == nil && m == modeValue {
+		return nil, c.in.errorf(arg, logoerr.ERR_DidntOutput, "%s didn't output to %s", arg.Name, c.name)
+	}
+	return nil, &outputSignal{value: value}
+}
+
+func primDefine(c *call) (*thing.Thing, error) {
+	name, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	name = strings.ToLower(name)
+	if _, ok := c.in.prims[name]; ok {
+		return nil, c.errorf(logoerr.ERR_IsPrimitive, "%s is a primitive", name)
+	}
+	proc, err := thing.NewProcFromText(name, c.args[1])
+	if err != nil {
+		return nil, c.in.locate(err, c.node)
+	}
+	c.in.setProc(name, proc)
+	return nil, nil
+}
+
+func (c *call) proc(i int) (*thing.TProc, error) {
+	name, err := c.word(i)
+	if err != nil {
+		return nil, err
+	}
+	proc, ok := c.in.procs[strings.ToLower(name)]
+	if !ok {
+		if _, ok := c.in.prims[strings.ToLower(name)]; ok {
+			return nil, c.errorf(logoerr.ERR_IsPrimitive, "%s is a primitive", name)
+		}
+		return nil, c.errorf(logoerr.ERR_DontKnowHow, "I don't know how to %s", name)
+	}
+	return proc.Value().(*thing.TProc), nil
+}
+
+func primText(c *call) (*thing.Thing, error) {
+	proc, err := c.proc(0)
+	if err != nil {
+		return nil, err
+	}
+	return proc.Text(), nil
+}
+
+func primFulltext(c *call) (*thing.Thing, error) {
+	proc, err := c.proc(0)
+	if err != nil {
+		return nil, err
+	}
+	return proc.FullText(), nil
+}
+
+// write prints the inputs separated by sep and followed by end. Lists
+// are written in brackets if brackets is set, as SHOW does.
+func (c *call) write(brackets bool, sep, end string) error {
+	full := c.in.fullPrint()
+	parts := make([]string, len(c.args))
+	for i, arg := range c.args {
+		parts[i] = arg.Format(brackets || arg.Tag() != thing.TagTList, full)
+	}
+	if _, err := fmt.Fprint(c.in.out, strings.Join(parts, sep)+end); err != nil {
+		return c.errorf(logoerr.ERR_FileSystem, "%v", err)
+	}
+	return nil
+}
+
+func primPrint(c *call) (*thing.Thing, error) {
+	return nil, c.write(false, " ", "\n")
+}
+
+// primType prints like PRINT, but without spaces between its inputs or
+// a newline after them.
+func primType(c *call) (*thing.Thing, error) {
+	return nil, c.write(false, "", "")
+}
+
+func primShow(c *call) (*thing.Thing, error) {
+	return nil, c.write(true, " ", "\n")
+}
+
+func primSum(c *call) (*thing.Thing, error) {
+	total := 0.0
+	for i := range c.args {
+		n, err := c.number(i)
+		if err != nil {
+			return nil, err
+		}
+		total += n
+	}
+	return number(total), nil
+}
+
+func primProduct(c *call) (*thing.Thing, error) {
+	total := 1.0
+	for i := range c.args {
+		n, err := c.number(i)
+		if err != nil {
+			return nil, err
+		}
+		total *= n
+	}
+	return number(total), nil
+}
+
+// numbers returns the inputs of a call that takes exactly two numbers.
+func (c *call) numbers() (a, b float64, err error) {
+	if a, err = c.number(0); err != nil {
+		return 0, 0, err
+	}
+	if b, err = c.number(1); err != nil {
+		return 0, 0, err
+	}
+	return a, b, nil
+}
+
+func primDifference(c *call) (*thing.Thing, error) {
+	a, b, err := c.numbers()
+	if err != nil {
+		return nil, err
+	}
+	return number(a - b), nil
+}
+
+func primQuotient(c *call) (*thing.Thing, error) {
+	if len(c.args) == 1 {
+		c.args = append([]*thing.Thing{number(1)}, c.args...)
+	}
+	a, b, err := c.numbers()
+	if err != nil {
+		return nil, err
+	}
+	if b == 0 {
+		return nil, c.badInput(1)
+	}
+	return number(a / b), nil
+}
+
+func primRemainder(c *call) (*thing.Thing, error) {
+	a, b, err := c.numbers()
+	if err != nil {
+		return nil, err
+	}
+	if b == 0 {
+		return nil, c.badInput(1)
+	}
+	return number(math.Mod(a, b)), nil
+}
+
+func primModulo(c *call) (*thing.Thing, error) {
+	a, b, err := c.numbers()
+	if err != nil {
+		return nil, err
+	}
+	if b == 0 {
+		return nil, c.badInput(1)
+	}
+	m := math.Mod(a, b)
+	if m != 0 && (m < 0) != (b < 0) {
+		m += b
+	}
+	return number(m), nil
+}
+
+func primMinus(c *call) (*thing.Thing, error) {
+	n, err := c.number(0)
+	if err != nil {
+		return nil, err
+	}
+	return number(-n), nil
+}
+
+func mathFunc(fn func(float64) float64) func(c *call) (*thing.Thing, error) {
+	return func(c *call) (*thing.Thing, error) {
+		n, err := c.number(0)
+		if err != nil {
+			return nil, err
+		}
+		return number(fn(n)), nil
+	}
+}
+
+func primSqrt(c *call) (*thing.Thing, error) {
+	n, err := c.number(0)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, c.badInput(0)
+	}
+	return number(math.Sqrt(n)), nil
+}
+
+func primPower(c *call) (*thing.Thing, error) {
+	a, b, err := c.numbers()
+	if err != nil {
+		return nil, err
+	}
+	p := math.Pow(a, b)
+	if math.IsNaN(p) {
+		return nil, c.badInput(0)
+	}
+	return number(p), nil
+}
+
+// equal compares two values the way EQUALP does, ignoring the case of
+// words unless CASEIGNOREDP is false.
+func (in *Interp) equal(a, b *thing.Thing) bool {
+	return a.Equal(b, in.caseIgnored())
+}
+
+func (in *Interp) caseIgnored() bool {
+	value, ok := in.lookup("caseignoredp")
+	if !ok || value == nil {
+		return true
+	}
+	w, ok := value.Word()
+	return !ok || !strings.EqualFold(w, "false")
+}
+
+func primEqualp(c *call) (*thing.Thing, error) {
+	return boolean(c.in.equal(c.args[0], c.args[1])), nil
+}
+
+func primNotequalp(c *call) (*thing.Thing, error) {
+	return boolean(!c.in.equal(c.args[0], c.args[1])), nil
+}
+
+func primEq(c *call) (*thing.Thing, error) {
+	return boolean(c.args[0].Eq(c.args[1])), nil
+}
+
+func compare(cmp func(a, b float64) bool) func(c *call) (*thing.Thing, error) {
+	return func(c *call) (*thing.Thing, error) {
+		a, b, err := c.numbers()
+		if err != nil {
+			return nil, err
+		}
+		return boolean(cmp(a, b)), nil
+	}
+}
@@ -0,0 +1,29 @@
+package eval
+
+import (
+	"strings"
+
+	"gortle/internal/thing"
+)
+
+// show formats a value the way SHOW prints it, with lists in brackets.
+func show(t *thing.Thing) string {
+	return t.String()
+}
+
+// printed formats a value the way PRINT prints it: the outer brackets
+// of a list are dropped.
+func printed(t *thing.Thing) string {
+	return t.Format(t.Tag() != thing.TagTList, false)
+}
+
+// fullPrint reports whether FULLPRINTP asks PRINT, TYPE and SHOW to
+// escape words so that they read back unchanged.
+func (in *Interp) fullPrint() bool {
+	value, ok := in.lookup("fullprintp")
+	if !ok || value == nil {
+		return false
+	}
+	w, ok := value.Word()
+	return ok && strings.EqualFold(w, "true")
+}
@@ -0,0 +1,63 @@
+package eval
+
+import (
+	"io"
+
+	"gortle/internal/logoerr"
+	"gortle/internal/parser"
+	"gortle/internal/thing"
+)
+
+func (in *Interp) addRead() {
+	in.prim("readlist rl", 0, 0, 0, primReadlist)
+	in.prim("parse", 1, 1, 1, primParse)
+	in.prim("runparse", 1, 1, 1, primRunparse)
+}
+
+// readList reads text as a list the way it would be printed.
+func (c *call) readList(text string) (*thing.Thing, error) {
+	datum, err := parser.ReadList(c.name, text)
+	if err != nil {
+		return nil, c.in.locate(err, nil)
+	}
+	return thing.FromDatum(datum), nil
+}
+
+// primReadlist reads a line of input as a list. At the end of input it
+// outputs the empty word.
+func primReadlist(c *call) (*thing.Thing, error) {
+	if c.in.input == nil {
+		return thing.NewWord("", false), nil
+	}
+	line, err := readLine(c.in.input)
+	if err == io.EOF {
+		return thing.NewWord("", false), nil
+	}
+	if err != nil {
+		return nil, c.errorf(logoerr.ERR_FileSystem, "%v", err)
+	}
+	return c.readList(line)
+}
+
+func primParse(c *call) (*thing.Thing, error) {
+	w, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	return c.readList(w)
+}
+
+// primRunparse outputs a word or list split into the tokens RUN would
+// see.
+func primRunparse(c *call) (*thing.Thing, error) {
+	lst := c.args[0]
+	if w, ok := lst.Word(); ok {
+		var err error
+		if lst, err = c.readList(w); err != nil {
+			return nil, err
+		}
+	} else if _, err := c.list(0); err != nil {
+		return nil, err
+	}
+	return thing.FromDatum(parser.RunParse(thing.ToDatum(lst))), nil
+}
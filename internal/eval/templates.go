@@ -0,0 +1,590 @@
+package eval
+
+import (
+	"strings"
+
+	"gortle/internal/ast"
+	"gortle/internal/logoerr"
+	"gortle/internal/parser"
+	"gortle/internal/thing"
+)
+
+// template is a template input to a higher-order primitive, in one of
+// three forms: the name of a procedure, a list using the slots ? ?1 ?2
+// and #, or a lambda [[x y] body] naming its inputs.
+type template struct {
+	text *thing.Thing
+	name string
+	proc *thing.TProc
+	body []*ast.ASTNode
+}
+
+// slots holds the values the explicit-slot procedures see while a
+// template runs: the inputs, the iteration number for #, and the
+// baskets of TRANSFER.
+type slots struct {
+	args    []*thing.Thing
+	count   int
+	in, out *thing.Thing
+}
+
+func (in *Interp) addTemplates() {
+	in.prim("apply", 2, 2, 2, primApply)
+	in.prim("invoke", 1, 2, -1, primInvoke)
+	in.prim("run", 1, 1, 1, primRun)
+	in.prim("runresult", 1, 1, 1, primRunresult)
+	in.prim("map", 2, 2, -1, primMap)
+	in.prim("map.se", 2, 2, -1, primMap)
+	in.prim("filter", 2, 2, 2, primFilter)
+	in.prim("find", 2, 2, 2, primFind)
+	in.prim("reduce", 2, 2, 2, primReduce)
+	in.prim("crossmap", 2, 2, -1, primCrossmap)
+	in.prim("cascade", 3, 3, -1, primCascade)
+	in.prim("foreach", 2, 2, -1, primForeach)
+	in.prim("transfer", 3, 3, 3, primTransfer)
+
+	in.prim("?", 0, 0, 1, primSlot)
+	for i := 1; i <= 9; i++ {
+		in.prim("?"+string(rune('0'+i)), 0, 0, 0, slotN(i))
+	}
+	in.prim("?rest", 0, 0, 0, primSlotRest)
+	in.prim("#", 0, 0, 0, primSlotCount)
+	in.prim("?in", 0, 0, 0, primSlotIn)
+	in.prim("?out", 0, 0, 0, primSlotOut)
+}
+
+// template reads the template input at i.
+func (c *call) template(i int) (*template, error) {
+	text := c.args[i]
+	if name, ok := text.Word(); ok {
+		return &template{text: text, name: strings.ToLower(name)}, nil
+	}
+	lst, err := c.list(i)
+	if err != nil || len(lst) == 0 {
+		return nil, c.badInput(i)
+	}
+	if lst[0].Tag() != thing.TagTList {
+		return &template{text: text}, nil
+	}
+
+	// A lambda is given a procedure of its own for its inputs, and the
+	// rest of the template is read as its body.
+	proc, err := thing.NewProcFromText(c.name, thing.New(lst[:1], thing.TagTList, false))
+	if err != nil {
+		return nil, c.badInput(i)
+	}
+	datum := thing.ToDatum(text)
+	instrs, err := parser.ParseList(ast.NewList(datum.Pos, datum.Children[1:]), c.in)
+	if err != nil {
+		return nil, c.in.locate(err, nil)
+	}
+	return &template{text: text, proc: proc.Value().(*thing.TProc), body: instrs}, nil
+}
+
+// invoke runs a template with args in its slots. count is the value of
+// #.
+func (c *call) invoke(t *template, s slots) (*thing.Thing, error) {
+	in := c.in
+	if t.name != "" {
+		return c.invokeNamed(t.name, s.args)
+	}
+	in.slots = append(in.slots, &s)
+	defer func() { in.slots = in.slots[:len(in.slots)-1] }()
+	if t.proc == nil {
+		return in.runList(t.text, modeMaybe, false)
+	}
+
+	min, _, max := t.proc.Arity()
+	if err := c.checkInputs(show(t.text), min, max, len(s.args)); err != nil {
+		return nil, err
+	}
+	f := &frame{proc: t.proc, vars: make(thing.TEnv), parent: in.frame, held: 1}
+	in.frame = f
+	defer func() { in.frame = f.parent }()
+	if err := in.bindInputs(f, s.args, c.node); err != nil {
+		return nil, err
+	}
+	value, err := in.runInstrs(t.body, modeMaybe, false)
+	switch sig := err.(type) {
+	case *outputSignal:
+		return sig.value, nil
+	case *stopSignal:
+		return nil, nil
+	}
+	return value, err
+}
+
+// invokeNamed calls a procedure or primitive by name, checking the
+// number of inputs as the parser would have.
+func (c *call) invokeNamed(name string, args []*thing.Thing) (*thing.Thing, error) {
+	min, _, max, ok := c.in.Arity(name)
+	if !ok {
+		return nil, c.errorf(logoerr.ERR_DontKnowHow, "I don't know how to %s", name)
+	}
+	if prim, ok := c.in.prims[name]; ok && prim.special {
+		return nil, c.errorf(logoerr.ERR_BadInput, "%s doesn't like %s as input", c.name, name)
+	}
+	if err := c.checkInputs(name, min, max, len(args)); err != nil {
+		return nil, err
+	}
+	return c.in.apply(name, args, c.node)
+}
+
+// checkInputs reports an error if n inputs are too few or too many for
+// the template called name.
+func (c *call) checkInputs(name string, min, max, n int) error {
+	switch {
+	case n < min:
+		return c.errorf(logoerr.ERR_NotEnoughInputs, "not enough inputs to %s", name)
+	case max >= 0 && n > max:
+		return c.errorf(logoerr.ERR_BadInput, "too many inputs to %s", name)
+	}
+	return nil
+}
+
+// value invokes a template that must output.
+func (c *call) value(t *template, s slots) (*thing.Thing, error) {
+	value, err := c.invoke(t, s)
+	if err == nil && value == nil {
+		name := t.name
+		if name == "" {
+			name = show(t.text)
+		}
+		return nil, c.errorf(logoerr.ERR_DidntOutput, "%s didn't output to %s", name, c.name)
+	}
+	return value, err
+}
+
+// predicate invokes a template that must output true or false.
+func (c *call) predicate(t *template, s slots) (bool, error) {
+	value, err := c.value(t, s)
+	if err != nil {
+		return false, err
+	}
+	w, ok := value.Word()
+	switch {
+	case ok && strings.EqualFold(w, "true"):
+		return true, nil
+	case ok && strings.EqualFold(w, "false"):
+		return false, nil
+	}
+	return false, c.errorf(logoerr.ERR_BadInput, "%s doesn't like %s as input", c.name, show(value))
+}
+
+func (c *call) slots() (*slots, error) {
+	if len(c.in.slots) == 0 {
+		return nil, c.errorf(logoerr.ERR_OnlyInProc, "Can only use %s inside a template", c.name)
+	}
+	return c.in.slots[len(c.in.slots)-1], nil
+}
+
+func primApply(c *call) (*thing.Thing, error) {
+	t, err := c.template(0)
+	if err != nil {
+		return nil, err
+	}
+	args, err := c.list(1)
+	if err != nil {
+		return nil, err
+	}
+	return c.invoke(t, slots{args: args, count: 1})
+}
+
+func primInvoke(c *call) (*thing.Thing, error) {
+	t, err := c.template(0)
+	if err != nil {
+		return nil, err
+	}
+	return c.invoke(t, slots{args: c.args[1:], count: 1})
+}
+
+func primRun(c *call) (*thing.Thing, error) {
+	return c.in.runList(c.args[0], modeMaybe, false)
+}
+
+// primRunresult outputs [] if its instructions do not output, and a
+// list of the value if they do.
+func primRunresult(c *call) (*thing.Thing, error) {
+	value, err := c.in.runList(c.args[0], modeMaybe, false)
+	if err != nil {
+		return nil, err
+	}
+	lst := thing.TList{}
+	if value != nil {
+		lst = append(lst, value)
+	}
+	return thing.New(lst, thing.TagTList, false), nil
+}
+
+// data reads the data inputs from i on, which must all have the same
+// number of members.
+func (c *call) data(from, to int) ([]*members, error) {
+	var data []*members
+	for i := from; i < to; i++ {
+		m, err := c.members(i)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > 0 && len(m.items) != len(data[0].items) {
+			return nil, c.badInput(i)
+		}
+		data = append(data, m)
+	}
+	return data, nil
+}
+
+// nth returns the members at index i of each of data.
+func nth(data []*members, i int) []*thing.Thing {
+	args := make([]*thing.Thing, len(data))
+	for j, m := range data {
+		args[j] = m.items[i]
+	}
+	return args
+}
+
+// primMap outputs the values of the template for each member of the
+// data, as a word, list or array like the first data input. MAP.SE
+// combines the values with SENTENCE instead.
+func primMap(c *call) (*thing.Thing, error) {
+	t, err := c.template(0)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.data(1, len(c.args))
+	if err != nil {
+		return nil, err
+	}
+	var values []*thing.Thing
+	for i := range data[0].items {
+		value, err := c.value(t, slots{args: nth(data, i), count: i + 1})
+		if err != nil {
+			return nil, err
+		}
+		if c.name != "map.se" {
+			values = append(values, value)
+		} else if lst, ok := value.Value().(thing.TList); ok {
+			values = append(values, lst...)
+		} else {
+			values = append(values, value)
+		}
+	}
+	if c.name == "map.se" {
+		return thing.New(append(thing.TList{}, values...), thing.TagTList, false), nil
+	}
+	if data[0].tag == thing.TagTString {
+		for _, value := range values {
+			if _, ok := value.Word(); !ok {
+				return thing.New(append(thing.TList{}, values...), thing.TagTList, false), nil
+			}
+		}
+	}
+	return data[0].make(values), nil
+}
+
+func primFilter(c *call) (*thing.Thing, error) {
+	t, err := c.template(0)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.members(1)
+	if err != nil {
+		return nil, err
+	}
+	var kept []*thing.Thing
+	for i, item := range m.items {
+		ok, err := c.predicate(t, slots{args: []*thing.Thing{item}, count: i + 1})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			kept = append(kept, item)
+		}
+	}
+	return m.make(kept), nil
+}
+
+// primFind outputs the first member for which the template is true, or
+// the empty list.
+func primFind(c *call) (*thing.Thing, error) {
+	t, err := c.template(0)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.members(1)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range m.items {
+		ok, err := c.predicate(t, slots{args: []*thing.Thing{item}, count: i + 1})
+		if err != nil || ok {
+			return item, err
+		}
+	}
+	return thing.New(thing.TList{}, thing.TagTList, false), nil
+}
+
+// primReduce combines the members from the right:
+// reduce "f [a b c] is f a f b c.
+func primReduce(c *call) (*thing.Thing, error) {
+	t, err := c.template(0)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.nonEmpty(1)
+	if err != nil {
+		return nil, err
+	}
+	acc := m.items[len(m.items)-1]
+	for i := len(m.items) - 2; i >= 0; i-- {
+		if acc, err = c.value(t, slots{args: []*thing.Thing{m.items[i], acc}, count: i + 1}); err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+// primCrossmap outputs the values of the template for every combination
+// of members of the data lists, the first varying slowest. With one
+// data input it is a list of the lists to combine.
+func primCrossmap(c *call) (*thing.Thing, error) {
+	t, err := c.template(0)
+	if err != nil {
+		return nil, err
+	}
+	var data []*members
+	if len(c.args) == 2 {
+		lst, err := c.list(1)
+		if err != nil {
+			return nil, err
+		}
+		for _, elt := range lst {
+			m, err := (&call{in: c.in, name: c.name, node: c.node, args: []*thing.Thing{elt}}).members(0)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, m)
+		}
+	} else {
+		for i := 1; i < len(c.args); i++ {
+			m, err := c.members(i)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, m)
+		}
+	}
+
+	values := thing.TList{}
+	args := make([]*thing.Thing, len(data))
+	var cross func(j int) error
+	cross = func(j int) error {
+		if j == len(data) {
+			value, err := c.value(t, slots{args: append([]*thing.Thing(nil), args...), count: len(values) + 1})
+			values = append(values, value)
+			return err
+		}
+		for _, item := range data[j].items {
+			args[j] = item
+			if err := cross(j + 1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := cross(0); err != nil {
+		return nil, err
+	}
+	return thing.New(values, thing.TagTList, false), nil
+}
+
+// primCascade applies templates repeatedly to values, starting from the
+// start values, until the end test is true or has been applied that
+// many times if it is a number. The inputs are (cascade endtest
+// template1 start1 template2 start2 ... final), with ?1, ?2 ... the
+// current values. The value of the final template is output, or ?1.
+func primCascade(c *call) (*thing.Thing, error) {
+	var templates []*template
+	var values []*thing.Thing
+	i := 1
+	for ; i+1 < len(c.args); i += 2 {
+		t, err := c.template(i)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+		values = append(values, c.args[i+1])
+	}
+	var final *template
+	if i < len(c.args) {
+		t, err := c.template(i)
+		if err != nil {
+			return nil, err
+		}
+		final = t
+	}
+
+	times, counted := c.args[0].Number()
+	var test *template
+	if !counted {
+		t, err := c.template(0)
+		if err != nil {
+			return nil, err
+		}
+		test = t
+	}
+	for count := 1; ; count++ {
+		if counted {
+			if float64(count) > times {
+				break
+			}
+		} else {
+			done, err := c.predicate(test, slots{args: values, count: count})
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				break
+			}
+		}
+		next := make([]*thing.Thing, len(values))
+		for j, t := range templates {
+			value, err := c.value(t, slots{args: values, count: count})
+			if err != nil {
+				return nil, err
+			}
+			next[j] = value
+		}
+		values = next
+	}
+	if final != nil {
+		return c.value(final, slots{args: values, count: 1})
+	}
+	return values[0], nil
+}
+
+func primForeach(c *call) (*thing.Thing, error) {
+	last := len(c.args) - 1
+	t, err := c.template(last)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.data(0, last)
+	if err != nil {
+		return nil, err
+	}
+	for i := range data[0].items {
+		value, err := c.invoke(t, slots{args: nth(data, i), count: i + 1})
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			return nil, c.errorf(logoerr.ERR_DontSay, "You don't say what to do with %s", show(value))
+		}
+	}
+	return nil, nil
+}
+
+// primTransfer runs the template for each member of the inbasket, ?in,
+// making its value the new outbasket, ?out, which starts empty. The end
+// test, unless it is the empty list, is checked before each member.
+func primTransfer(c *call) (*thing.Thing, error) {
+	var test *template
+	if lst, ok := c.args[0].Value().(thing.TList); !ok || len(lst) > 0 {
+		t, err := c.template(0)
+		if err != nil {
+			return nil, err
+		}
+		test = t
+	}
+	t, err := c.template(1)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.members(2)
+	if err != nil {
+		return nil, err
+	}
+	out := thing.New(thing.TList{}, thing.TagTList, false)
+	for i, item := range m.items {
+		s := slots{count: i + 1, in: item, out: out}
+		if test != nil {
+			done, err := c.predicate(test, s)
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				break
+			}
+		}
+		if out, err = c.value(t, s); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// primSlot outputs the first input of the running template, or with an
+// input the one it numbers.
+func primSlot(c *call) (*thing.Thing, error) {
+	n := 1
+	if len(c.args) > 0 {
+		var err error
+		if n, err = c.integer(0); err != nil {
+			return nil, err
+		}
+	}
+	return c.slot(n)
+}
+
+func slotN(n int) func(c *call) (*thing.Thing, error) {
+	return func(c *call) (*thing.Thing, error) {
+		return c.slot(n)
+	}
+}
+
+func (c *call) slot(n int) (*thing.Thing, error) {
+	s, err := c.slots()
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 || n > len(s.args) {
+		return nil, c.errorf(logoerr.ERR_BadInput, "%s doesn't like %d as input", c.name, n)
+	}
+	return s.args[n-1], nil
+}
+
+func primSlotRest(c *call) (*thing.Thing, error) {
+	s, err := c.slots()
+	if err != nil {
+		return nil, err
+	}
+	rest := thing.TList{}
+	if len(s.args) > 1 {
+		rest = append(rest, s.args[1:]...)
+	}
+	return thing.New(rest, thing.TagTList, false), nil
+}
+
+func primSlotCount(c *call) (*thing.Thing, error) {
+	s, err := c.slots()
+	if err != nil {
+		return nil, err
+	}
+	return number(float64(s.count)), nil
+}
+
+func primSlotIn(c *call) (*thing.Thing, error) {
+	s, err := c.slots()
+	if err != nil || s.in == nil {
+		return nil, c.errorf(logoerr.ERR_OnlyInProc, "Can only use %s inside transfer", c.name)
+	}
+	return s.in, nil
+}
+
+func primSlotOut(c *call) (*thing.Thing, error) {
+	s, err := c.slots()
+	if err != nil || s.out == nil {
+		return nil, c.errorf(logoerr.ERR_OnlyInProc, "Can only use %s inside transfer", c.name)
+	}
+	return s.out, nil
+}
@@ -0,0 +1,338 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gortle/internal/logoerr"
+	"gortle/internal/thing"
+)
+
+// contents names procedures, variables and property lists, as in a
+// contents list [[procs] [vars] [plists]].
+type contents struct {
+	procs, vars, plists []string
+}
+
+func (in *Interp) addWorkspace() {
+	in.prim("contents", 0, 0, 0, primContents)
+	in.prim("buried", 0, 0, 0, primContents)
+	in.prim("bury", 1, 1, 1, primBury)
+	in.prim("unbury", 1, 1, 1, primBury)
+	in.prim("buriedp buried?", 1, 1, 1, primBuriedp)
+	in.prim("po", 1, 1, 1, primPo)
+	in.prim("poall", 0, 0, 0, primPoall)
+	in.prim("pops", 0, 0, 0, primPoall)
+	in.prim("pons", 0, 0, 0, primPoall)
+	in.prim("pots", 0, 0, 0, primPots)
+	in.prim("erase er", 1, 1, 1, primErase)
+	in.prim("erall", 0, 0, 0, primErall)
+	in.prim("erps", 0, 0, 0, primErall)
+	in.prim("erns", 0, 0, 0, primErall)
+	in.prim("save", 1, 1, 1, primSave)
+	in.prim("load", 1, 1, 1, primLoad)
+}
+
+// contents reads the contents list at input i. A word or a list of
+// words names procedures; otherwise the input is a list of up to three
+// lists of procedure, variable and property list names.
+func (c *call) contents(i int) (contents, error) {
+	var ct contents
+	if w, ok := c.args[i].Word(); ok {
+		ct.procs = []string{w}
+		return ct, nil
+	}
+	lst, err := c.list(i)
+	if err != nil {
+		return ct, err
+	}
+	if len(lst) == 0 || lst[0].Tag() != thing.TagTList {
+		if ct.procs, err = c.names(i, lst); err != nil {
+			return ct, err
+		}
+		return ct, nil
+	}
+	if len(lst) > 3 {
+		return ct, c.badInput(i)
+	}
+	parts := []*[]string{&ct.procs, &ct.vars, &ct.plists}
+	for j, elt := range lst {
+		names, ok := elt.Value().(thing.TList)
+		if !ok {
+			return ct, c.badInput(i)
+		}
+		if *parts[j], err = c.names(i, names); err != nil {
+			return ct, err
+		}
+	}
+	return ct, nil
+}
+
+func (c *call) names(i int, lst thing.TList) ([]string, error) {
+	names := make([]string, len(lst))
+	for j, elt := range lst {
+		w, ok := elt.Word()
+		if !ok {
+			return nil, c.badInput(i)
+		}
+		names[j] = w
+	}
+	return names, nil
+}
+
+// workspace lists the procedures, global variables and property lists
+// that are buried, or those that are not, each sorted by name.
+func (in *Interp) workspace(buried bool) contents {
+	var ct contents
+	for name, proc := range in.procs {
+		if proc.Buried() == buried {
+			ct.procs = append(ct.procs, name)
+		}
+	}
+	for _, sym := range in.globals.Symbols() {
+		if in.globals.Buried(sym) == buried {
+			ct.vars = append(ct.vars, sym.Name())
+		}
+	}
+	for _, pl := range in.plists {
+		if pl.buried == buried {
+			ct.plists = append(ct.plists, pl.name)
+		}
+	}
+	for _, names := range [][]string{ct.procs, ct.vars, ct.plists} {
+		sort.Slice(names, func(i, j int) bool {
+			return strings.ToLower(names[i]) < strings.ToLower(names[j])
+		})
+	}
+	return ct
+}
+
+func words(names []string) *thing.Thing {
+	lst := make(thing.TList, len(names))
+	for i, name := range names {
+		lst[i] = thing.NewWord(name, false)
+	}
+	return thing.New(lst, thing.TagTList, false)
+}
+
+// primContents outputs a contents list of everything in the workspace
+// that is not buried, or for BURIED everything that is.
+func primContents(c *call) (*thing.Thing, error) {
+	ct := c.in.workspace(c.name == "buried")
+	lst := thing.TList{words(ct.procs), words(ct.vars), words(ct.plists)}
+	return thing.New(lst, thing.TagTList, false), nil
+}
+
+// primBury hides the named things from POALL, ERALL, SAVE and the like,
+// or for UNBURY shows them again. Names with nothing to hide are
+// ignored.
+func primBury(c *call) (*thing.Thing, error) {
+	ct, err := c.contents(0)
+	if err != nil {
+		return nil, err
+	}
+	buried := c.name == "bury"
+	in := c.in
+	for _, name := range ct.procs {
+		if proc, ok := in.procs[strings.ToLower(name)]; ok {
+			proc.SetBuried(buried)
+		}
+	}
+	for _, name := range ct.vars {
+		sym := thing.Symbol(name)
+		if buried {
+			in.globals.BuryVariable(sym)
+		} else {
+			in.globals.UnburyVariable(sym)
+		}
+	}
+	for _, name := range ct.plists {
+		if pl, ok := in.plists[strings.ToLower(name)]; ok {
+			pl.buried = buried
+		}
+	}
+	return nil, nil
+}
+
+// primBuriedp outputs whether the first thing named is buried.
+func primBuriedp(c *call) (*thing.Thing, error) {
+	ct, err := c.contents(0)
+	if err != nil {
+		return nil, err
+	}
+	in := c.in
+	switch {
+	case len(ct.procs) > 0:
+		proc, ok := in.procs[strings.ToLower(ct.procs[0])]
+		return boolean(ok && proc.Buried()), nil
+	case len(ct.vars) > 0:
+		return boolean(in.globals.Buried(thing.Symbol(ct.vars[0]))), nil
+	case len(ct.plists) > 0:
+		pl, ok := in.plists[strings.ToLower(ct.plists[0])]
+		return boolean(ok && pl.buried), nil
+	}
+	return nil, c.badInput(0)
+}
+
+// printContents writes the named things to w as the instructions that
+// would recreate them: procedure definitions, MAKEs and PPROPs.
+func (c *call) printContents(w io.Writer, ct contents) error {
+	in := c.in
+	var sb strings.Builder
+	for _, name := range ct.procs {
+		proc, err := c.namedProc(name)
+		if err != nil {
+			return err
+		}
+		sb.WriteString(proc.Defn() + "\n")
+	}
+	for _, name := range ct.vars {
+		value, ok := in.globals.Lookup(thing.Symbol(name))
+		if !ok || value == nil {
+			return c.errorf(logoerr.ERR_NoValue, "%s has no value", name)
+		}
+		fmt.Fprintf(&sb, "make \"%s %s\n", escapeName(name), quoted(value))
+	}
+	for _, name := range ct.plists {
+		pl, ok := in.plists[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+		lst := pl.props.ToList().Value().(thing.TList)
+		for i := 0; i < len(lst); i += 2 {
+			fmt.Fprintf(&sb, "pprop \"%s %s %s\n", escapeName(pl.name), quoted(lst[i]), quoted(lst[i+1]))
+		}
+	}
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return c.errorf(logoerr.ERR_FileSystem, "%v", err)
+	}
+	return nil
+}
+
+// namedProc finds a procedure by name for PO and ERASE.
+func (c *call) namedProc(name string) (*thing.TProc, error) {
+	key := strings.ToLower(name)
+	if proc, ok := c.in.procs[key]; ok {
+		return proc.Value().(*thing.TProc), nil
+	}
+	if _, ok := c.in.prims[key]; ok {
+		return nil, c.errorf(logoerr.ERR_IsPrimitive, "%s is a primitive", name)
+	}
+	return nil, c.errorf(logoerr.ERR_DontKnowHow, "I don't know how to %s", name)
+}
+
+// escapeName writes a name so that it reads back as the same word after
+// a quote.
+func escapeName(name string) string {
+	return thing.NewWord(name, false).Format(false, true)
+}
+
+func primPo(c *call) (*thing.Thing, error) {
+	ct, err := c.contents(0)
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.printContents(c.in.out, ct)
+}
+
+// primPoall prints everything that is not buried, or for POPS and
+// PONS just the procedures or the variables.
+func primPoall(c *call) (*thing.Thing, error) {
+	ct := c.in.workspace(false)
+	switch c.name {
+	case "pops":
+		ct = contents{procs: ct.procs}
+	case "pons":
+		ct = contents{vars: ct.vars}
+	}
+	return nil, c.printContents(c.in.out, ct)
+}
+
+// primPots prints the title line of every procedure that is not buried.
+func primPots(c *call) (*thing.Thing, error) {
+	var sb strings.Builder
+	for _, name := range c.in.workspace(false).procs {
+		title, _, _ := strings.Cut(c.in.procs[name].Value().(*thing.TProc).Defn(), "\n")
+		sb.WriteString(title + "\n")
+	}
+	if _, err := io.WriteString(c.in.out, sb.String()); err != nil {
+		return nil, c.errorf(logoerr.ERR_FileSystem, "%v", err)
+	}
+	return nil, nil
+}
+
+// erase removes the named things from the workspace.
+func (c *call) erase(ct contents) error {
+	in := c.in
+	for _, name := range ct.procs {
+		if _, err := c.namedProc(name); err != nil {
+			return err
+		}
+	}
+	for _, name := range ct.procs {
+		in.eraseProc(strings.ToLower(name))
+	}
+	for _, name := range ct.vars {
+		in.globals.DeleteVariable(thing.Symbol(name))
+	}
+	for _, name := range ct.plists {
+		delete(in.plists, strings.ToLower(name))
+	}
+	return nil
+}
+
+func primErase(c *call) (*thing.Thing, error) {
+	ct, err := c.contents(0)
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.erase(ct)
+}
+
+// primErall erases everything that is not buried, or for ERPS and ERNS
+// just the procedures or the variables.
+func primErall(c *call) (*thing.Thing, error) {
+	ct := c.in.workspace(false)
+	switch c.name {
+	case "erps":
+		ct = contents{procs: ct.procs}
+	case "erns":
+		ct = contents{vars: ct.vars}
+	}
+	return nil, c.erase(ct)
+}
+
+// primSave writes everything that is not buried to a file, as POALL
+// would print it, so that LOAD can read it back.
+func primSave(c *call) (*thing.Thing, error) {
+	name, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, c.errorf(logoerr.ERR_FileSystem, "%v", err)
+	}
+	err = c.printContents(f, c.in.workspace(false))
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = c.errorf(logoerr.ERR_FileSystem, "%v", cerr)
+	}
+	return nil, err
+}
+
+// primLoad reads and runs the instructions in a file.
+func primLoad(c *call) (*thing.Thing, error) {
+	name, err := c.word(0)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, c.errorf(logoerr.ERR_FileSystem, "%v", err)
+	}
+	defer f.Close()
+	return nil, c.in.Run(name, f)
+}
@@ -1,38 +1,97 @@
 package lexer
 
 import (
+	"bufio"
 	"fmt"
-	"patina-lang/internal/token"
+	"io"
+	"strconv"
 	"strings"
 	"unicode/utf8"
+
+	"gortle/internal/token"
 )
 
 const EOF rune = -1
 
+const maxBuffered = 4096
+
+const (
+	spaceChars     = " \t\r\n"
+	delimiterChars = " \t\r\n[](){};"
+	infixChars     = "+-*/=<>"
+	digitChars     = "0123456789"
+)
+
 type StateFn func(*Lexer) StateFn
 
+type Error struct {
+	Pos token.Pos
+	Msg string
+}
+
+type ErrorList []*Error
+
 type Lexer struct {
-	name   string
-	input  string
-	start  int
-	pos    int
-	width  int
-	ch     byte
-	tokens chan token.Token
-	state  StateFn
+	name    string
+	input   string
+	reader  io.RuneReader
+	base    int
+	start   int
+	pos     int
+	width   int
+	ch      byte
+	line    int
+	col     int
+	nesting []rune
+	spaced  bool
+	inTo    bool
+	joined  bool
+	last    token.TokenType
+	tokens  chan token.Token
+	state   StateFn
+	errors  ErrorList
 }
 
 func New(name, input string, initState StateFn) (l *Lexer) {
 	l = &Lexer{
 		name:   name,
 		input:  input,
+		line:   1,
+		col:    1,
+		last:   token.TOKEN_Newline,
 		tokens: make(chan token.Token, 2),
 		state:  initState,
 	}
 	return l
 }
 
+// NewReader returns a lexer that pulls its input from r one line at a
+// time, so it can be fed from a terminal, a pipe or a socket.
+func NewReader(name string, r io.Reader, initState StateFn) *Lexer {
+	l := New(name, "", initState)
+	if rr, ok := r.(io.RuneReader); ok {
+		l.reader = rr
+	} else {
+		l.reader = bufio.NewReader(r)
+	}
+	return l
+}
+
+// Incomplete reports whether the input read so far leaves a list,
+// array or TO definition open, or ends with a ~ continuation.
+func (l *Lexer) Incomplete() bool {
+	return l.inList() || l.inTo || l.joined
+}
+
+// Errors returns every lexical error reported so far, in input order.
+func (l *Lexer) Errors() ErrorList {
+	return append(ErrorList(nil), l.errors...)
+}
+
 func (l *Lexer) GetNextToken() token.Token {
+	if l.state == nil {
+		l.state = lexEOF
+	}
 	for {
 		select {
 		case token := <-l.tokens:
@@ -41,16 +100,51 @@ func (l *Lexer) GetNextToken() token.Token {
 			l.state = l.state(l)
 		}
 	}
-	panic("Unreachable")
 }
 
 func (l *Lexer) emit(t token.TokenType) {
-	l.tokens <- token.Token{t, l.input[l.start:l.pos]}
-	l.start = l.pos
+	l.emitText(t, l.input[l.start:l.pos])
+}
+
+func (l *Lexer) emitText(t token.TokenType, text string) {
+	l.emitWord(t, text, false)
+}
+
+func (l *Lexer) emitWord(t token.TokenType, text string, escaped bool) {
+	l.tokens <- token.Token{
+		Type:    t,
+		Text:    text,
+		Pos:     l.startPos(),
+		Escaped: escaped,
+		Spaced:  l.spaced,
+	}
+	if t == token.TOKEN_Word && !escaped && l.last == token.TOKEN_Newline && !l.inList() {
+		switch strings.ToLower(text) {
+		case "to", ".macro":
+			l.inTo = true
+		case "end":
+			l.inTo = false
+		}
+	}
+	l.ignore()
+	l.last = t
+	l.spaced = false
+	if t != token.TOKEN_EOF {
+		l.joined = false
+	}
+}
+
+func (l *Lexer) startPos() token.Pos {
+	return token.Pos{
+		File:   l.name,
+		Offset: l.base + l.start,
+		Line:   l.line,
+		Column: l.col,
+	}
 }
 
 func (l *Lexer) next() (r rune) {
-	if l.pos >= len(l.input) {
+	if l.pos >= len(l.input) && !l.fill() {
 		l.width = 0
 		return EOF
 	}
@@ -59,7 +153,46 @@ func (l *Lexer) next() (r rune) {
 	return r
 }
 
+// fill appends the next line from the reader to the input, dropping
+// the part of the buffer that has already been tokenized.
+func (l *Lexer) fill() bool {
+	if l.reader == nil {
+		return false
+	}
+	if l.start > maxBuffered {
+		l.input = l.input[l.start:]
+		l.base += l.start
+		l.pos -= l.start
+		l.start = 0
+	}
+	var line strings.Builder
+	for {
+		r, _, err := l.reader.ReadRune()
+		if err != nil {
+			l.reader = nil
+			break
+		}
+		line.WriteRune(r)
+		if r == '\n' {
+			break
+		}
+	}
+	if line.Len() == 0 {
+		return false
+	}
+	l.input += line.String()
+	return true
+}
+
 func (l *Lexer) ignore() {
+	for _, r := range l.input[l.start:l.pos] {
+		if r == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+	}
 	l.start = l.pos
 }
 
@@ -87,10 +220,340 @@ func (l *Lexer) acceptRun(valid string) {
 	l.backup()
 }
 
+// scanWord reads a word starting at from and stops before the first
+// unescaped rune in stop. A backslash makes the rune after it part of
+// the word, and vertical bars do the same for everything up to the
+// closing bar; either one marks the word as escaped.
+func (l *Lexer) scanWord(from int, stop string) (text string, escaped, ok bool) {
+	var sb strings.Builder
+	inBar := false
+	l.pos = from
+	for {
+		r := l.next()
+		switch {
+		case r == EOF:
+			return sb.String(), escaped, !inBar
+		case r == '\\':
+			escaped = true
+			if r = l.next(); r != EOF {
+				sb.WriteRune(r)
+			}
+		case r == '|':
+			escaped = true
+			inBar = !inBar
+		case inBar:
+			sb.WriteRune(r)
+		case strings.ContainsRune(stop, r) || l.atContinuation(r):
+			l.backup()
+			return sb.String(), escaped, true
+		default:
+			sb.WriteRune(r)
+		}
+	}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+func (el ErrorList) Error() string {
+	switch len(el) {
+	case 0:
+		return "no errors"
+	case 1:
+		return el[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", el[0], len(el)-1)
+}
+
+// Err returns the list as an error, or nil when it is empty.
+func (el ErrorList) Err() error {
+	if len(el) == 0 {
+		return nil
+	}
+	return el
+}
+
+// errorf records an error at the start of the current token, emits it
+// as a TOKEN_Error and carries on lexing after the offending text.
 func (l *Lexer) errorf(format string, args ...interface{}) StateFn {
+	err := &Error{
+		Pos: l.startPos(),
+		Msg: fmt.Sprintf(format, args...),
+	}
+	l.errors = append(l.errors, err)
 	l.tokens <- token.Token{
-		token.TOKEN_Error,
-		fmt.Sprintf(format, args...),
+		Type: token.TOKEN_Error,
+		Text: err.Msg,
+		Pos:  err.Pos,
+	}
+	return lexResync
+}
+
+func (l *Lexer) inList() bool {
+	return len(l.nesting) > 0
+}
+
+func (l *Lexer) push(open rune) {
+	l.nesting = append(l.nesting, open)
+}
+
+func (l *Lexer) pop(open rune) bool {
+	n := len(l.nesting)
+	if n == 0 || l.nesting[n-1] != open {
+		return false
+	}
+	l.nesting = l.nesting[:n-1]
+	return true
+}
+
+// atContinuation reports whether r, just consumed, is a tilde that
+// ends its line and so joins the next line onto this one.
+func (l *Lexer) atContinuation(r rune) bool {
+	if r != '~' {
+		return false
+	}
+	if l.pos >= len(l.input) {
+		l.fill()
 	}
-	return nil
+	rest := strings.TrimLeft(l.input[l.pos:], " \t\r")
+	return rest == "" || rest[0] == '\n'
+}
+
+func LexTopLevel(l *Lexer) StateFn {
+	r := l.next()
+	switch {
+	case r == EOF:
+		return lexEOF
+	case r == '\n':
+		if l.inList() {
+			return lexSpace
+		}
+		l.emit(token.TOKEN_Newline)
+		return LexTopLevel
+	case strings.ContainsRune(spaceChars, r):
+		return lexSpace
+	case r == ';':
+		return lexComment
+	case l.atContinuation(r):
+		return lexContinuation
+	case r == '[':
+		l.push(r)
+		l.emit(token.TOKEN_LBracket)
+		return LexTopLevel
+	case r == ']':
+		if !l.pop('[') {
+			return l.errorf("unexpected ]")
+		}
+		l.emit(token.TOKEN_RBracket)
+		return LexTopLevel
+	case r == '{':
+		l.push(r)
+		l.emit(token.TOKEN_LBrace)
+		return LexTopLevel
+	case r == '}':
+		if !l.pop('{') {
+			return l.errorf("unexpected }")
+		}
+		l.emit(token.TOKEN_RBrace)
+		return lexOrigin
+	case r == '(':
+		l.emit(token.TOKEN_LParen)
+		return LexTopLevel
+	case r == ')':
+		l.emit(token.TOKEN_RParen)
+		return LexTopLevel
+	case l.inList():
+		return lexListWord
+	case r == '"':
+		return lexQuoted
+	case r == ':':
+		return lexVariable
+	case strings.ContainsRune(digitChars, r),
+		r == '.' && strings.ContainsRune(digitChars, l.peek()):
+		l.backup()
+		return lexNumber
+	case strings.ContainsRune(infixChars, r):
+		l.backup()
+		return lexOperator
+	default:
+		return lexWord
+	}
+}
+
+// LexData lexes input as the contents of a list without its brackets,
+// the way READLIST and PARSE read a line: everything is data, and the
+// end of input closes the list.
+func LexData(l *Lexer) StateFn {
+	l.push('[')
+	return LexTopLevel
+}
+
+func lexSpace(l *Lexer) StateFn {
+	for {
+		r := l.next()
+		if r == '\n' && !l.inList() {
+			break
+		}
+		if r == EOF || !strings.ContainsRune(spaceChars, r) {
+			break
+		}
+	}
+	l.backup()
+	l.ignore()
+	l.spaced = true
+	return LexTopLevel
+}
+
+func lexComment(l *Lexer) StateFn {
+	for {
+		r := l.next()
+		if r == EOF || r == '\n' {
+			break
+		}
+		if l.atContinuation(r) {
+			return lexContinuation
+		}
+	}
+	l.backup()
+	l.ignore()
+	l.spaced = true
+	return LexTopLevel
+}
+
+func lexContinuation(l *Lexer) StateFn {
+	for i := l.pos; i < len(l.input) && l.input[i] != '\n'; i++ {
+		l.pos++
+	}
+	if l.pos < len(l.input) {
+		l.pos++
+	}
+	l.ignore()
+	l.spaced = true
+	l.joined = true
+	return LexTopLevel
+}
+
+func lexWord(l *Lexer) StateFn {
+	return l.lexEscapable(token.TOKEN_Word, 0, delimiterChars+infixChars)
+}
+
+func lexListWord(l *Lexer) StateFn {
+	return l.lexEscapable(token.TOKEN_Word, 0, delimiterChars)
+}
+
+func lexQuoted(l *Lexer) StateFn {
+	return l.lexEscapable(token.TOKEN_QuotedWord, 1, delimiterChars)
+}
+
+func lexVariable(l *Lexer) StateFn {
+	return l.lexEscapable(token.TOKEN_Variable, 1, delimiterChars+infixChars)
+}
+
+func (l *Lexer) lexEscapable(t token.TokenType, skip int, stop string) StateFn {
+	text, escaped, ok := l.scanWord(l.start+skip, stop)
+	if !ok {
+		return l.errorf("unterminated | in word")
+	}
+	if t == token.TOKEN_Variable && text == "" && !escaped {
+		return l.errorf("missing variable name after :")
+	}
+	l.emitWord(t, text, escaped)
+	return LexTopLevel
+}
+
+func lexNumber(l *Lexer) StateFn {
+	l.acceptRun(digitChars)
+	if l.accept(".") {
+		l.acceptRun(digitChars)
+	}
+	if l.accept("eEnN") {
+		l.accept("+-")
+		l.acceptRun(digitChars)
+	}
+	r := l.peek()
+	if r != EOF && !strings.ContainsRune(delimiterChars+infixChars, r) {
+		return lexWord
+	}
+	if !validNumber(l.input[l.start:l.pos]) {
+		l.emit(token.TOKEN_Word)
+		return LexTopLevel
+	}
+	l.emit(token.TOKEN_Number)
+	return LexTopLevel
+}
+
+func validNumber(text string) bool {
+	text = strings.NewReplacer("n", "e-", "N", "e-").Replace(text)
+	_, err := strconv.ParseFloat(text, 64)
+	return err == nil
+}
+
+func lexOperator(l *Lexer) StateFn {
+	switch r := l.next(); r {
+	case '<':
+		l.accept("=>")
+	case '>':
+		l.accept("=")
+	case '-':
+		if l.unaryMinus() {
+			l.emit(token.TOKEN_UnaryMinus)
+			return LexTopLevel
+		}
+	}
+	tt, _ := token.LookupInfix(l.input[l.start:l.pos])
+	l.emit(tt)
+	return LexTopLevel
+}
+
+// unaryMinus applies the UCBLogo spacing rule: a minus sign is unary
+// when it starts an expression, or when it follows a space and is
+// itself followed by something other than a space.
+func (l *Lexer) unaryMinus() bool {
+	r := l.peek()
+	if r == EOF || strings.ContainsRune(spaceChars, r) {
+		return false
+	}
+	switch {
+	case l.last == token.TOKEN_Newline,
+		l.last == token.TOKEN_LParen,
+		l.last == token.TOKEN_LBracket,
+		l.last == token.TOKEN_UnaryMinus,
+		l.last.IsInfix():
+		return true
+	}
+	return l.spaced
+}
+
+func lexOrigin(l *Lexer) StateFn {
+	if l.peek() != '@' {
+		return LexTopLevel
+	}
+	l.next()
+	l.accept("-")
+	l.acceptRun(digitChars)
+	if l.pos == l.start+1 {
+		return l.errorf("missing array origin after @")
+	}
+	l.emitText(token.TOKEN_Origin, l.input[l.start+1:l.pos])
+	return LexTopLevel
+}
+
+// lexResync skips the rest of a bad token so that lexing can resume at
+// the next space or bracket.
+func lexResync(l *Lexer) StateFn {
+	for {
+		r := l.next()
+		if r == EOF || strings.ContainsRune(spaceChars+"[](){}", r) {
+			l.backup()
+			break
+		}
+	}
+	l.ignore()
+	return LexTopLevel
+}
+
+func lexEOF(l *Lexer) StateFn {
+	l.emit(token.TOKEN_EOF)
+	return lexEOF
 }
@@ -0,0 +1,300 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"gortle/internal/token"
+)
+
+func collect(input string) []token.Token {
+	l := New("test", input, LexTopLevel)
+	var toks []token.Token
+	for {
+		tok := l.GetNextToken()
+		toks = append(toks, tok)
+		if tok.Type == token.TOKEN_EOF || tok.Type == token.TOKEN_Error {
+			return toks
+		}
+	}
+}
+
+func expectTokens(t *testing.T, input string, want []token.Token) {
+	t.Helper()
+	got := collect(input)
+	if len(got) != len(want) {
+		t.Fatalf("%q: expected %d tokens %v, got %d %v", input, len(want), want, len(got), got)
+	}
+	for i := range want {
+		if got[i].Type != want[i].Type || got[i].Text != want[i].Text {
+			t.Errorf("%q: token %d: expected %v, got %v", input, i, want[i], got[i])
+		}
+	}
+}
+
+func tok(tt token.TokenType, text string) token.Token {
+	return token.Token{Type: tt, Text: text}
+}
+
+var eof = tok(token.TOKEN_EOF, "")
+
+// TestLexWords tests bare, quoted and variable words
+func TestLexWords(t *testing.T) {
+	expectTokens(t, `print "hello :name`, []token.Token{
+		tok(token.TOKEN_Word, "print"),
+		tok(token.TOKEN_QuotedWord, "hello"),
+		tok(token.TOKEN_Variable, "name"),
+		eof,
+	})
+	expectTokens(t, `make "a+b :x+1`, []token.Token{
+		tok(token.TOKEN_Word, "make"),
+		tok(token.TOKEN_QuotedWord, "a+b"),
+		tok(token.TOKEN_Variable, "x"),
+		tok(token.TOKEN_Plus, "+"),
+		tok(token.TOKEN_Number, "1"),
+		eof,
+	})
+}
+
+// TestLexNumbers tests integers, decimals and exponents
+func TestLexNumbers(t *testing.T) {
+	expectTokens(t, "3 4.5 .25 1e3 2.5E-2 1n3 3abc", []token.Token{
+		tok(token.TOKEN_Number, "3"),
+		tok(token.TOKEN_Number, "4.5"),
+		tok(token.TOKEN_Number, ".25"),
+		tok(token.TOKEN_Number, "1e3"),
+		tok(token.TOKEN_Number, "2.5E-2"),
+		tok(token.TOKEN_Number, "1n3"),
+		tok(token.TOKEN_Word, "3abc"),
+		eof,
+	})
+}
+
+// TestLexInfix tests infix operators and the unary minus spacing rule
+func TestLexInfix(t *testing.T) {
+	expectTokens(t, "3-4 3 - 4 3 -4 (-4) a<=b a<>b a>=b", []token.Token{
+		tok(token.TOKEN_Number, "3"),
+		tok(token.TOKEN_Minus, "-"),
+		tok(token.TOKEN_Number, "4"),
+		tok(token.TOKEN_Number, "3"),
+		tok(token.TOKEN_Minus, "-"),
+		tok(token.TOKEN_Number, "4"),
+		tok(token.TOKEN_Number, "3"),
+		tok(token.TOKEN_UnaryMinus, "-"),
+		tok(token.TOKEN_Number, "4"),
+		tok(token.TOKEN_LParen, "("),
+		tok(token.TOKEN_UnaryMinus, "-"),
+		tok(token.TOKEN_Number, "4"),
+		tok(token.TOKEN_RParen, ")"),
+		tok(token.TOKEN_Word, "a"),
+		tok(token.TOKEN_LessEqual, "<="),
+		tok(token.TOKEN_Word, "b"),
+		tok(token.TOKEN_Word, "a"),
+		tok(token.TOKEN_NotEqual, "<>"),
+		tok(token.TOKEN_Word, "b"),
+		tok(token.TOKEN_Word, "a"),
+		tok(token.TOKEN_GreaterEqual, ">="),
+		tok(token.TOKEN_Word, "b"),
+		eof,
+	})
+}
+
+// TestLexLists tests that words inside brackets are not split
+func TestLexLists(t *testing.T) {
+	expectTokens(t, "repeat 4 [fd :n+1\n rt 90]", []token.Token{
+		tok(token.TOKEN_Word, "repeat"),
+		tok(token.TOKEN_Number, "4"),
+		tok(token.TOKEN_LBracket, "["),
+		tok(token.TOKEN_Word, "fd"),
+		tok(token.TOKEN_Word, ":n+1"),
+		tok(token.TOKEN_Word, "rt"),
+		tok(token.TOKEN_Word, "90"),
+		tok(token.TOKEN_RBracket, "]"),
+		eof,
+	})
+}
+
+// TestLexArrays tests array braces with an origin
+func TestLexArrays(t *testing.T) {
+	expectTokens(t, "{a b}@0 {c}", []token.Token{
+		tok(token.TOKEN_LBrace, "{"),
+		tok(token.TOKEN_Word, "a"),
+		tok(token.TOKEN_Word, "b"),
+		tok(token.TOKEN_RBrace, "}"),
+		tok(token.TOKEN_Origin, "0"),
+		tok(token.TOKEN_LBrace, "{"),
+		tok(token.TOKEN_Word, "c"),
+		tok(token.TOKEN_RBrace, "}"),
+		eof,
+	})
+}
+
+// TestLexCommentsAndContinuations tests ; comments and ~ line joins
+func TestLexCommentsAndContinuations(t *testing.T) {
+	expectTokens(t, "fd 10 ; move\nrt ~\n 90\n", []token.Token{
+		tok(token.TOKEN_Word, "fd"),
+		tok(token.TOKEN_Number, "10"),
+		tok(token.TOKEN_Newline, "\n"),
+		tok(token.TOKEN_Word, "rt"),
+		tok(token.TOKEN_Number, "90"),
+		tok(token.TOKEN_Newline, "\n"),
+		eof,
+	})
+}
+
+// TestLexPositions tests file, line and column tracking
+func TestLexPositions(t *testing.T) {
+	toks := collect("fd 10\n  rt \"x\n\n:y")
+	want := []token.Pos{
+		{File: "test", Offset: 0, Line: 1, Column: 1},
+		{File: "test", Offset: 3, Line: 1, Column: 4},
+		{File: "test", Offset: 5, Line: 1, Column: 6},
+		{File: "test", Offset: 8, Line: 2, Column: 3},
+		{File: "test", Offset: 11, Line: 2, Column: 6},
+		{File: "test", Offset: 13, Line: 2, Column: 8},
+		{File: "test", Offset: 14, Line: 3, Column: 1},
+		{File: "test", Offset: 15, Line: 4, Column: 1},
+		{File: "test", Offset: 17, Line: 4, Column: 3},
+	}
+	if len(toks) != len(want) {
+		t.Fatalf("Expected %d tokens, got %d: %v", len(want), len(toks), toks)
+	}
+	for i, pos := range want {
+		if toks[i].Pos != pos {
+			t.Errorf("token %v: expected position %+v, got %+v", toks[i], pos, toks[i].Pos)
+		}
+	}
+	if s := toks[3].Pos.String(); s != "test:2:3" {
+		t.Errorf("Expected position string test:2:3, got %s", s)
+	}
+}
+
+// TestLexReader tests lexing incrementally from an io.Reader
+func TestLexReader(t *testing.T) {
+	src := "to square :n\nrepeat 4 [fd :n rt 90]\nend\nsquare 50\n"
+	l := NewReader("stdin", iotest.OneByteReader(strings.NewReader(src)), LexTopLevel)
+	var texts []string
+	for {
+		tok := l.GetNextToken()
+		if tok.Type == token.TOKEN_EOF {
+			break
+		}
+		if tok.Type != token.TOKEN_Newline {
+			texts = append(texts, tok.Text)
+		}
+	}
+	want := "to square n repeat 4 [ fd :n rt 90 ] end square 50"
+	if got := strings.Join(texts, " "); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if l.Incomplete() {
+		t.Error("Expected complete input after END")
+	}
+}
+
+// TestLexIncomplete tests detection of unfinished input
+func TestLexIncomplete(t *testing.T) {
+	cases := map[string]bool{
+		"fd 10\n":            false,
+		"repeat 4 [fd 10\n":  true,
+		"make \"a {1 2\n":    true,
+		"to square :n\nfd 1": true,
+		"fd ~\n":             true,
+		"to sq\nfd 1\nend\n": false,
+	}
+	for src, want := range cases {
+		l := NewReader("stdin", strings.NewReader(src), LexTopLevel)
+		for l.GetNextToken().Type != token.TOKEN_EOF {
+		}
+		if got := l.Incomplete(); got != want {
+			t.Errorf("%q: expected Incomplete()=%v, got %v", src, want, got)
+		}
+	}
+}
+
+// TestLexEscapes tests vertical bar and backslash escapes in words
+func TestLexEscapes(t *testing.T) {
+	toks := collect(`print "|hello world| "a\ b foo|[x]| [a|b c|d] "plain`)
+	want := []struct {
+		tt      token.TokenType
+		text    string
+		escaped bool
+	}{
+		{token.TOKEN_Word, "print", false},
+		{token.TOKEN_QuotedWord, "hello world", true},
+		{token.TOKEN_QuotedWord, "a b", true},
+		{token.TOKEN_Word, "foo[x]", true},
+		{token.TOKEN_LBracket, "[", false},
+		{token.TOKEN_Word, "ab cd", true},
+		{token.TOKEN_RBracket, "]", false},
+		{token.TOKEN_QuotedWord, "plain", false},
+		{token.TOKEN_EOF, "", false},
+	}
+	if len(toks) != len(want) {
+		t.Fatalf("Expected %d tokens, got %d: %v", len(want), len(toks), toks)
+	}
+	for i, w := range want {
+		if toks[i].Type != w.tt || toks[i].Text != w.text || toks[i].Escaped != w.escaped {
+			t.Errorf("token %d: expected %v %q escaped=%v, got %v escaped=%v",
+				i, w.tt, w.text, w.escaped, toks[i], toks[i].Escaped)
+		}
+	}
+
+	toks = collect(`print "|oops`)
+	if last := toks[len(toks)-1]; last.Type != token.TOKEN_Error {
+		t.Errorf("Expected error for unterminated bar, got %v", last)
+	}
+}
+
+// TestLexErrorRecovery tests that lexing continues after errors
+func TestLexErrorRecovery(t *testing.T) {
+	l := New("prog.lg", "fd 10 ]\nprint }x :\nrt 90 \"|open", LexTopLevel)
+	var words []string
+	for {
+		tok := l.GetNextToken()
+		if tok.Type == token.TOKEN_EOF {
+			break
+		}
+		if tok.Type == token.TOKEN_Word || tok.Type == token.TOKEN_Number {
+			words = append(words, tok.Text)
+		}
+	}
+	if got := strings.Join(words, " "); got != "fd 10 print rt 90" {
+		t.Errorf("Expected words after recovery %q, got %q", "fd 10 print rt 90", got)
+	}
+
+	errs := l.Errors()
+	want := []string{
+		"prog.lg:1:7: unexpected ]",
+		"prog.lg:2:7: unexpected }",
+		"prog.lg:2:10: missing variable name after :",
+		"prog.lg:3:7: unterminated | in word",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, w := range want {
+		if errs[i].Error() != w {
+			t.Errorf("error %d: expected %q, got %q", i, w, errs[i].Error())
+		}
+	}
+	if errs.Err() == nil {
+		t.Error("Expected non-nil Err() for a non-empty error list")
+	}
+	if l.GetNextToken().Type != token.TOKEN_EOF {
+		t.Error("Expected EOF to repeat after the end of input")
+	}
+}
+
+// TestLexData tests that LexData reads everything as list contents
+func TestLexData(t *testing.T) {
+	l := New("test", "print 3+4 [a] :x", LexData)
+	var got []string
+	for tok := l.GetNextToken(); tok.Type != token.TOKEN_EOF; tok = l.GetNextToken() {
+		got = append(got, tok.Text)
+	}
+	if want := "print 3+4 [ a ] :x"; strings.Join(got, " ") != want {
+		t.Errorf("Expected %q, got %q", want, strings.Join(got, " "))
+	}
+}
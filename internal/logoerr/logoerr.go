@@ -0,0 +1,98 @@
+package logoerr
+
+import (
+	"errors"
+	"fmt"
+
+	"gortle/internal/token"
+)
+
+// Code is a UCBLogo error code, as reported by ERROR. The codes are
+// numbered as in UCBLogo, so that 13 is an "I don't know how" that ERRACT
+// can recover from and 24 is one it cannot.
+type Code int
+
+const (
+	ERR_Fatal Code = iota
+	ERR_OutOfMemory
+	ERR_StackOverflow
+	ERR_TurtleBounds
+	ERR_BadInputFatal
+	ERR_DidntOutput
+	ERR_NotEnoughInputs
+	ERR_BadInput
+	ERR_TooMuchInParens
+	ERR_DontSay
+	ERR_ParenNotFound
+	ERR_NoValue
+	ERR_UnexpectedParen
+	ERR_DontKnowHow
+	ERR_NoCatchTag
+	ERR_AlreadyDefined
+	ERR_Stopped
+	ERR_AlreadyDribbling
+	ERR_FileSystem
+	ERR_AssumeIfElse
+	ERR_Shadowed
+	ERR_ThrowError
+	ERR_IsPrimitive
+	ERR_ToInProc
+	ERR_DontKnowHowFatal
+	ERR_NoTest
+	ERR_UnexpectedBracket
+	ERR_UnexpectedBrace
+	ERR_Graphics
+	ERR_MacroReturned
+	ERR_DontSayValue
+	ERR_OnlyInProc
+	ERR_ApplyBadInput
+	ERR_EndInInstruction
+	ERR_ReallyOutOfMemory
+)
+
+// Error is a Logo error. Proc is the procedure running when the error
+// happened, empty at top level, and Pos is where in the source it was
+// raised, if known.
+type Error struct {
+	Code    Code
+	Message string
+	Proc    string
+	Pos     token.Pos
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func Errorf(code Code, format string, args ...interface{}) *Error {
+	return New(code, fmt.Sprintf(format, args...))
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if e.Proc != "" {
+		msg += " in " + e.Proc
+	}
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s", e.Pos, msg)
+	}
+	return msg
+}
+
+// Recoverable reports whether a value supplied by ERRACT can stand in
+// for the expression that failed. These are the errors UCBLogo's error
+// list marks as recoverable: codes 5, 7, 11 and 13.
+func (e *Error) Recoverable() bool {
+	switch e.Code {
+	case ERR_DidntOutput, ERR_BadInput, ERR_NoValue, ERR_DontKnowHow:
+		return true
+	}
+	return false
+}
+
+// As returns the Logo error in err's chain, if there is one.
+func As(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
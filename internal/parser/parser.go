@@ -0,0 +1,512 @@
+package parser
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"gortle/internal/ast"
+	"gortle/internal/logoerr"
+	"gortle/internal/thing"
+	"gortle/internal/token"
+)
+
+// ProcTable tells the parser how many inputs a procedure takes. max is
+// negative when the procedure accepts any number of inputs in
+// parentheses.
+type ProcTable interface {
+	Arity(name string) (min, dflt, max int, ok bool)
+}
+
+type TokenSource interface {
+	GetNextToken() token.Token
+}
+
+type Parser struct {
+	src      TokenSource
+	procs    ProcTable
+	declared map[string]*thing.TProc
+	tok      token.Token
+	ahead    []token.Token
+	primed   bool
+}
+
+var infixPrecedence = map[token.TokenType]int{
+	token.TOKEN_Equal:        1,
+	token.TOKEN_Less:         1,
+	token.TOKEN_Greater:      1,
+	token.TOKEN_LessEqual:    1,
+	token.TOKEN_GreaterEqual: 1,
+	token.TOKEN_NotEqual:     1,
+	token.TOKEN_Plus:         2,
+	token.TOKEN_Minus:        2,
+	token.TOKEN_Star:         3,
+	token.TOKEN_Slash:        3,
+}
+
+func New(src TokenSource, procs ProcTable) *Parser {
+	return &Parser{
+		src:      src,
+		procs:    procs,
+		declared: make(map[string]*thing.TProc),
+	}
+}
+
+func (p *Parser) errorf(pos token.Pos, code logoerr.Code, format string, args ...interface{}) *logoerr.Error {
+	err := logoerr.Errorf(code, format, args...)
+	err.Pos = pos
+	return err
+}
+
+// unexpected reports a closing bracket that does not match.
+func (p *Parser) unexpected(tok token.Token) *logoerr.Error {
+	code := logoerr.ERR_UnexpectedBracket
+	if tok.Type == token.TOKEN_RBrace {
+		code = logoerr.ERR_UnexpectedBrace
+	}
+	return p.errorf(tok.Pos, code, "unexpected '%s'", tok.Type)
+}
+
+// lexError turns a lexical error token into a Logo error.
+func (p *Parser) lexError(tok token.Token) *logoerr.Error {
+	switch tok.Text {
+	case "unexpected ]":
+		return p.errorf(tok.Pos, logoerr.ERR_UnexpectedBracket, "%s", tok.Text)
+	case "unexpected }":
+		return p.errorf(tok.Pos, logoerr.ERR_UnexpectedBrace, "%s", tok.Text)
+	}
+	return p.errorf(tok.Pos, logoerr.ERR_BadInput, "%s", tok.Text)
+}
+
+func (p *Parser) current() token.Token {
+	if !p.primed {
+		p.advance()
+	}
+	return p.tok
+}
+
+func (p *Parser) advance() {
+	p.primed = true
+	if len(p.ahead) > 0 {
+		p.tok = p.ahead[0]
+		p.ahead = p.ahead[1:]
+		return
+	}
+	p.tok = p.src.GetNextToken()
+}
+
+// skipLine discards the rest of the current line after an error so
+// that the next instruction starts on a clean line.
+func (p *Parser) skipLine() {
+	for {
+		switch p.current().Type {
+		case token.TOKEN_Newline, token.TOKEN_EOF:
+			return
+		}
+		p.advance()
+	}
+}
+
+// ParseInstruction returns the next top-level instruction, or io.EOF
+// once the input is exhausted. It never reads past the end of the line
+// holding the instruction, so it is safe to use on interactive input.
+func (p *Parser) ParseInstruction() (*ast.ASTNode, error) {
+	for p.current().Type == token.TOKEN_Newline {
+		p.advance()
+	}
+	if p.current().Type == token.TOKEN_EOF {
+		return nil, io.EOF
+	}
+	var node *ast.ASTNode
+	var err error
+	if tok := p.current(); tok.Type == token.TOKEN_Word && strings.EqualFold(tok.Text, "to") {
+		node, err = p.parseProcDef()
+	} else {
+		node, err = p.parseExpr()
+	}
+	if err != nil {
+		p.skipLine()
+		return nil, err
+	}
+	return node, nil
+}
+
+// ParseProgram parses every remaining instruction into a single
+// instruction list.
+func (p *Parser) ParseProgram() (*ast.ASTNode, error) {
+	pos := p.current().Pos
+	var instrs []*ast.ASTNode
+	for {
+		node, err := p.ParseInstruction()
+		if err == io.EOF {
+			return ast.NewInstructionList(pos, instrs), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		instrs = append(instrs, node)
+	}
+}
+
+func (p *Parser) parseExpr() (*ast.ASTNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseInfix(left, 1)
+}
+
+// parseInfix extends left with any infix operators of at least the
+// given precedence.
+func (p *Parser) parseInfix(left *ast.ASTNode, minPrec int) (*ast.ASTNode, error) {
+	for {
+		op := p.current()
+		prec := infixPrecedence[op.Type]
+		if prec == 0 || prec < minPrec {
+			return left, nil
+		}
+		p.advance()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		for infixPrecedence[p.current().Type] > prec {
+			if right, err = p.parseInfix(right, prec+1); err != nil {
+				return nil, err
+			}
+		}
+		left = ast.NewInfix(op.Pos, op.Text, left, right)
+	}
+}
+
+// parseUnary parses an operand, negated if it starts with a minus. A
+// minus the lexer took for an infix one, because a space follows it, is
+// still negation here, where an operand must start, as in "print - 3"
+// or "3 * - 2".
+func (p *Parser) parseUnary() (*ast.ASTNode, error) {
+	tok := p.current()
+	if tok.Type != token.TOKEN_UnaryMinus && tok.Type != token.TOKEN_Minus {
+		return p.parsePrimary()
+	}
+	p.advance()
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return ast.NewNegate(tok.Pos, operand), nil
+}
+
+func (p *Parser) parsePrimary() (*ast.ASTNode, error) {
+	tok := p.current()
+	switch tok.Type {
+	case token.TOKEN_Number:
+		p.advance()
+		return parseNumber(tok)
+	case token.TOKEN_QuotedWord:
+		p.advance()
+		return ast.NewQuoted(tok.Pos, tok.Text, tok.Escaped), nil
+	case token.TOKEN_Variable:
+		p.advance()
+		return ast.NewVariable(tok.Pos, tok.Text), nil
+	case token.TOKEN_LBracket:
+		p.advance()
+		return p.parseList(tok.Pos)
+	case token.TOKEN_LBrace:
+		p.advance()
+		return p.parseArray(tok.Pos)
+	case token.TOKEN_LParen:
+		p.advance()
+		return p.parseParens(tok.Pos)
+	case token.TOKEN_Word:
+		p.advance()
+		return p.parseCall(tok, false)
+	case token.TOKEN_RParen:
+		return nil, p.errorf(tok.Pos, logoerr.ERR_UnexpectedParen, "unexpected ')'")
+	case token.TOKEN_RBracket:
+		return nil, p.errorf(tok.Pos, logoerr.ERR_UnexpectedBracket, "unexpected ']'")
+	case token.TOKEN_RBrace:
+		return nil, p.errorf(tok.Pos, logoerr.ERR_UnexpectedBrace, "unexpected '}'")
+	case token.TOKEN_Error:
+		return nil, p.lexError(tok)
+	case token.TOKEN_Newline, token.TOKEN_EOF:
+		return nil, p.errorf(tok.Pos, logoerr.ERR_NotEnoughInputs, "unexpected end of line")
+	}
+	return nil, p.errorf(tok.Pos, logoerr.ERR_BadInput, "unexpected %s", tok.Type)
+}
+
+func parseNumber(tok token.Token) (*ast.ASTNode, error) {
+	text := strings.NewReplacer("n", "e-", "N", "e-").Replace(tok.Text)
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		err := logoerr.Errorf(logoerr.ERR_BadInput, "bad number: %s", tok.Text)
+		err.Pos = tok.Pos
+		return nil, err
+	}
+	return ast.NewNumber(tok.Pos, tok.Text, value), nil
+}
+
+func (p *Parser) arity(tok token.Token) (min, dflt, max int, err error) {
+	name := strings.ToLower(tok.Text)
+	if min, dflt, max, ok := p.procs.Arity(name); ok {
+		return min, dflt, max, nil
+	}
+	if proc, ok := p.declared[name]; ok {
+		min, dflt, max = proc.Arity()
+		return min, dflt, max, nil
+	}
+	return 0, 0, 0, p.errorf(tok.Pos, logoerr.ERR_DontKnowHow, "I don't know how to %s", tok.Text)
+}
+
+// atArgsEnd reports whether the current token closes the instruction
+// being parsed, so that no further inputs can follow it.
+func (p *Parser) atArgsEnd() bool {
+	switch p.current().Type {
+	case token.TOKEN_EOF, token.TOKEN_Newline, token.TOKEN_RParen,
+		token.TOKEN_RBracket, token.TOKEN_RBrace:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) parseCall(name token.Token, inParens bool) (*ast.ASTNode, error) {
+	min, dflt, max, err := p.arity(name)
+	if err != nil {
+		return nil, err
+	}
+	var args []*ast.ASTNode
+	for {
+		if inParens {
+			if cur := p.current().Type; cur == token.TOKEN_RParen ||
+				infixPrecedence[cur] > 0 && cur != token.TOKEN_Minus {
+				break
+			}
+			if max >= 0 && len(args) >= max {
+				return nil, p.errorf(p.current().Pos, logoerr.ERR_TooMuchInParens, "too much inside ()'s")
+			}
+			if p.atArgsEnd() {
+				return nil, p.errorf(p.current().Pos, logoerr.ERR_ParenNotFound, "')' not found")
+			}
+		} else if len(args) == dflt {
+			break
+		}
+		if p.atArgsEnd() {
+			return nil, p.errorf(name.Pos, logoerr.ERR_NotEnoughInputs, "not enough inputs to %s", name.Text)
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) < min {
+		return nil, p.errorf(name.Pos, logoerr.ERR_NotEnoughInputs, "not enough inputs to %s", name.Text)
+	}
+	return ast.NewCall(name.Pos, strings.ToLower(name.Text), args), nil
+}
+
+func (p *Parser) parseParens(pos token.Pos) (*ast.ASTNode, error) {
+	var node *ast.ASTNode
+	var err error
+	if tok := p.current(); tok.Type == token.TOKEN_Word {
+		p.advance()
+		if node, err = p.parseCall(tok, true); err == nil {
+			node, err = p.parseInfix(node, 1)
+		}
+	} else {
+		node, err = p.parseExpr()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.current().Type != token.TOKEN_RParen {
+		if p.atArgsEnd() {
+			return nil, p.errorf(pos, logoerr.ERR_ParenNotFound, "')' not found")
+		}
+		return nil, p.errorf(p.current().Pos, logoerr.ERR_TooMuchInParens, "too much inside ()'s")
+	}
+	p.advance()
+	return node, nil
+}
+
+func (p *Parser) parseList(pos token.Pos) (*ast.ASTNode, error) {
+	items, err := p.parseData(token.TOKEN_RBracket, pos)
+	if err != nil {
+		return nil, err
+	}
+	return ast.NewList(pos, items), nil
+}
+
+func (p *Parser) parseArray(pos token.Pos) (*ast.ASTNode, error) {
+	items, err := p.parseData(token.TOKEN_RBrace, pos)
+	if err != nil {
+		return nil, err
+	}
+	origin := ast.DefaultOrigin
+	if tok := p.current(); tok.Type == token.TOKEN_Origin {
+		p.advance()
+		if origin, err = strconv.Atoi(tok.Text); err != nil {
+			return nil, p.errorf(tok.Pos, logoerr.ERR_BadInput, "bad array origin: %s", tok.Text)
+		}
+	}
+	return ast.NewArray(pos, items, origin), nil
+}
+
+// parseData reads the words, lists and arrays of a literal up to its
+// closing bracket. Nothing inside a literal is evaluated.
+func (p *Parser) parseData(closer token.TokenType, pos token.Pos) ([]*ast.ASTNode, error) {
+	items := []*ast.ASTNode{}
+	for {
+		tok := p.current()
+		switch tok.Type {
+		case closer:
+			p.advance()
+			return items, nil
+		case token.TOKEN_LBracket:
+			p.advance()
+			item, err := p.parseList(tok.Pos)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		case token.TOKEN_LBrace:
+			p.advance()
+			item, err := p.parseArray(tok.Pos)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		case token.TOKEN_RBracket, token.TOKEN_RBrace:
+			return nil, p.unexpected(tok)
+		case token.TOKEN_EOF:
+			return nil, p.errorf(pos, logoerr.ERR_BadInput, "'%s' not found", closer)
+		case token.TOKEN_Error:
+			return nil, p.lexError(tok)
+		case token.TOKEN_Newline:
+			p.advance()
+		default:
+			p.advance()
+			items = append(items, ast.NewWord(tok.Pos, tok.Text, tok.Escaped))
+		}
+	}
+}
+
+// parseProcDef reads a TO header and the lines up to the matching END.
+// The body is kept as data and parsed each time the procedure is
+// called, so it may refer to procedures that are defined later.
+func (p *Parser) parseProcDef() (*ast.ASTNode, error) {
+	to := p.current()
+	p.advance()
+	name := p.current()
+	switch name.Type {
+	case token.TOKEN_Word:
+	case token.TOKEN_Newline, token.TOKEN_EOF:
+		return nil, p.errorf(to.Pos, logoerr.ERR_NotEnoughInputs, "not enough inputs to to")
+	default:
+		return nil, p.errorf(name.Pos, logoerr.ERR_BadInput, "to doesn't like %s as input", name.Text)
+	}
+	p.advance()
+
+	params := []*ast.ASTNode{}
+	for !p.atArgsEnd() {
+		tok := p.current()
+		switch tok.Type {
+		case token.TOKEN_Variable, token.TOKEN_Word, token.TOKEN_Number:
+			p.advance()
+			params = append(params, ast.NewWord(tok.Pos, tok.Text, tok.Escaped))
+		case token.TOKEN_LBracket:
+			p.advance()
+			spec, err := p.parseList(tok.Pos)
+			if err != nil {
+				return nil, err
+			}
+			if len(spec.Children) > 0 {
+				first := spec.Children[0]
+				first.Text = strings.TrimPrefix(first.Text, ":")
+			}
+			params = append(params, spec)
+		default:
+			return nil, p.errorf(tok.Pos, logoerr.ERR_BadInput, "to doesn't like %s as input", tok.Text)
+		}
+	}
+
+	var lines []*ast.ASTNode
+	for {
+		for p.current().Type == token.TOKEN_Newline {
+			p.advance()
+		}
+		tok := p.current()
+		if tok.Type == token.TOKEN_EOF {
+			return nil, p.errorf(to.Pos, logoerr.ERR_BadInput, "end not found for %s", name.Text)
+		}
+		if tok.Type == token.TOKEN_Word && strings.EqualFold(tok.Text, "end") {
+			p.advance()
+			break
+		}
+		line, err := p.parseLineData()
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	def := ast.NewProcDef(to.Pos, strings.ToLower(name.Text), ast.NewList(to.Pos, params), lines)
+	proc, err := thing.NewProcFromDef(def)
+	if err != nil {
+		if lerr, ok := logoerr.As(err); ok {
+			lerr.Pos = to.Pos
+			return nil, lerr
+		}
+		return nil, p.errorf(to.Pos, logoerr.ERR_BadInput, "%v", err)
+	}
+	p.declared[def.Name] = proc.Value().(*thing.TProc)
+	return def, nil
+}
+
+// parseLineData turns the rest of a line into a list of words, the way
+// UCBLogo reads procedure bodies. Tokens written without spaces between
+// them, such as :n+1, are joined back into a single word.
+func (p *Parser) parseLineData() (*ast.ASTNode, error) {
+	pos := p.current().Pos
+	items := []*ast.ASTNode{}
+	var last *ast.ASTNode
+	for {
+		tok := p.current()
+		switch tok.Type {
+		case token.TOKEN_Newline, token.TOKEN_EOF:
+			return ast.NewList(pos, items), nil
+		case token.TOKEN_LBracket, token.TOKEN_LBrace:
+			item, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			last = nil
+			continue
+		case token.TOKEN_LParen, token.TOKEN_RParen:
+			p.advance()
+			items = append(items, ast.NewWord(tok.Pos, tok.Text, false))
+			last = nil
+			continue
+		case token.TOKEN_RBracket, token.TOKEN_RBrace:
+			return nil, p.unexpected(tok)
+		case token.TOKEN_Error:
+			return nil, p.lexError(tok)
+		}
+
+		p.advance()
+		text := tok.Text
+		switch tok.Type {
+		case token.TOKEN_QuotedWord:
+			text = "\"" + text
+		case token.TOKEN_Variable:
+			text = ":" + text
+		}
+		if last != nil && !tok.Spaced {
+			last.Text += text
+			last.Escaped = last.Escaped || tok.Escaped
+			continue
+		}
+		last = ast.NewWord(tok.Pos, text, tok.Escaped)
+		items = append(items, last)
+	}
+}
@@ -0,0 +1,234 @@
+package parser
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"gortle/internal/lexer"
+	"gortle/internal/thing"
+)
+
+type arity struct {
+	min, dflt, max int
+}
+
+type testProcs map[string]arity
+
+func (tp testProcs) Arity(name string) (int, int, int, bool) {
+	a, ok := tp[name]
+	return a.min, a.dflt, a.max, ok
+}
+
+var procs = testProcs{
+	"fd":     {1, 1, 1},
+	"rt":     {1, 1, 1},
+	"print":  {1, 1, -1},
+	"sum":    {2, 2, -1},
+	"repeat": {2, 2, 2},
+	"pi":     {0, 0, 0},
+	"first":  {1, 1, 1},
+}
+
+func parse(src string) (string, error) {
+	l := lexer.New("test", src, lexer.LexTopLevel)
+	prog, err := New(l, procs).ParseProgram()
+	if err != nil {
+		return "", err
+	}
+	return prog.String(), nil
+}
+
+func expectParse(t *testing.T, src, want string) {
+	t.Helper()
+	got, err := parse(src)
+	if err != nil {
+		t.Fatalf("%q: unexpected error: %v", src, err)
+	}
+	if got != want {
+		t.Errorf("%q: expected %s, got %s", src, want, got)
+	}
+}
+
+func expectError(t *testing.T, src, want string) {
+	t.Helper()
+	_, err := parse(src)
+	if err == nil {
+		t.Fatalf("%q: expected error %q, got none", src, want)
+	}
+	if err.Error() != want {
+		t.Errorf("%q: expected error %q, got %q", src, want, err.Error())
+	}
+}
+
+// TestParseArity tests that calls consume their default number of inputs
+func TestParseArity(t *testing.T) {
+	expectParse(t, "fd 100 rt 90", "(fd 100) (rt 90)")
+	expectParse(t, "print sum 1 sum 2 3", "(print (sum 1 (sum 2 3)))")
+	expectParse(t, "repeat 4 [fd 100 rt 90]", "(repeat 4 [fd 100 rt 90])")
+	expectParse(t, "print first [a b] print pi", "(print (first [a b])) (print (pi))")
+}
+
+// TestParseParens tests variadic calls and grouping with parentheses
+func TestParseParens(t *testing.T) {
+	expectParse(t, "print (sum 1 2 3 4)", "(print (sum 1 2 3 4))")
+	expectParse(t, "(print \"a \"b)", "(print \"a \"b)")
+	expectParse(t, "print (1 + 2) * 3", "(print (* (+ 1 2) 3))")
+	expectParse(t, "print (pi * 2)", "(print (* (pi) 2))")
+	expectParse(t, "print (sum 1 2) + 3", "(print (+ (sum 1 2) 3))")
+}
+
+// TestParseInfix tests operator precedence and unary minus
+func TestParseInfix(t *testing.T) {
+	expectParse(t, "print 1 + 2 * 3 - 4", "(print (- (+ 1 (* 2 3)) 4))")
+	expectParse(t, "print :a + 1 = :b * 2", "(print (= (+ :a 1) (* :b 2)))")
+	expectParse(t, "fd 10 + 20", "(fd (+ 10 20))")
+	expectParse(t, "print sum 1 2 + 3", "(print (sum 1 (+ 2 3)))")
+	expectParse(t, "print (sum 3 -4)", "(print (sum 3 (- 4)))")
+	expectParse(t, "print 3 - -4", "(print (- 3 (- 4)))")
+	expectParse(t, "print 8 / 2 / 2", "(print (/ (/ 8 2) 2))")
+}
+
+// TestParseNegation tests a minus followed by a space where an operand
+// starts, which UCBLogo reads as negation
+func TestParseNegation(t *testing.T) {
+	expectParse(t, "print - 3", "(print (- 3))")
+	expectParse(t, "print (- :x)", "(print (- :x))")
+	expectParse(t, "print (- 3 + 1)", "(print (+ (- 3) 1))")
+	expectParse(t, "print 3 * - 2", "(print (* 3 (- 2)))")
+	expectParse(t, "(print - 3 4)", "(print (- 3) 4)")
+	expectParse(t, "print 5 - 3", "(print (- 5 3))")
+	expectParse(t, "print 5 - - 3", "(print (- 5 (- 3)))")
+}
+
+// TestParseLiterals tests quoted words, lists and arrays
+func TestParseLiterals(t *testing.T) {
+	expectParse(t, `print "hello`, `(print "hello)`)
+	expectParse(t, "print [a [b c] {d e}@0]", "(print [a [b c] {d e}@0])")
+	expectParse(t, "print {1 2}", "(print {1 2})")
+}
+
+// TestParseErrors tests error messages and positions
+func TestParseErrors(t *testing.T) {
+	expectError(t, "fd", "test:1:1: not enough inputs to fd")
+	expectError(t, "fd 10\nfoo 3", "test:2:1: I don't know how to foo")
+	expectError(t, "print (sum 1 2", "test:1:15: ')' not found")
+	expectError(t, "print (fd 1 2)", "test:1:13: too much inside ()'s")
+	expectError(t, "print 1)", "test:1:8: unexpected ')'")
+	expectError(t, "print [a b", "test:1:7: ']' not found")
+	expectError(t, "fd\n100", "test:1:1: not enough inputs to fd")
+}
+
+// TestParseInstruction tests instruction-at-a-time parsing and recovery
+func TestParseInstruction(t *testing.T) {
+	l := lexer.New("test", "fd 1 foo 2\nrt 3\n", lexer.LexTopLevel)
+	p := New(l, procs)
+	var got []string
+	for {
+		node, err := p.ParseInstruction()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			got = append(got, "error")
+			continue
+		}
+		got = append(got, node.String())
+	}
+	if s := strings.Join(got, " "); s != "(fd 1) error (rt 3)" {
+		t.Errorf("Expected %q, got %q", "(fd 1) error (rt 3)", s)
+	}
+}
+
+// TestParseProcDef tests TO ... END definitions and their arity
+func TestParseProcDef(t *testing.T) {
+	src := "to poly :side :count [:color \"red] 2\n" +
+		"repeat :count [fd :side rt 360/:count]\n" +
+		"print :side+1\n" +
+		"end\n" +
+		"poly 10 5\n" +
+		"(poly 10 5 \"blue)\n"
+	expectParse(t, src,
+		`(to poly [side count [color "red] 2] [repeat :count [fd :side rt 360/:count]] [print :side+1]) `+
+			`(poly 10 5) (poly 10 5 "blue)`)
+
+	expectParse(t, "to spread [:items]\nend\nprint (spread 1 2 3)",
+		"(to spread [[items]]) (print (spread 1 2 3))")
+	expectError(t, "to broken :a\nfd :a\n", "test:1:1: end not found for broken")
+	expectError(t, "to bad [:a 1] :b\nend", "test:1:1: to: required input b after optional inputs in bad")
+}
+
+// TestParseList tests that list contents are re-read as code, with
+// positions taken from the words of the list
+func TestParseList(t *testing.T) {
+	l := lexer.New("test", "repeat 2 [fd :n+1 print \"|a b| [x y]]", lexer.LexTopLevel)
+	prog, err := New(l, procs).ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram failed: %v", err)
+	}
+	body := prog.Children[0].Children[1]
+
+	instrs, err := ParseList(body, procs)
+	if err != nil {
+		t.Fatalf("ParseList failed: %v", err)
+	}
+	var got []string
+	for _, instr := range instrs {
+		got = append(got, instr.String())
+	}
+	if want := "(fd (+ :n 1)) (print \"a b) [x y]"; strings.Join(got, " ") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, " "))
+	}
+	if pos := instrs[0].Children[0].Children[1].Pos; pos.Line != 1 || pos.Column != 17 {
+		t.Errorf("Expected 1 at 1:17, got %s", pos)
+	}
+}
+
+// TestReadListRoundTrip tests that values printed with escapes read
+// back as equal values
+func TestReadListRoundTrip(t *testing.T) {
+	word := func(text string, escaped bool) *thing.Thing { return thing.NewWord(text, escaped) }
+	arr, _ := thing.NewArray([]int{2}, 0, false)
+	values := thing.TList{
+		word("a b", true),
+		word("", true),
+		word("3", true),
+		word("x|y\\z", true),
+		thing.NewNumber(2.5, false),
+		thing.New(thing.TList{word("[", true), word("c", false)}, thing.TagTList, false),
+		arr,
+	}
+	text := thing.New(values, thing.TagTList, false).Format(false, true)
+	if want := `|a b| || |3| |x\|y\\z| 2.5 [|[| c] {[] []}@0`; text != want {
+		t.Errorf("Expected %s, got %s", want, text)
+	}
+	datum, err := ReadList("test", text)
+	if err != nil {
+		t.Fatalf("ReadList failed: %v", err)
+	}
+	got := thing.FromDatum(datum).Value().(thing.TList)
+	if len(got) != len(values) {
+		t.Fatalf("Expected %d values, got %d", len(values), len(got))
+	}
+	for i := range values[:len(values)-1] {
+		if !got[i].Equal(values[i], false) {
+			t.Errorf("Expected %s, got %s", values[i].Format(true, true), got[i].Format(true, true))
+		}
+	}
+	if _, err := ReadList("test", "a ] b"); err == nil {
+		t.Errorf("Expected an error for an unmatched ]")
+	}
+}
+
+// TestRunParse tests that RunParse splits words into tokens and leaves
+// lists alone
+func TestRunParse(t *testing.T) {
+	datum, err := ReadList("test", `fd :n+1 (print "a) [x+y] -3 3-4 - :x`)
+	if err != nil {
+		t.Fatalf("ReadList failed: %v", err)
+	}
+	got := thing.FromDatum(RunParse(datum)).String()
+	if want := `[fd :n + 1 ( print "a ) [x+y] -3 3 - 4 - :x]`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,175 @@
+package parser
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"gortle/internal/ast"
+	"gortle/internal/lexer"
+	"gortle/internal/token"
+)
+
+// tokenSlice replays a fixed run of tokens, then reports EOF forever.
+type tokenSlice struct {
+	toks []token.Token
+	eof  token.Token
+}
+
+func (s *tokenSlice) GetNextToken() token.Token {
+	if len(s.toks) == 0 {
+		return s.eof
+	}
+	tok := s.toks[0]
+	s.toks = s.toks[1:]
+	return tok
+}
+
+// ParseList parses the contents of a list as instructions, the way RUN
+// sees them. Every word of the list is read again as Logo source, so
+// [fd :n+1] runs exactly like the line fd :n+1. Positions are taken
+// from the words, so errors point into the file the list came from.
+func ParseList(datum *ast.ASTNode, procs ProcTable) ([]*ast.ASTNode, error) {
+	src := &tokenSlice{eof: token.Token{Type: token.TOKEN_EOF, Pos: datum.Pos}}
+	for _, item := range datum.Children {
+		src.toks = appendRunTokens(src.toks, item)
+	}
+
+	p := New(src, procs)
+	var instrs []*ast.ASTNode
+	for {
+		node, err := p.ParseInstruction()
+		if err == io.EOF {
+			return instrs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		instrs = append(instrs, node)
+	}
+}
+
+// appendRunTokens appends the tokens of one list item read as code.
+func appendRunTokens(toks []token.Token, item *ast.ASTNode) []token.Token {
+	if item.Type != ast.NODE_Word {
+		return appendDataTokens(toks, item)
+	}
+
+	if item.Escaped {
+		tok := token.Token{Type: token.TOKEN_Word, Text: item.Text, Pos: item.Pos, Escaped: true, Spaced: true}
+		switch {
+		case strings.HasPrefix(item.Text, "\""):
+			tok.Type, tok.Text = token.TOKEN_QuotedWord, item.Text[1:]
+		case strings.HasPrefix(item.Text, ":"):
+			tok.Type, tok.Text = token.TOKEN_Variable, item.Text[1:]
+		}
+		return append(toks, tok)
+	}
+
+	l := lexer.New(item.Pos.File, item.Text, lexer.LexTopLevel)
+	first := true
+	for {
+		tok := l.GetNextToken()
+		if tok.Type == token.TOKEN_EOF {
+			return toks
+		}
+		if tok.Type == token.TOKEN_Newline {
+			continue
+		}
+		tok.Pos = shiftPos(item.Pos, tok.Pos)
+		tok.Spaced = first
+		first = false
+		toks = append(toks, tok)
+	}
+}
+
+// appendDataTokens appends the tokens of a list or array literal found
+// inside a list being run. Its contents stay data.
+func appendDataTokens(toks []token.Token, item *ast.ASTNode) []token.Token {
+	switch item.Type {
+	case ast.NODE_List:
+		toks = append(toks, token.Token{Type: token.TOKEN_LBracket, Text: "[", Pos: item.Pos, Spaced: true})
+		for _, child := range item.Children {
+			toks = appendDataTokens(toks, child)
+		}
+		return append(toks, token.Token{Type: token.TOKEN_RBracket, Text: "]", Pos: item.Pos})
+	case ast.NODE_Array:
+		toks = append(toks, token.Token{Type: token.TOKEN_LBrace, Text: "{", Pos: item.Pos, Spaced: true})
+		for _, child := range item.Children {
+			toks = appendDataTokens(toks, child)
+		}
+		toks = append(toks, token.Token{Type: token.TOKEN_RBrace, Text: "}", Pos: item.Pos})
+		if item.Origin != ast.DefaultOrigin {
+			toks = append(toks, token.Token{Type: token.TOKEN_Origin, Text: strconv.Itoa(item.Origin), Pos: item.Pos})
+		}
+		return toks
+	case ast.NODE_Number:
+		return append(toks, token.Token{Type: token.TOKEN_Number, Text: item.Text, Pos: item.Pos, Spaced: true})
+	}
+	return append(toks, token.Token{Type: token.TOKEN_Word, Text: item.Text, Pos: item.Pos, Escaped: item.Escaped, Spaced: true})
+}
+
+// shiftPos makes a position within a word relative to the word itself.
+func shiftPos(word, rel token.Pos) token.Pos {
+	if !word.IsValid() {
+		return token.Pos{}
+	}
+	return token.Pos{
+		File:   word.File,
+		Offset: word.Offset + rel.Offset,
+		Line:   word.Line,
+		Column: word.Column + rel.Column - 1,
+	}
+}
+
+// ReadList reads text as the contents of a list, the way READLIST and
+// PARSE do. Nothing in it is evaluated, and words stay as written.
+func ReadList(name, text string) (*ast.ASTNode, error) {
+	pos := token.Pos{File: name, Line: 1, Column: 1}
+	p := New(lexer.New(name, text, lexer.LexData), nil)
+	items, err := p.parseData(token.TOKEN_EOF, pos)
+	if err != nil {
+		return nil, err
+	}
+	return ast.NewList(pos, items), nil
+}
+
+// RunParse splits the words of a list into the tokens RUN would see, as
+// RUNPARSE outputs them: infix operators and parentheses become words of
+// their own, and quoted words and variables keep their " and :. Lists
+// and arrays inside are left alone.
+func RunParse(datum *ast.ASTNode) *ast.ASTNode {
+	var items []*ast.ASTNode
+	for _, item := range datum.Children {
+		if item.Type != ast.NODE_Word || item.Escaped {
+			items = append(items, item)
+			continue
+		}
+		var minus *token.Token
+		for _, tok := range appendRunTokens(nil, item) {
+			text := tok.Text
+			switch tok.Type {
+			case token.TOKEN_UnaryMinus:
+				minus = &tok
+				continue
+			case token.TOKEN_QuotedWord:
+				text = "\"" + text
+			case token.TOKEN_Variable:
+				text = ":" + text
+			}
+			if minus != nil {
+				if tok.Type == token.TOKEN_Number {
+					text = "-" + text
+				} else {
+					items = append(items, ast.NewWord(minus.Pos, "-", false))
+				}
+				minus = nil
+			}
+			items = append(items, ast.NewWord(tok.Pos, text, tok.Escaped))
+		}
+		if minus != nil {
+			items = append(items, ast.NewWord(minus.Pos, "-", false))
+		}
+	}
+	return ast.NewList(datum.Pos, items)
+}
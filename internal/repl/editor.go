@@ -0,0 +1,372 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ErrInterrupt is returned by ReadLine when the line is abandoned with
+// Ctrl-C.
+var ErrInterrupt = errors.New("interrupted")
+
+// ErrCancelled is returned by ReadLine when Cancel abandons the line.
+var ErrCancelled = errors.New("cancelled")
+
+// maxHistory is the number of lines kept in the history file.
+const maxHistory = 1000
+
+// Editor reads lines typed at a terminal, with cursor movement, the
+// usual Emacs-style editing keys and a history recalled with the up and
+// down arrows. When its input is not a terminal it reads plain lines.
+type Editor struct {
+	r       *bufio.Reader
+	out     io.Writer
+	term    *os.File
+	editing bool
+	restore func()
+	history []string
+
+	// keys gets the rune read by the read running on its own
+	// goroutine, if reading is set, so that waiting for it can be
+	// abandoned. cancel holds a Cancel not yet seen, and cancelled is
+	// set once the current line has seen one.
+	keys      chan key
+	reading   bool
+	cancel    chan struct{}
+	cancelled bool
+}
+
+// key is a rune read from the input, or the error that stopped it.
+type key struct {
+	r   rune
+	err error
+}
+
+// NewEditor makes an editor reading keys from in and echoing to out.
+func NewEditor(in io.Reader, out io.Writer) *Editor {
+	e := &Editor{
+		r:      bufio.NewReader(in),
+		out:    out,
+		keys:   make(chan key, 1),
+		cancel: make(chan struct{}, 1),
+	}
+	if f, ok := in.(*os.File); ok && isTerminal(f) {
+		e.term, e.editing = f, true
+	}
+	return e
+}
+
+// ReadLine shows prompt and reads a line, without its newline. It
+// returns io.EOF at the end of input, or for Ctrl-D on an empty line,
+// ErrInterrupt for Ctrl-C and ErrCancelled once Cancel is called.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	e.cancelled = false
+	if _, err := io.WriteString(e.out, prompt); err != nil {
+		return "", err
+	}
+	if !e.editing {
+		return e.readPlain()
+	}
+	if e.term != nil {
+		restore, err := makeRaw(e.term)
+		if err != nil {
+			return e.readPlain()
+		}
+		e.restore = restore
+		defer e.Close()
+	}
+	line, err := e.edit()
+	if err == nil {
+		e.Add(line)
+	}
+	return line, err
+}
+
+// Cancel makes the ReadLine running on another goroutine, or the next
+// one, return ErrCancelled. A key being waited for is not lost: it goes
+// to the ReadLine after that.
+func (e *Editor) Cancel() {
+	select {
+	case e.cancel <- struct{}{}:
+	default:
+	}
+}
+
+// readRune reads a rune on another goroutine and waits for it, or for
+// Cancel, after which it keeps returning ErrCancelled until the line
+// is given up. Only that goroutine touches the input, and only one read
+// is running at a time.
+func (e *Editor) readRune() (rune, error) {
+	if e.cancelled {
+		return 0, ErrCancelled
+	}
+	if !e.reading {
+		e.reading = true
+		go func() {
+			r, _, err := e.r.ReadRune()
+			e.keys <- key{r, err}
+		}()
+	}
+	select {
+	case k := <-e.keys:
+		e.reading = false
+		return k.r, k.err
+	case <-e.cancel:
+		e.cancelled = true
+		return 0, ErrCancelled
+	}
+}
+
+// Close puts the terminal back the way it was, if a ReadLine left it
+// raw.
+func (e *Editor) Close() {
+	if e.restore != nil {
+		e.restore()
+		e.restore = nil
+	}
+}
+
+func (e *Editor) readPlain() (string, error) {
+	var sb strings.Builder
+	for {
+		r, err := e.readRune()
+		switch {
+		case err == nil && r == '\n', err == io.EOF && sb.Len() > 0:
+			return strings.TrimRight(sb.String(), "\r"), nil
+		case err != nil:
+			return "", err
+		}
+		sb.WriteRune(r)
+	}
+}
+
+// line is the text being edited and the cursor position in it.
+type line struct {
+	buf []rune
+	pos int
+	// shown is how far the cursor is from the end of the prompt on the
+	// screen, so that redrawing can go back to the start of the line
+	// without knowing what the prompt was.
+	shown int
+}
+
+func (e *Editor) edit() (string, error) {
+	var l line
+	hist := len(e.history)
+	saved := ""
+	for {
+		r, err := e.readRune()
+		if err != nil {
+			switch {
+			case err == io.EOF && len(l.buf) > 0:
+				fmt.Fprint(e.out, "\n")
+				return string(l.buf), nil
+			case err == ErrCancelled:
+				fmt.Fprint(e.out, "\n")
+			}
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			l.pos = len(l.buf)
+			e.refresh(&l)
+			fmt.Fprint(e.out, "\n")
+			return string(l.buf), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\n")
+			return "", ErrInterrupt
+		case 4: // Ctrl-D
+			if len(l.buf) == 0 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+			l.delete(l.pos, l.pos+1)
+		case 1: // Ctrl-A
+			l.pos = 0
+		case 5: // Ctrl-E
+			l.pos = len(l.buf)
+		case 2: // Ctrl-B
+			l.move(-1)
+		case 6: // Ctrl-F
+			l.move(1)
+		case 8, 127: // backspace
+			if l.pos > 0 {
+				l.delete(l.pos-1, l.pos)
+			}
+		case 11: // Ctrl-K
+			l.delete(l.pos, len(l.buf))
+		case 21: // Ctrl-U
+			l.delete(0, l.pos)
+		case 23: // Ctrl-W
+			start := l.pos
+			for start > 0 && unicode.IsSpace(l.buf[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(l.buf[start-1]) {
+				start--
+			}
+			l.delete(start, l.pos)
+		case 16, 14: // Ctrl-P, Ctrl-N
+			hist, saved = e.recall(&l, hist, saved, r == 16)
+		case 27:
+			switch e.escape() {
+			case 'A':
+				hist, saved = e.recall(&l, hist, saved, true)
+			case 'B':
+				hist, saved = e.recall(&l, hist, saved, false)
+			case 'C':
+				l.move(1)
+			case 'D':
+				l.move(-1)
+			case 'H':
+				l.pos = 0
+			case 'F':
+				l.pos = len(l.buf)
+			case '~':
+				l.delete(l.pos, l.pos+1)
+			}
+		default:
+			if unicode.IsPrint(r) || r == '\t' {
+				l.buf = append(l.buf[:l.pos], append([]rune{r}, l.buf[l.pos:]...)...)
+				l.pos++
+			}
+		}
+		e.refresh(&l)
+	}
+}
+
+// escape reads the rest of an escape sequence and returns the key it
+// stands for: the final letter of an arrow or Home and End key, or '~'
+// for Delete. Other sequences are read and ignored.
+func (e *Editor) escape() rune {
+	r, err := e.readRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return 0
+	}
+	var params strings.Builder
+	for {
+		r, err = e.readRune()
+		if err != nil {
+			return 0
+		}
+		if !(r >= '0' && r <= '9' || r == ';') {
+			break
+		}
+		params.WriteRune(r)
+	}
+	switch {
+	case r == '~' && params.String() == "3":
+		return '~'
+	case r == '~' && (params.String() == "1" || params.String() == "7"):
+		return 'H'
+	case r == '~' && (params.String() == "4" || params.String() == "8"):
+		return 'F'
+	case r == '~':
+		return 0
+	}
+	return r
+}
+
+// recall replaces the line with an older history entry, or a newer one
+// if older is false. Going past the newest entry gets back the line
+// that was being typed.
+func (e *Editor) recall(l *line, hist int, saved string, older bool) (int, string) {
+	switch {
+	case older && hist > 0:
+		if hist == len(e.history) {
+			saved = string(l.buf)
+		}
+		hist--
+		l.set(e.history[hist])
+	case !older && hist < len(e.history):
+		hist++
+		if hist == len(e.history) {
+			l.set(saved)
+		} else {
+			l.set(e.history[hist])
+		}
+	}
+	return hist, saved
+}
+
+func (l *line) set(text string) {
+	l.buf = []rune(text)
+	l.pos = len(l.buf)
+}
+
+func (l *line) move(by int) {
+	l.pos = max(0, min(len(l.buf), l.pos+by))
+}
+
+func (l *line) delete(from, to int) {
+	if to > len(l.buf) {
+		to = len(l.buf)
+	}
+	if from >= to {
+		return
+	}
+	l.buf = append(l.buf[:from], l.buf[to:]...)
+	l.pos = from
+}
+
+// refresh redraws the line after the prompt and puts the cursor back
+// where it belongs.
+func (e *Editor) refresh(l *line) {
+	var sb strings.Builder
+	if l.shown > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dD", l.shown)
+	}
+	sb.WriteString(string(l.buf))
+	sb.WriteString("\x1b[K")
+	if back := len(l.buf) - l.pos; back > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dD", back)
+	}
+	l.shown = l.pos
+	io.WriteString(e.out, sb.String())
+}
+
+// Add puts a line on the end of the history, unless it is blank or the
+// same as the last one.
+func (e *Editor) Add(text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == text {
+		return
+	}
+	e.history = append(e.history, text)
+}
+
+// LoadHistory reads the history saved in a file. A missing file is not
+// an error.
+func (e *Editor) LoadHistory(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, text := range strings.Split(string(data), "\n") {
+		e.Add(text)
+	}
+	return nil
+}
+
+// SaveHistory writes the most recent lines of the history to a file.
+func (e *Editor) SaveHistory(path string) error {
+	lines := e.history
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+	}
+	var sb strings.Builder
+	for _, text := range lines {
+		sb.WriteString(text + "\n")
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0o600)
+}
@@ -0,0 +1,222 @@
+// Package repl runs Logo interactively: it prompts for instructions,
+// runs them as they are finished and keeps a graphics window alive
+// while it waits.
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"gortle/internal/eval"
+	"gortle/internal/lexer"
+	"gortle/internal/logoerr"
+	"gortle/internal/parser"
+	"gortle/internal/token"
+)
+
+const (
+	prompt         = "? "
+	continuePrompt = "~ "
+	// idleInterval is how often Idle is called, about once a frame.
+	idleInterval = 16 * time.Millisecond
+)
+
+// errStopped abandons the instructions running when the user presses
+// Ctrl-C.
+var errStopped = errors.New("Stopping...")
+
+// errQuit ends the session when Idle asks to.
+var errQuit = errors.New("quit")
+
+// Repl reads instructions from an Editor and runs them in an
+// interpreter.
+type Repl struct {
+	in  *eval.Interp
+	ed  *Editor
+	out io.Writer
+
+	// Idle is called about once a frame while the REPL waits for input
+	// and while instructions run, to keep a window responsive. It
+	// returns false to end the session.
+	Idle func() bool
+	// Delay is a pause before each instruction, to slow a program down
+	// enough to watch it draw.
+	Delay time.Duration
+
+	lastIdle  time.Time
+	quit      bool
+	interrupt chan os.Signal
+}
+
+// New makes a REPL that runs what is typed at ed in in, writing
+// prompts and errors to out.
+func New(in *eval.Interp, ed *Editor, out io.Writer) *Repl {
+	return &Repl{in: in, ed: ed, out: out}
+}
+
+// Run prompts for and runs instructions until the end of input or
+// until Idle returns false. Ctrl-C abandons the line being typed or
+// the instructions running and prompts again.
+func (r *Repl) Run() error {
+	r.interrupt = make(chan os.Signal, 1)
+	signal.Notify(r.interrupt, os.Interrupt)
+	defer signal.Stop(r.interrupt)
+
+	r.in.SetPoll(r.poll)
+	defer r.in.SetPoll(nil)
+	r.in.SetInput(&lines{r: r, prompt: func() string { return "" }})
+
+	for {
+		src := &lines{r: r}
+		lx := lexer.NewReader("", src, lexer.LexTopLevel)
+		src.prompt = func() string {
+			if lx.Incomplete() {
+				return continuePrompt
+			}
+			return prompt
+		}
+		err := r.session(parser.New(lx, r.in))
+		switch {
+		case err == errQuit || src.err == errQuit || src.err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		case src.err != ErrInterrupt:
+			return src.err
+		}
+	}
+}
+
+// session runs instructions until the parser runs out of input.
+func (r *Repl) session(p *parser.Parser) error {
+	for {
+		node, err := p.ParseInstruction()
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			err = r.in.Execute(node)
+		}
+		if err == errQuit {
+			return err
+		}
+		if err != nil {
+			fmt.Fprintln(r.out, message(err))
+		}
+	}
+}
+
+// message formats an error without its position, which means little
+// for a line typed at the prompt.
+func message(err error) string {
+	if e, ok := logoerr.As(err); ok {
+		e := *e
+		e.Pos = token.Pos{}
+		return e.Error()
+	}
+	return err.Error()
+}
+
+// idle calls Idle if it is due. Once Idle has asked to quit, everything
+// running is abandoned.
+func (r *Repl) idle() error {
+	if !r.quit && r.Idle != nil && time.Since(r.lastIdle) >= idleInterval {
+		r.lastIdle = time.Now()
+		r.quit = !r.Idle()
+	}
+	if r.quit {
+		return errQuit
+	}
+	return nil
+}
+
+// poll is run before each instruction.
+func (r *Repl) poll() error {
+	select {
+	case <-r.interrupt:
+		return errStopped
+	default:
+	}
+	if r.Delay > 0 {
+		time.Sleep(r.Delay)
+	}
+	return r.idle()
+}
+
+// readLine reads a line from the editor on another goroutine, keeping
+// Idle running until it arrives. If Idle asks to quit, the read is
+// cancelled and waited for, so that the editor is done with the
+// terminal and its history when readLine returns.
+func (r *Repl) readLine(prompt string) (string, error) {
+	if r.quit {
+		return "", errQuit
+	}
+	type result struct {
+		line string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		line, err := r.ed.ReadLine(prompt)
+		done <- result{line, err}
+	}()
+	tick := time.NewTicker(idleInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case res := <-done:
+			return res.line, res.err
+		case <-r.interrupt:
+			// A Ctrl-C that reaches us as a signal while waiting, as
+			// when input is not a terminal, is dropped so that it does
+			// not stop the next instruction.
+		case <-tick.C:
+			if err := r.idle(); err != nil {
+				r.ed.Cancel()
+				<-done
+				return "", err
+			}
+		}
+	}
+}
+
+// lines feeds what is typed to a lexer or to PAUSE a line at a time,
+// prompting for each. Any error reading a line shows up as the end of
+// input, and is kept in err.
+type lines struct {
+	r      *Repl
+	prompt func() string
+	buf    strings.Reader
+	err    error
+}
+
+func (s *lines) fill() error {
+	for s.buf.Len() == 0 {
+		line, err := s.r.readLine(s.prompt())
+		if err != nil {
+			s.err = err
+			return io.EOF
+		}
+		s.buf.Reset(line + "\n")
+	}
+	return nil
+}
+
+func (s *lines) ReadRune() (rune, int, error) {
+	if err := s.fill(); err != nil {
+		return 0, 0, err
+	}
+	return s.buf.ReadRune()
+}
+
+func (s *lines) Read(p []byte) (int, error) {
+	if err := s.fill(); err != nil {
+		return 0, err
+	}
+	return s.buf.Read(p)
+}
@@ -0,0 +1,156 @@
+package repl
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gortle/internal/eval"
+)
+
+// editor returns an editor that edits keys read from input as if they
+// were typed at a terminal.
+func editor(input string) (*Editor, *strings.Builder) {
+	var out strings.Builder
+	e := NewEditor(strings.NewReader(input), &out)
+	e.editing = true
+	return e, &out
+}
+
+// TestEditKeys tests cursor movement and editing keys
+func TestEditKeys(t *testing.T) {
+	for keys, want := range map[string]string{
+		"abc\r":                 "abc",
+		"abc\x1b[D\x1b[DX\r":    "aXbc",
+		"abc\x01X\x05Y\r":       "XabcY",
+		"abcd\x02\x02\x0b\r":    "ab",
+		"abcd\x02\x15\r":        "d",
+		"ab\x7fc\r":             "ac",
+		"fd 10 rt 90\x17\x17\r": "fd 10 ",
+		"abc\x01\x1b[3~\r":      "bc",
+		"abc\x01\x04\r":         "bc",
+		"abc\x1b[H\x1b[C!\r":    "a!bc",
+		"héllo\x02\x02\x7f\r":   "hélo",
+	} {
+		e, _ := editor(keys)
+		got, err := e.ReadLine("? ")
+		if err != nil || got != want {
+			t.Errorf("Keys %q: expected %q, got %q (%v)", keys, want, got, err)
+		}
+	}
+}
+
+// TestEditEnd tests Ctrl-D, Ctrl-C and the end of input
+func TestEditEnd(t *testing.T) {
+	e, out := editor("\x04ab\x03cd")
+	if _, err := e.ReadLine("? "); err != io.EOF {
+		t.Errorf("Expected io.EOF for Ctrl-D, got %v", err)
+	}
+	if _, err := e.ReadLine("? "); err != ErrInterrupt {
+		t.Errorf("Expected ErrInterrupt for Ctrl-C, got %v", err)
+	}
+	if line, err := e.ReadLine("? "); err != nil || line != "cd" {
+		t.Errorf("Expected the last line without a newline, got %q (%v)", line, err)
+	}
+	if !strings.Contains(out.String(), "^C\n") {
+		t.Errorf("Expected ^C to be echoed, got %q", out.String())
+	}
+}
+
+// TestHistory tests recalling lines with the arrows and saving them
+func TestHistory(t *testing.T) {
+	e, _ := editor("one\rtwo\r\rtwo\r\x1b[A\x1b[A\r\x1b[A\x1b[B\x1b[Bnew\x1b[A\x1b[B\r")
+	var got []string
+	for {
+		line, err := e.ReadLine("? ")
+		if err != nil {
+			break
+		}
+		got = append(got, line)
+	}
+	if want := "one|two||two|one|new"; strings.Join(got, "|") != want {
+		t.Errorf("Expected lines %q, got %q", want, strings.Join(got, "|"))
+	}
+
+	path := filepath.Join(t.TempDir(), "history")
+	if err := e.SaveHistory(path); err != nil {
+		t.Fatalf("SaveHistory failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if want := "one\ntwo\none\nnew\n"; string(data) != want {
+		t.Errorf("Expected history file %q, got %q", want, data)
+	}
+	loaded, _ := editor("\x1b[A\x1b[A\r")
+	if err := loaded.LoadHistory(path); err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if line, _ := loaded.ReadLine("? "); line != "one" {
+		t.Errorf("Expected a loaded line to be recalled, got %q", line)
+	}
+	if err := loaded.LoadHistory(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("Expected a missing history file to be ignored, got %v", err)
+	}
+}
+
+// TestRepl tests prompting, continuation lines, errors and PAUSE
+func TestRepl(t *testing.T) {
+	input := "to sq :n\noutput :n * :n\nend\nprint sq 3\nshow [a\nb]\nfoo 1\n" +
+		"to p\npause\nprint \"after\nend\np\nprint 5\ncontinue\nprint \"done\n"
+	var out strings.Builder
+	r := New(eval.New(&out, nil), NewEditor(strings.NewReader(input), &out), &out)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want := "? ~ ~ ? 9\n? ~ [a b]\n? I don't know how to foo\n? ~ ~ ~ ? p? 5\np? after\n? done\n? "
+	if out.String() != want {
+		t.Errorf("Expected output %q, got %q", want, out.String())
+	}
+}
+
+// TestReplIdle tests that Idle runs while instructions run and can end
+// the session
+func TestReplIdle(t *testing.T) {
+	var out strings.Builder
+	r := New(eval.New(&out, nil), NewEditor(strings.NewReader("forever [make \"x 1]\nprint \"unreached\n"), &out), &out)
+	calls := 0
+	r.Idle = func() bool {
+		calls++
+		return calls < 3
+	}
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if calls != 3 || strings.Contains(out.String(), "unreached") {
+		t.Errorf("Expected the loop to be stopped by Idle, got %d calls and %q", calls, out.String())
+	}
+}
+
+// TestReplQuitWhileReading tests that quitting while a line is being
+// read gives the editor back straight away, without losing what is
+// typed next. Run it with -race.
+func TestReplQuitWhileReading(t *testing.T) {
+	input, typing := io.Pipe()
+	var out strings.Builder
+	e := NewEditor(input, &out)
+	e.editing = true
+	r := New(eval.New(&out, nil), e, &out)
+	calls := 0
+	r.Idle = func() bool {
+		calls++
+		return calls < 3
+	}
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	e.Add("print 1")
+	if err := e.SaveHistory(filepath.Join(t.TempDir(), "history")); err != nil {
+		t.Fatalf("SaveHistory failed: %v", err)
+	}
+	go typing.Write([]byte("fd 10\r"))
+	if line, err := e.ReadLine("? "); err != nil || line != "fd 10" {
+		t.Errorf("Expected the next line to be read, got %q (%v)", line, err)
+	}
+}
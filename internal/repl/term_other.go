@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package repl
+
+import (
+	"errors"
+	"os"
+)
+
+// Line editing needs a Unix terminal. Elsewhere the editor reads plain
+// lines.
+
+func isTerminal(f *os.File) bool {
+	return false
+}
+
+func makeRaw(f *os.File) (func(), error) {
+	return nil, errors.New("line editing is not supported on this system")
+}
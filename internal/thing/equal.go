@@ -0,0 +1,115 @@
+package thing
+
+import (
+	"hash/maphash"
+	"strings"
+)
+
+var seed = maphash.MakeSeed()
+
+// Equal reports whether t and o are equal in the sense of EQUALP:
+// numbers, including words that read as numbers, compare by value,
+// other words by their text, ignoring case if ignoreCase is set, and
+// lists member by member. Arrays are only equal to themselves.
+func (t *Thing) Equal(o *Thing, ignoreCase bool) bool {
+	if t == o {
+		return true
+	}
+	if t == nil || o == nil {
+		return false
+	}
+	if x, ok := t.Number(); ok {
+		y, ok := o.Number()
+		return ok && x == y
+	}
+	if x, ok := t.Word(); ok {
+		if _, ok := o.Number(); ok {
+			return false
+		}
+		y, ok := o.Word()
+		return ok && equalWords(x, y, ignoreCase)
+	}
+	switch x := t.value.(type) {
+	case TList:
+		y, ok := o.value.(TList)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !x[i].Equal(y[i], ignoreCase) {
+				return false
+			}
+		}
+		return true
+	case *TArray:
+		return x == o.value
+	}
+	return false
+}
+
+// Hash returns a hash of t such that things Equal with the same
+// ignoreCase have the same hash.
+func (t *Thing) Hash(ignoreCase bool) uint64 {
+	if t == nil {
+		return 0
+	}
+	if n, ok := t.Number(); ok {
+		if n == 0 {
+			n = 0 // -0 is equal to 0
+		}
+		return maphash.Comparable(seed, n)
+	}
+	if w, ok := t.Word(); ok {
+		return hashWord(w, ignoreCase)
+	}
+	switch v := t.value.(type) {
+	case TList:
+		h := uint64(len(v))
+		for _, elt := range v {
+			h = h*31 + elt.Hash(ignoreCase)
+		}
+		return h
+	case *TArray:
+		return maphash.Comparable(seed, v)
+	}
+	return maphash.Comparable(seed, t)
+}
+
+// Eq reports whether t and o are the same datum, as .EQ does, so that
+// changing one would change the other.
+func (t *Thing) Eq(o *Thing) bool {
+	if t == o {
+		return true
+	}
+	if t == nil || o == nil {
+		return false
+	}
+	switch x := t.value.(type) {
+	case *TArray:
+		return x == o.value
+	case TList:
+		y, ok := o.value.(TList)
+		return ok && len(x) > 0 && len(x) == len(y) && &x[0] == &y[0]
+	}
+	return false
+}
+
+func equalWords(x, y string, ignoreCase bool) bool {
+	if ignoreCase {
+		return strings.ToLower(x) == strings.ToLower(y)
+	}
+	return x == y
+}
+
+func hashWord(w string, ignoreCase bool) uint64 {
+	if ignoreCase {
+		w = strings.ToLower(w)
+	}
+	return maphash.String(seed, w)
+}
+
+// Equal compares names as words, ignoring case, so that a variable is
+// the same whatever case its name is written in.
+func (s TSymbol) Equal(o TSymbol) bool {
+	return equalWords(s.name, o.name, true)
+}
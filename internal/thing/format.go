@@ -0,0 +1,115 @@
+package thing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// specialChars are the characters that end a word when a list is read,
+// along with the escapes themselves. A word containing any of them has
+// to be escaped to read back as the same word.
+const specialChars = " \t\r\n[](){};|\\~"
+
+// FormatNumber formats n the way UCBLogo prints numbers: with up to 15
+// significant digits, no trailing zeros and an exponent for very large
+// and very small values.
+func FormatNumber(n float64) string {
+	if n == 0 {
+		return "0" // not -0
+	}
+	return strconv.FormatFloat(n, 'g', 15, 64)
+}
+
+// String formats t the way SHOW prints it.
+func (t *Thing) String() string {
+	return t.Format(true, false)
+}
+
+// Format formats t for printing. With brackets set a list is written in
+// brackets, as SHOW does; PRINT and TYPE leave them off the outermost
+// list. With full set words are escaped where needed so that reading
+// the text back gives the same words, as FULLPRINTP asks.
+func (t *Thing) Format(brackets, full bool) string {
+	var sb strings.Builder
+	t.format(&sb, brackets, full)
+	return sb.String()
+}
+
+func (t *Thing) format(sb *strings.Builder, brackets, full bool) {
+	if t == nil {
+		return
+	}
+	switch v := t.value.(type) {
+	case TList:
+		if brackets {
+			sb.WriteByte('[')
+		}
+		for i, elt := range v {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			elt.format(sb, true, full)
+		}
+		if brackets {
+			sb.WriteByte(']')
+		}
+	case *TArray:
+		v.format(sb, full)
+	case *TProc:
+		sb.WriteString(v.Name())
+	case TNumber:
+		sb.WriteString(FormatNumber(float64(v)))
+	case TString:
+		if full {
+			sb.WriteString(escapeWord(string(v), t.escaped))
+		} else {
+			sb.WriteString(string(v))
+		}
+	default:
+		fmt.Fprint(sb, v)
+	}
+}
+
+// format writes an array in braces, with each row of a
+// multi-dimensional array as an array of its own. Origins other than 1
+// are written after an @.
+func (arr *TArray) format(sb *strings.Builder, full bool) {
+	sb.WriteByte('{')
+	if len(arr.dims) > 1 {
+		for i := 0; i < arr.dims[0]; i++ {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			sub, _ := arr.Sub(arr.origin + i)
+			sub.format(sb, full)
+		}
+	} else {
+		for i, elt := range arr.values {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			elt.format(sb, true, full)
+		}
+	}
+	sb.WriteByte('}')
+	if arr.origin != 1 {
+		fmt.Fprintf(sb, "@%d", arr.origin)
+	}
+}
+
+// escapeWord puts a word in vertical bars if it would not otherwise
+// read back as itself: if it is empty, contains a special character, or
+// was escaped and would now read as a number.
+func escapeWord(w string, escaped bool) string {
+	if w != "" && !strings.ContainsAny(w, specialChars) {
+		if !escaped {
+			return w
+		}
+		if _, ok := NewWord(w, false).Number(); !ok {
+			return w
+		}
+	}
+	w = strings.NewReplacer(`\`, `\\`, `|`, `\|`).Replace(w)
+	return "|" + w + "|"
+}
@@ -0,0 +1,300 @@
+package thing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gortle/internal/ast"
+	"gortle/internal/logoerr"
+	"gortle/internal/token"
+)
+
+// NewProcFromDef builds a procedure from a TO definition parsed into
+// an ast.NODE_ProcDef node.
+func NewProcFromDef(def *ast.ASTNode) (*Thing, error) {
+	return buildProc("to", def)
+}
+
+// NewProcFromText builds a procedure from a list in the form TEXT
+// outputs: a list of input names followed by one list per line.
+func NewProcFromText(name string, text *Thing) (*Thing, error) {
+	lst, ok := text.value.(TList)
+	if text.tag != TagTList || !ok || len(lst) == 0 {
+		return nil, logoerr.Errorf(logoerr.ERR_BadInput, "define: text must be a non-empty list")
+	}
+	datum := make([]*ast.ASTNode, len(lst))
+	for i, elt := range lst {
+		if elt.tag != TagTList {
+			return nil, logoerr.Errorf(logoerr.ERR_BadInput, "define: line %d is not a list", i)
+		}
+		datum[i] = ToDatum(elt)
+	}
+	def := ast.NewProcDef(token.Pos{}, name, datum[0], datum[1:])
+	return buildProc("define", def)
+}
+
+func buildProc(prim string, def *ast.ASTNode) (*Thing, error) {
+	if def.Type != ast.NODE_ProcDef || len(def.Children) == 0 {
+		return nil, logoerr.Errorf(logoerr.ERR_BadInput, "%s: not a procedure definition", prim)
+	}
+	if def.Name == "" {
+		return nil, logoerr.Errorf(logoerr.ERR_BadInput, "%s: missing procedure name", prim)
+	}
+
+	var params []TParam
+	required := 0
+	dflt := -1
+	for _, item := range def.Children[0].Children {
+		if dflt >= 0 {
+			return nil, logoerr.Errorf(logoerr.ERR_BadInput, "%s: default arity must come last in %s", prim, def.Name)
+		}
+		switch item.Type {
+		case ast.NODE_Word:
+			if n, err := strconv.Atoi(item.Text); err == nil {
+				dflt = n
+				continue
+			}
+			if len(params) > required {
+				return nil, logoerr.Errorf(logoerr.ERR_BadInput, "%s: required input %s after optional inputs in %s", prim, item.Text, def.Name)
+			}
+			params = append(params, TParam{name: Symbol(paramName(item.Text))})
+			required++
+		case ast.NODE_List:
+			if len(item.Children) == 0 || item.Children[0].Type != ast.NODE_Word {
+				return nil, logoerr.Errorf(logoerr.ERR_BadInput, "%s: bad input specification %s in %s", prim, item, def.Name)
+			}
+			if n := len(params); n > 0 && params[n-1].rem {
+				return nil, logoerr.Errorf(logoerr.ERR_BadInput, "%s: rest input must be the last input in %s", prim, def.Name)
+			}
+			param := TParam{name: Symbol(paramName(item.Children[0].Text))}
+			if len(item.Children) == 1 {
+				param.rem = true
+			} else {
+				param.opt = true
+				param.dflval = FromDatum(ast.NewList(item.Pos, item.Children[1:]))
+			}
+			params = append(params, param)
+		default:
+			return nil, logoerr.Errorf(logoerr.ERR_BadInput, "%s: bad input specification %s in %s", prim, item, def.Name)
+		}
+	}
+
+	proc := &TProc{name: def.Name, params: params, body: def}
+	min, _, max := proc.Arity()
+	if dflt < 0 {
+		dflt = min
+	}
+	if dflt < min || (max >= 0 && dflt > max) {
+		return nil, logoerr.Errorf(logoerr.ERR_BadInput, "%s: default arity %d out of range in %s", prim, dflt, def.Name)
+	}
+
+	return NewProc(def.Name, def, formatDefn(def.Name, params, dflt, def.Children[1:]), params, dflt), nil
+}
+
+func paramName(text string) string {
+	return strings.TrimPrefix(text, ":")
+}
+
+func formatDefn(name string, params []TParam, dflt int, lines []*ast.ASTNode) string {
+	var sb strings.Builder
+	sb.WriteString("to " + name)
+	required := 0
+	for _, param := range params {
+		sb.WriteByte(' ')
+		switch {
+		case param.opt:
+			sb.WriteString("[:" + param.name.name)
+			for _, word := range ToDatum(param.dflval).Children {
+				sb.WriteString(" " + word.String())
+			}
+			sb.WriteByte(']')
+		case param.rem:
+			sb.WriteString("[:" + param.name.name + "]")
+		default:
+			sb.WriteString(":" + param.name.name)
+			required++
+		}
+	}
+	if dflt != required {
+		sb.WriteString(" " + strconv.Itoa(dflt))
+	}
+	sb.WriteByte('\n')
+	for _, line := range lines {
+		for i, word := range line.Children {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(word.String())
+		}
+		sb.WriteByte('\n')
+	}
+	sb.WriteString("end\n")
+	return sb.String()
+}
+
+func (p *TProc) Name() string {
+	return p.name
+}
+
+func (p *TProc) Defn() string {
+	return p.defn
+}
+
+func (p *TProc) Body() *ast.ASTNode {
+	return p.body
+}
+
+// Lines returns the unparsed body of the procedure, one NODE_List per
+// line.
+func (p *TProc) Lines() []*ast.ASTNode {
+	return p.body.Children[1:]
+}
+
+func (p *TProc) Params() []TParam {
+	return p.params
+}
+
+// Arity returns the minimum, default and maximum number of inputs. max
+// is -1 when the procedure has a rest input.
+func (p *TProc) Arity() (min, dflt, max int) {
+	for _, param := range p.params {
+		switch {
+		case param.rem:
+			return min, p.dflt, -1
+		case param.opt:
+			max++
+		default:
+			min++
+			max++
+		}
+	}
+	return min, p.dflt, max
+}
+
+func (p *TProc) Text() *Thing {
+	spec := make(TList, 0, len(p.params)+1)
+	required := 0
+	for _, param := range p.params {
+		name := NewString(TString(param.name.name), false)
+		switch {
+		case param.opt:
+			opt := TList{name}
+			opt = append(opt, param.dflval.value.(TList)...)
+			spec = append(spec, New(opt, TagTList, false))
+		case param.rem:
+			spec = append(spec, New(TList{name}, TagTList, false))
+		default:
+			spec = append(spec, name)
+			required++
+		}
+	}
+	if p.dflt != required {
+		spec = append(spec, NewNumber(TNumber(p.dflt), false))
+	}
+
+	text := TList{New(spec, TagTList, false)}
+	for _, line := range p.Lines() {
+		text = append(text, FromDatum(line))
+	}
+	return New(text, TagTList, false)
+}
+
+func (p *TProc) FullText() *Thing {
+	lines := strings.Split(strings.TrimSuffix(p.defn, "\n"), "\n")
+	text := make(TList, len(lines))
+	for i, line := range lines {
+		text[i] = NewString(TString(line), false)
+	}
+	return New(text, TagTList, false)
+}
+
+func (p TParam) Name() string {
+	return p.name.name
+}
+
+func (p TParam) Symbol() TSymbol {
+	return p.name
+}
+
+func (p TParam) Default() *Thing {
+	return p.dflval
+}
+
+func (p TParam) Optional() bool {
+	return p.opt
+}
+
+func (p TParam) Rest() bool {
+	return p.rem
+}
+
+// FromDatum converts a word, list or array literal into a Thing.
+func FromDatum(n *ast.ASTNode) *Thing {
+	switch n.Type {
+	case ast.NODE_List:
+		lst := make(TList, len(n.Children))
+		for i, child := range n.Children {
+			lst[i] = FromDatum(child)
+		}
+		tng := New(lst, TagTList, false)
+		tng.source = n
+		return tng
+	case ast.NODE_Array:
+		arr := &TArray{
+			values: make([]*Thing, len(n.Children)),
+			dims:   []int{len(n.Children)},
+			origin: n.Origin,
+		}
+		for i, child := range n.Children {
+			arr.values[i] = FromDatum(child)
+		}
+		return New(arr, TagTArray, false)
+	case ast.NODE_Number:
+		return NewNumber(TNumber(n.Number), false)
+	}
+	return NewWord(n.Text, n.Escaped)
+}
+
+// ToDatum converts a Thing back into literal form so that it can be
+// parsed as instructions.
+func ToDatum(t *Thing) *ast.ASTNode {
+	if t == nil {
+		return ast.NewList(token.Pos{}, nil)
+	}
+	if t.source != nil {
+		return t.source
+	}
+	switch v := t.value.(type) {
+	case TList:
+		items := make([]*ast.ASTNode, len(v))
+		for i, elt := range v {
+			items[i] = ToDatum(elt)
+		}
+		return ast.NewList(token.Pos{}, items)
+	case *TArray:
+		return arrayDatum(v)
+	case TNumber:
+		return ast.NewWord(token.Pos{}, FormatNumber(float64(v)), false)
+	case TString:
+		return ast.NewWord(token.Pos{}, string(v), t.escaped)
+	}
+	return ast.NewWord(token.Pos{}, fmt.Sprint(t.value), false)
+}
+
+// arrayDatum writes a multi-dimensional array as nested array literals.
+func arrayDatum(arr *TArray) *ast.ASTNode {
+	if len(arr.dims) > 1 {
+		items := make([]*ast.ASTNode, arr.dims[0])
+		for i := range items {
+			sub, _ := arr.Sub(arr.origin + i)
+			items[i] = arrayDatum(sub)
+		}
+		return ast.NewArray(token.Pos{}, items, arr.origin)
+	}
+	items := make([]*ast.ASTNode, len(arr.values))
+	for i, elt := range arr.values {
+		items[i] = ToDatum(elt)
+	}
+	return ast.NewArray(token.Pos{}, items, arr.origin)
+}
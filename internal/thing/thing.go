@@ -1,38 +1,41 @@
 package thing
 
 import (
-	"fmt"
-	"log"
+	"strconv"
+	"strings"
 
 	"gortle/internal/ast"
+	"gortle/internal/logoerr"
 )
 
 type Tag int
 
 const (
-	TagTArray    Tag = iota
-	TagTList     Tag
-	TagTProc     Tag
-	TagTPropList Tag
-	TagTString   Tag
-	TagTSymbol   Tag
-	TagTNumber   Tag
-	TagTParam    Tag
-
-	defaultListSize uint = 32
+	TagTArray Tag = iota
+	TagTList
+	TagTProc
+	TagTPropList
+	TagTString
+	TagTSymbol
+	TagTNumber
+	TagTParam
+
+	defaultListSize = 32
 )
 
 type TArray struct {
 	values []*Thing
-	dims   []uint
-	origin uint
+	dims   []int
+	origin int
 }
 
 type TProc struct {
 	env    TEnv
 	body   *ast.ASTNode
 	defn   string
-	params []*Thing
+	name   string
+	params []TParam
+	dflt   int
 }
 
 type TParam struct {
@@ -45,20 +48,42 @@ type TParam struct {
 type TSymbol struct {
 	name     string
 	reserved bool
+	hash     uint64
 }
 
 type TList []*Thing
-type TPropList map[*Thing]*Thing
-type TEnv map[TSymbol]*Thing
+
+// TPropList holds the properties of one name in the order they were
+// first set. Property names are compared with Equal, ignoring case.
+type TPropList struct {
+	props []prop
+}
+
+type prop struct {
+	name  *Thing
+	value *Thing
+}
+
+// TEnv maps variable names to values. Names are looked up by their
+// hash and compared with TSymbol.Equal.
+type TEnv map[uint64][]binding
+
+type binding struct {
+	symbol TSymbol
+	value  *Thing
+	buried bool
+}
 
 type TString string
 type TNumber float64
 
 type Thing struct {
-	value  interface{}
-	tag    Tag
-	local  bool
-	buried bool
+	value   interface{}
+	tag     Tag
+	local   bool
+	buried  bool
+	escaped bool
+	source  *ast.ASTNode
 }
 
 func New(value interface{}, tag Tag, local bool) *Thing {
@@ -70,25 +95,32 @@ func New(value interface{}, tag Tag, local bool) *Thing {
 	return tng
 }
 
-func NewArray(dims []uint, origin uint, local bool) *Thing {
+func NewArray(dims []int, origin int, local bool) (*Thing, error) {
 	if len(dims) == 0 {
-		log.Fatalf("newarray: dimens are zero")
-		return
+		return nil, logoerr.New(logoerr.ERR_BadInput, "newarray: no dimensions")
 	}
 
 	size := 1
 	for _, dim := range dims {
+		if dim < 0 {
+			return nil, logoerr.Errorf(logoerr.ERR_BadInput, "newarray: negative dimension %d", dim)
+		}
 		size *= dim
 	}
 
+	// Members start out as empty lists, as in UCBLogo.
+	empty := New(TList{}, TagTList, false)
 	values := make([]*Thing, size)
+	for i := range values {
+		values[i] = empty
+	}
 	arr := &TArray{
 		values: values,
 		dims:   dims,
 		origin: origin,
 	}
 
-	return New(arr, TagTArray, local)
+	return New(arr, TagTArray, local), nil
 }
 
 func NewList(local bool) *Thing {
@@ -96,15 +128,17 @@ func NewList(local bool) *Thing {
 }
 
 func NewPropList(local bool) *Thing {
-	return New(make(TList, 0, 1024), TagTPropList, local)
+	return New(&TPropList{}, TagTPropList, local)
 }
 
-func NewProc(body *ast.ASTNode, defn string, params []TParam) *Thing {
+func NewProc(name string, body *ast.ASTNode, defn string, params []TParam, dflt int) *Thing {
 	proc := &TProc{
 		env:    make(TEnv, defaultListSize),
 		body:   body,
 		defn:   defn,
+		name:   name,
 		params: params,
+		dflt:   dflt,
 	}
 	return New(proc, TagTProc, false)
 }
@@ -123,6 +157,7 @@ func NewSymbol(name string, reserved bool) *Thing {
 	sym := &TSymbol{
 		name:     name,
 		reserved: reserved,
+		hash:     hashWord(name, true),
 	}
 	return New(sym, TagTSymbol, false)
 }
@@ -131,19 +166,27 @@ func NewString(value TString, local bool) *Thing {
 	return New(value, TagTString, local)
 }
 
+// NewWord makes a word. Escaped words were written with bars or
+// backslashes and are never read as numbers or split into tokens.
+func NewWord(text string, escaped bool) *Thing {
+	word := New(TString(text), TagTString, false)
+	word.escaped = escaped
+	return word
+}
+
 func NewNumber(value TNumber, local bool) *Thing {
 	return New(value, TagTNumber, local)
 }
 
 func (t *TArray) getIndex(coords []int) (int, error) {
 	if len(coords) != len(t.dims) {
-		return 0, fmt.Errorf("Invalid number of coordinates")
+		return 0, logoerr.New(logoerr.ERR_BadInput, "Invalid number of coordinates")
 	}
 
 	index := 0
 	for i, coord := range coords {
 		if coord < t.origin || coord >= t.origin+t.dims[i] {
-			return 0, fmt.Errorf("Index out of bounds for dimension %d", i)
+			return 0, logoerr.Errorf(logoerr.ERR_BadInput, "Index out of bounds for dimension %d", i)
 		}
 		index = index*t.dims[i] + (coord - t.origin)
 	}
@@ -168,16 +211,36 @@ func (t *TArray) SetAt(coords []int, value *Thing) error {
 	return nil
 }
 
+// Sub returns row index of a multi-dimensional array as an array of
+// one dimension fewer. It shares its members with t, so that setting
+// them through either is seen through both.
+func (t *TArray) Sub(index int) (*TArray, error) {
+	if len(t.dims) < 2 {
+		return nil, logoerr.New(logoerr.ERR_BadInput, "Array has no rows")
+	}
+	if index < t.origin || index >= t.origin+t.dims[0] {
+		return nil, logoerr.New(logoerr.ERR_BadInput, "Index out of bounds for dimension 0")
+	}
+	stride := len(t.values) / t.dims[0]
+	start := (index - t.origin) * stride
+	sub := &TArray{
+		values: t.values[start : start+stride : start+stride],
+		dims:   t.dims[1:],
+		origin: t.origin,
+	}
+	return sub, nil
+}
+
 func (t *TArray) ToList() *Thing {
-	lst := NewList()
+	lst := make(TList, len(t.values))
 	copy(lst, t.values)
-	return lst
+	return New(lst, TagTList, false)
 }
 
-func (t *TArry) CombineWith(otherT *TArray, local bool) *Thing {
+func (t *TArray) CombineWith(otherT *TArray, local bool) *Thing {
 	combined := &TArray{
-		values: make([]*Thing, len(t.values)+len(otherT.values)+1),
-		dims:   append(t.dims[:], otherT.dims[:]...),
+		values: make([]*Thing, len(t.values)+len(otherT.values)),
+		dims:   append(append([]int{}, t.dims...), otherT.dims...),
 		origin: t.origin,
 	}
 	copy(combined.values, t.values)
@@ -185,91 +248,272 @@ func (t *TArry) CombineWith(otherT *TArray, local bool) *Thing {
 	return New(combined, TagTArray, local)
 }
 
-func (e *TEnv) GetVariable(symbol TSymbol) (*Thing, error) {
-	if varr, exists := e[symbol]; val != nil {
-		return nil, fmt.Errorf("getvariable: %s does not exist in environment", symbol)
+// Lookup finds a variable. A variable can exist without a value, in
+// which case the value is nil.
+func (e TEnv) Lookup(symbol TSymbol) (*Thing, bool) {
+	for _, b := range e[symbol.hash] {
+		if b.symbol.Equal(symbol) {
+			return b.value, true
+		}
 	}
-	if varr.buried {
-		return nil, fmt.Errorf("getvariable: %s is buried", symbol)
+	return nil, false
+}
+
+func (e TEnv) GetVariable(symbol TSymbol) (*Thing, error) {
+	varr, exists := e.Lookup(symbol)
+	if !exists || varr == nil {
+		return nil, logoerr.Errorf(logoerr.ERR_NoValue, "getvariable: %s does not exist in environment", symbol.name)
+	}
+	if e.Buried(symbol) {
+		return nil, logoerr.Errorf(logoerr.ERR_NoValue, "getvariable: %s is buried", symbol.name)
 	}
 	return varr, nil
 }
 
-func (e *TEnv) SetVariable(symbol TSymbol, value *Thing) {
-	e[symbol] = value
+func (e TEnv) SetVariable(symbol TSymbol, value *Thing) {
+	bucket := e[symbol.hash]
+	for i, b := range bucket {
+		if b.symbol.Equal(symbol) {
+			bucket[i].value = value
+			return
+		}
+	}
+	e[symbol.hash] = append(bucket, binding{symbol: symbol, value: value})
 }
 
-func (e *TEnv) BuryVariable(symbol TSymbol) error {
-	if varr, exists := e[symbol]; exists != nil {
-		return fmt.Errorf("buryvariable: %s is not set", symbol)
+func (e TEnv) DeleteVariable(symbol TSymbol) {
+	bucket := e[symbol.hash]
+	for i, b := range bucket {
+		if b.symbol.Equal(symbol) {
+			bucket = append(bucket[:i:i], bucket[i+1:]...)
+			break
+		}
+	}
+	if len(bucket) == 0 {
+		delete(e, symbol.hash)
+		return
 	}
-	e[symbol].buried = true
+	e[symbol.hash] = bucket
 }
 
-func (pl *TPropList) GetPropValue(prop *Thing) (*Thing, error) {
-	if val, exists := pl[prop]; val != nil {
-		return nil, fmt.Errorf("getprop: Property is not set in list")
+// Symbols returns the names of the variables in e, in no particular
+// order.
+func (e TEnv) Symbols() []TSymbol {
+	var syms []TSymbol
+	for _, bucket := range e {
+		for _, b := range bucket {
+			syms = append(syms, b.symbol)
+		}
 	}
-	return val, nil
+	return syms
+}
+
+// BuryVariable hides a variable from the workspace listings. The
+// variable stays buried when it is given a new value.
+func (e TEnv) BuryVariable(symbol TSymbol) error {
+	return e.setBuried(symbol, true)
 }
 
-func (pl *TPropList) SetPropValue(prop, val *Thing) {
-	pl[prop] = val
+func (e TEnv) UnburyVariable(symbol TSymbol) error {
+	return e.setBuried(symbol, false)
+}
+
+func (e TEnv) setBuried(symbol TSymbol, buried bool) error {
+	bucket := e[symbol.hash]
+	for i, b := range bucket {
+		if b.symbol.Equal(symbol) {
+			bucket[i].buried = buried
+			return nil
+		}
+	}
+	return logoerr.Errorf(logoerr.ERR_NoValue, "buryvariable: %s is not set", symbol.name)
+}
+
+// Buried reports whether a variable has been buried.
+func (e TEnv) Buried(symbol TSymbol) bool {
+	for _, b := range e[symbol.hash] {
+		if b.symbol.Equal(symbol) {
+			return b.buried
+		}
+	}
+	return false
+}
+
+func (pl *TPropList) find(name *Thing) int {
+	for i, p := range pl.props {
+		if p.name.Equal(name, true) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (pl *TPropList) GetPropValue(name *Thing) (*Thing, error) {
+	i := pl.find(name)
+	if i < 0 {
+		return nil, logoerr.New(logoerr.ERR_BadInput, "getprop: Property is not set in list")
+	}
+	return pl.props[i].value, nil
+}
+
+func (pl *TPropList) SetPropValue(name, val *Thing) {
+	if i := pl.find(name); i >= 0 {
+		pl.props[i].value = val
+		return
+	}
+	pl.props = append(pl.props, prop{name: name, value: val})
+}
+
+func (pl *TPropList) RemoveProp(name *Thing) {
+	if i := pl.find(name); i >= 0 {
+		pl.props = append(pl.props[:i], pl.props[i+1:]...)
+	}
+}
+
+func (pl *TPropList) Len() int {
+	return len(pl.props)
+}
+
+// ToList returns the properties as a list of alternating names and
+// values.
+func (pl *TPropList) ToList() *Thing {
+	lst := make(TList, 0, 2*len(pl.props))
+	for _, p := range pl.props {
+		lst = append(lst, p.name, p.value)
+	}
+	return New(lst, TagTList, false)
 }
 
 func (lst *TList) AppendList(item *Thing) {
-	lst = append(lst, item)
+	*lst = append(*lst, item)
 }
 
 func (lst *TList) PopList() (*Thing, error) {
-	if len(lst) == 0 {
-		return nil, fmt.Errorf("poplist: List empty")
+	if len(*lst) == 0 {
+		return nil, logoerr.New(logoerr.ERR_BadInput, "poplist: List empty")
 	}
-	item := lst[len(lst)-1]
-	lst = lst[:len(lst)-1]
+	item := (*lst)[len(*lst)-1]
+	*lst = (*lst)[:len(*lst)-1]
 	return item, nil
 }
 
 func (lst *TList) ShiftList() (*Thing, error) {
-	if len(lst) == 0 {
-		return nil, fmt.Errorf("shiftlist: List empty")
+	if len(*lst) == 0 {
+		return nil, logoerr.New(logoerr.ERR_BadInput, "shiftlist: List empty")
 	}
-	item := lst[0]
-	lst = lst[1:]
+	item := (*lst)[0]
+	*lst = (*lst)[1:]
 	return item, nil
 }
 
-func (lst *TList) ButFirst() ([]*Thing, error) {
+func (lst TList) ButFirst() ([]*Thing, error) {
 	if len(lst) == 0 {
-		return nil, fmt.Errorf("butfirst: List empty")
+		return nil, logoerr.New(logoerr.ERR_BadInput, "butfirst: List empty")
 	}
 	items := lst[1:]
 	return items, nil
 }
 
-func (lst *TList) ButLast() ([]*Thing, error) {
+func (lst TList) ButLast() ([]*Thing, error) {
 	if len(lst) == 0 {
-		return nil, fmt.Errorf("butlast: List empty")
+		return nil, logoerr.New(logoerr.ERR_BadInput, "butlast: List empty")
 	}
 	items := lst[:len(lst)-1]
 	return items, nil
 }
 
-func (lst *TList) ToArray() *TArray {
+func (lst TList) ToArray() *TArray {
 	arr := &TArray{
 		values: make([]*Thing, len(lst)),
-		dims:   []uint{len(lst)},
+		dims:   []int{len(lst)},
 		origin: 1,
 	}
-	for i, elt := range lst {
-		arr.values[i] = elt
-	}
+	copy(arr.values, lst)
 	return arr
 }
 
-func (lst *TList) CombineWith(otherLst TList, local bool) *Thing {
-	combined := make(TList, len(lst)+len(otherLst)+1)
+func (lst TList) CombineWith(otherLst TList, local bool) *Thing {
+	combined := make(TList, len(lst)+len(otherLst))
 	copy(combined, lst)
 	copy(combined[len(lst):], otherLst)
 	return New(combined, TagTList, local)
 }
+
+func (t *Thing) Value() interface{} {
+	return t.value
+}
+
+func (t *Thing) Tag() Tag {
+	return t.tag
+}
+
+func (t *Thing) Escaped() bool {
+	return t.escaped
+}
+
+// Buried reports whether t, a procedure, is hidden from the workspace
+// listings.
+func (t *Thing) Buried() bool {
+	return t.buried
+}
+
+func (t *Thing) SetBuried(buried bool) {
+	t.buried = buried
+}
+
+// Source returns the literal a list was read from, or nil for lists
+// built at run time.
+func (t *Thing) Source() *ast.ASTNode {
+	return t.source
+}
+
+// Number returns the numeric value of a number or of a word that reads
+// as one.
+func (t *Thing) Number() (float64, bool) {
+	switch v := t.value.(type) {
+	case TNumber:
+		return float64(v), true
+	case TString:
+		if t.escaped {
+			return 0, false
+		}
+		text := strings.NewReplacer("n", "e-", "N", "e-").Replace(string(v))
+		if text == "" || strings.ContainsAny(text, "xXpP_") {
+			return 0, false
+		}
+		n, err := strconv.ParseFloat(text, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// Word returns the text of a word or number.
+func (t *Thing) Word() (string, bool) {
+	switch v := t.value.(type) {
+	case TString:
+		return string(v), true
+	case TNumber:
+		return FormatNumber(float64(v)), true
+	}
+	return "", false
+}
+
+func Symbol(name string) TSymbol {
+	return TSymbol{name: name, hash: hashWord(name, true)}
+}
+
+func (s TSymbol) Name() string {
+	return s.name
+}
+
+func (t *TArray) Values() []*Thing {
+	return t.values
+}
+
+func (t *TArray) Dims() []int {
+	return t.dims
+}
+
+func (t *TArray) Origin() int {
+	return t.origin
+}
@@ -0,0 +1,237 @@
+package thing
+
+import (
+	"testing"
+
+	"gortle/internal/ast"
+	"gortle/internal/logoerr"
+	"gortle/internal/token"
+)
+
+func word(text string) *ast.ASTNode {
+	return ast.NewWord(token.Pos{}, text, false)
+}
+
+func list(items ...*ast.ASTNode) *ast.ASTNode {
+	return ast.NewList(token.Pos{}, items)
+}
+
+// TestProcFromDef tests inputs, arity and the generated definition text
+func TestProcFromDef(t *testing.T) {
+	def := ast.NewProcDef(token.Pos{}, "poly",
+		list(word("side"), word("count"), list(word(":color"), word("\"red")), word("2")),
+		[]*ast.ASTNode{list(word("fd"), word(":side"))})
+
+	tng, err := NewProcFromDef(def)
+	if err != nil {
+		t.Fatalf("NewProcFromDef failed: %v", err)
+	}
+	proc := tng.Value().(*TProc)
+
+	if min, dflt, max := proc.Arity(); min != 2 || dflt != 2 || max != 3 {
+		t.Errorf("Expected arity 2 2 3, got %d %d %d", min, dflt, max)
+	}
+	if !proc.Params()[2].Optional() || proc.Params()[2].Name() != "color" {
+		t.Errorf("Expected optional input color, got %+v", proc.Params()[2])
+	}
+
+	want := "to poly :side :count [:color \"red]\nfd :side\nend\n"
+	if proc.Defn() != want {
+		t.Errorf("Expected definition %q, got %q", want, proc.Defn())
+	}
+}
+
+// TestProcTextRoundTrip tests that DEFINE accepts what TEXT produces
+func TestProcTextRoundTrip(t *testing.T) {
+	def := ast.NewProcDef(token.Pos{}, "spread",
+		list(word("a"), list(word("b"), word("1")), list(word("rest")), word("1")),
+		[]*ast.ASTNode{list(word("print"), word(":a"))})
+	tng, err := NewProcFromDef(def)
+	if err != nil {
+		t.Fatalf("NewProcFromDef failed: %v", err)
+	}
+	text := tng.Value().(*TProc).Text()
+
+	redefined, err := NewProcFromText("spread", text)
+	if err != nil {
+		t.Fatalf("NewProcFromText failed: %v", err)
+	}
+	proc := redefined.Value().(*TProc)
+	if min, dflt, max := proc.Arity(); min != 1 || dflt != 1 || max != -1 {
+		t.Errorf("Expected arity 1 1 -1, got %d %d %d", min, dflt, max)
+	}
+	if got := ToDatum(proc.Text()).String(); got != "[[a [b 1] [rest]] [print :a]]" {
+		t.Errorf("Unexpected text %s", got)
+	}
+	if got := len(proc.FullText().Value().(TList)); got != 3 {
+		t.Errorf("Expected 3 lines of full text, got %d", got)
+	}
+
+	if _, err := NewProcFromText("bad", NewList(false)); err == nil {
+		t.Error("Expected an error defining from an empty list")
+	}
+}
+
+// TestNewArrayErrors tests that bad dimensions are reported as Logo errors
+func TestNewArrayErrors(t *testing.T) {
+	for _, dims := range [][]int{nil, {3, -1}} {
+		_, err := NewArray(dims, 1, false)
+		e, ok := logoerr.As(err)
+		if !ok || e.Code != logoerr.ERR_BadInput {
+			t.Errorf("Expected a bad input error for %v, got %v", dims, err)
+		}
+	}
+	arr, err := NewArray([]int{2, 3}, 0, false)
+	if err != nil || len(arr.Value().(*TArray).Values()) != 6 {
+		t.Errorf("Expected a 2x3 array, got %v, %v", arr, err)
+	}
+}
+
+// TestArraySub tests that rows of a multi-dimensional array share its
+// members
+func TestArraySub(t *testing.T) {
+	arr, _ := NewArray([]int{2, 2}, 0, false)
+	grid := arr.Value().(*TArray)
+	row, err := grid.Sub(1)
+	if err != nil {
+		t.Fatalf("Sub failed: %v", err)
+	}
+	x := NewWord("x", false)
+	if err := row.SetAt([]int{0}, x); err != nil {
+		t.Fatalf("SetAt failed: %v", err)
+	}
+	if got, _ := grid.GetAt([]int{1, 0}); got != x {
+		t.Errorf("Expected x at [1 0], got %v", got)
+	}
+	if _, err := grid.Sub(2); err == nil {
+		t.Errorf("Expected an error for row 2")
+	}
+}
+
+// TestPropList tests that properties are found by case-insensitive name
+// and kept in order
+func TestPropList(t *testing.T) {
+	pl := NewPropList(false).Value().(*TPropList)
+	pl.SetPropValue(NewWord("Color", false), NewWord("red", false))
+	pl.SetPropValue(NewWord("size", false), NewNumber(3, false))
+	pl.SetPropValue(NewWord("COLOR", false), NewWord("blue", false))
+	if v, err := pl.GetPropValue(NewWord("color", false)); err != nil || v.Value() != TString("blue") {
+		t.Errorf("Expected blue, got %v, %v", v, err)
+	}
+	if lst := pl.ToList().Value().(TList); len(lst) != 4 || lst[0].Value() != TString("Color") {
+		t.Errorf("Expected [Color blue size 3], got %v", lst)
+	}
+	pl.RemoveProp(NewWord("SIZE", false))
+	if _, err := pl.GetPropValue(NewWord("size", false)); err == nil || pl.Len() != 1 {
+		t.Errorf("Expected size to be removed")
+	}
+}
+
+// TestEqualAndHash tests EQUALP semantics and that equal things hash
+// alike
+func TestEqualAndHash(t *testing.T) {
+	list := func(items ...*Thing) *Thing { return New(TList(items), TagTList, false) }
+	w := func(text string) *Thing { return NewWord(text, false) }
+	arr, _ := NewArray([]int{2}, 1, false)
+	arr2, _ := NewArray([]int{2}, 1, false)
+
+	equal := []struct {
+		a, b       *Thing
+		ignoreCase bool
+	}{
+		{w("3.0"), NewNumber(3, false), false},
+		{w("1e2"), w("100"), false},
+		{w("Foo"), w("fOO"), true},
+		{list(w("a"), list(w("B"))), list(w("A"), list(w("b"))), true},
+		{arr, arr, false},
+	}
+	for _, tc := range equal {
+		if !tc.a.Equal(tc.b, tc.ignoreCase) {
+			t.Errorf("Expected %v to equal %v", tc.a.Value(), tc.b.Value())
+		}
+		if tc.a.Hash(tc.ignoreCase) != tc.b.Hash(tc.ignoreCase) {
+			t.Errorf("Expected %v and %v to hash alike", tc.a.Value(), tc.b.Value())
+		}
+	}
+
+	unequal := [][2]*Thing{
+		{w("Foo"), w("foo")},
+		{w("3"), NewWord("3x", false)},
+		{list(w("a")), list(w("a"), w("b"))},
+		{arr, arr2},
+		{w("a"), list(w("a"))},
+	}
+	for _, tc := range unequal {
+		if tc[0].Equal(tc[1], false) {
+			t.Errorf("Expected %v not to equal %v", tc[0].Value(), tc[1].Value())
+		}
+	}
+}
+
+// TestEq tests identity as .EQ sees it
+func TestEq(t *testing.T) {
+	a := New(TList{NewWord("x", false)}, TagTList, false)
+	b := New(TList{NewWord("x", false)}, TagTList, false)
+	rest := New(a.Value().(TList)[0:], TagTList, false)
+	if !a.Eq(a) || !a.Eq(rest) || a.Eq(b) {
+		t.Errorf("Expected lists to be .eq only when they share members")
+	}
+}
+
+// TestEnvCase tests that variable names ignore case
+func TestEnvCase(t *testing.T) {
+	env := make(TEnv)
+	env.SetVariable(Symbol("Size"), NewNumber(1, false))
+	env.SetVariable(Symbol("SIZE"), NewNumber(2, false))
+	if v, ok := env.Lookup(Symbol("size")); !ok || v.Value() != TNumber(2) {
+		t.Errorf("Expected size to be 2, got %v", v)
+	}
+	if syms := env.Symbols(); len(syms) != 1 || syms[0].Name() != "Size" {
+		t.Errorf("Expected one variable named Size, got %v", syms)
+	}
+	env.DeleteVariable(Symbol("size"))
+	if _, ok := env.Lookup(Symbol("Size")); ok {
+		t.Errorf("Expected Size to be deleted")
+	}
+}
+
+// TestEnvBury tests that burying belongs to the variable, not its value
+func TestEnvBury(t *testing.T) {
+	env := make(TEnv)
+	value := NewNumber(1, false)
+	env.SetVariable(Symbol("a"), value)
+	env.SetVariable(Symbol("b"), value)
+	if err := env.BuryVariable(Symbol("A")); err != nil {
+		t.Fatalf("BuryVariable failed: %v", err)
+	}
+	env.SetVariable(Symbol("a"), NewNumber(2, false))
+	if !env.Buried(Symbol("a")) || env.Buried(Symbol("b")) {
+		t.Errorf("Expected only a to be buried")
+	}
+	if _, err := env.GetVariable(Symbol("a")); err == nil {
+		t.Errorf("Expected GetVariable to refuse a buried variable")
+	}
+	if err := env.UnburyVariable(Symbol("a")); err != nil || env.Buried(Symbol("a")) {
+		t.Errorf("Expected a to be unburied, got %v", err)
+	}
+	if err := env.BuryVariable(Symbol("c")); err == nil {
+		t.Errorf("Expected an error burying a missing variable")
+	}
+}
+
+// TestFormatNumber tests that numbers print the way UCBLogo prints them
+func TestFormatNumber(t *testing.T) {
+	for n, want := range map[float64]string{
+		3:               "3",
+		-2.5:            "-2.5",
+		1.0 / 3:         "0.333333333333333",
+		1e20:            "1e+20",
+		123456789012345: "123456789012345",
+		0.0001:          "0.0001",
+		0.00001:         "1e-05",
+	} {
+		if got := FormatNumber(n); got != want {
+			t.Errorf("Expected %s for %v, got %s", want, n, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package token
+
+import "fmt"
+
+type TokenType int
+
+const (
+	TOKEN_Error TokenType = iota
+	TOKEN_EOF
+	TOKEN_Newline
+	TOKEN_Word
+	TOKEN_QuotedWord
+	TOKEN_Variable
+	TOKEN_Number
+	TOKEN_LBracket
+	TOKEN_RBracket
+	TOKEN_LBrace
+	TOKEN_RBrace
+	TOKEN_Origin
+	TOKEN_LParen
+	TOKEN_RParen
+	TOKEN_Plus
+	TOKEN_Minus
+	TOKEN_UnaryMinus
+	TOKEN_Star
+	TOKEN_Slash
+	TOKEN_Equal
+	TOKEN_Less
+	TOKEN_Greater
+	TOKEN_LessEqual
+	TOKEN_GreaterEqual
+	TOKEN_NotEqual
+)
+
+var tokenNames = [...]string{
+	TOKEN_Error:        "Error",
+	TOKEN_EOF:          "EOF",
+	TOKEN_Newline:      "Newline",
+	TOKEN_Word:         "Word",
+	TOKEN_QuotedWord:   "QuotedWord",
+	TOKEN_Variable:     "Variable",
+	TOKEN_Number:       "Number",
+	TOKEN_LBracket:     "[",
+	TOKEN_RBracket:     "]",
+	TOKEN_LBrace:       "{",
+	TOKEN_RBrace:       "}",
+	TOKEN_Origin:       "@",
+	TOKEN_LParen:       "(",
+	TOKEN_RParen:       ")",
+	TOKEN_Plus:         "+",
+	TOKEN_Minus:        "-",
+	TOKEN_UnaryMinus:   "unary -",
+	TOKEN_Star:         "*",
+	TOKEN_Slash:        "/",
+	TOKEN_Equal:        "=",
+	TOKEN_Less:         "<",
+	TOKEN_Greater:      ">",
+	TOKEN_LessEqual:    "<=",
+	TOKEN_GreaterEqual: ">=",
+	TOKEN_NotEqual:     "<>",
+}
+
+var infixOperators = map[string]TokenType{
+	"+":  TOKEN_Plus,
+	"-":  TOKEN_Minus,
+	"*":  TOKEN_Star,
+	"/":  TOKEN_Slash,
+	"=":  TOKEN_Equal,
+	"<":  TOKEN_Less,
+	">":  TOKEN_Greater,
+	"<=": TOKEN_LessEqual,
+	">=": TOKEN_GreaterEqual,
+	"<>": TOKEN_NotEqual,
+}
+
+type Pos struct {
+	File   string
+	Offset int
+	Line   int
+	Column int
+}
+
+type Token struct {
+	Type    TokenType
+	Text    string
+	Pos     Pos
+	Escaped bool
+	Spaced  bool
+}
+
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Pos) String() string {
+	s := p.File
+	if s == "" {
+		s = "-"
+	}
+	if p.IsValid() {
+		s += fmt.Sprintf(":%d:%d", p.Line, p.Column)
+	}
+	return s
+}
+
+func (tt TokenType) String() string {
+	if tt >= 0 && int(tt) < len(tokenNames) {
+		return tokenNames[tt]
+	}
+	return fmt.Sprintf("TokenType(%d)", int(tt))
+}
+
+func (tt TokenType) IsInfix() bool {
+	return tt >= TOKEN_Plus && tt <= TOKEN_NotEqual && tt != TOKEN_UnaryMinus
+}
+
+func LookupInfix(op string) (TokenType, bool) {
+	tt, ok := infixOperators[op]
+	return tt, ok
+}
+
+func (t Token) String() string {
+	switch t.Type {
+	case TOKEN_EOF:
+		return "EOF"
+	case TOKEN_Newline:
+		return "Newline"
+	case TOKEN_Error:
+		return "Error(" + t.Text + ")"
+	}
+	return fmt.Sprintf("%s(%q)", t.Type, t.Text)
+}
@@ -0,0 +1,42 @@
+package turtle
+
+import (
+	"image"
+	"image/color"
+)
+
+// Font names the typeface labels are drawn in. A canvas that cannot
+// load fonts draws in its own.
+type Font struct {
+	Path string
+	Size uint
+}
+
+// Canvas is the surface a turtle draws on. Coordinates are pixels with
+// the origin at the top left; anything drawn outside the canvas is
+// clipped. Lines, spans and text replace the pixels they cover, alpha
+// and all; only sprites are blended.
+type Canvas interface {
+	// Size returns the width and height in pixels.
+	Size() (w, h int)
+	// Clear fills the whole canvas with c.
+	Clear(c color.RGBA)
+	// DrawLine draws a line width pixels wide.
+	DrawLine(x1, y1, x2, y2, width int, c color.RGBA)
+	// FillSpan fills row y from x1 to x2, inclusive.
+	FillSpan(y, x1, x2 int, c color.RGBA)
+	// ReadPixels copies what has been drawn.
+	ReadPixels() (*image.RGBA, error)
+	// WritePixels replaces the canvas with img, which must be the same
+	// size.
+	WritePixels(img *image.RGBA) error
+	// DrawText draws text centred on x, y.
+	DrawText(x, y int, text string, font Font, c color.RGBA) error
+	// LoadSprite reads the image drawn for the turtle.
+	LoadSprite(path string) error
+	// DrawSprite draws the sprite centred on x, y and turned angle
+	// degrees counterclockwise. Without a sprite it does nothing.
+	DrawSprite(x, y int, angle float64)
+	// Present shows what has been drawn, if the canvas is on screen.
+	Present()
+}
@@ -0,0 +1,99 @@
+package turtle
+
+import (
+	"image"
+	"image/color"
+)
+
+// The display list keeps everything drawn since the screen was last
+// cleared, in canvas pixels, so that a drawing can be written out as
+// vector graphics or printed as well as read back from the canvas.
+
+// shape is one entry in the display list.
+type shape interface {
+	svg(w *svgWriter)
+	print(p *printer)
+}
+
+// stroke is a line drawn by the pen.
+type stroke struct {
+	x1, y1, x2, y2 int
+	width          int
+	color          color.RGBA
+}
+
+// squareEnds reports whether the line reaches past its end points by
+// half its width, as a thin line covers the pixels at both ends, and a
+// thick one that goes nowhere is drawn as a square.
+func (s stroke) squareEnds() bool {
+	return s.width <= 1 || s.x1 == s.x2 && s.y1 == s.y2
+}
+
+// polygon is a shape drawn by Filled.
+type polygon struct {
+	pts     []image.Point
+	fill    color.RGBA
+	outline color.RGBA
+	width   int
+}
+
+// caption is text drawn by PrintLabel.
+type caption struct {
+	x, y  int
+	text  string
+	font  Font
+	color color.RGBA
+}
+
+// stamp is where the turtle's sprite is shown. It marks where the turtle
+// is rather than being part of the drawing, so only the latest one is
+// kept, out of the display list, and it is left off printed pages.
+type stamp struct {
+	x, y   int
+	angle  float64
+	sprite *sprite
+}
+
+// bitmap is a patch of pixels with no outline to describe it, such as
+// a flood fill or a loaded picture.
+type bitmap struct {
+	img *image.RGBA
+}
+
+// sprite is the image file the turtle is drawn with.
+type sprite struct {
+	data   []byte
+	format string
+	w, h   int
+}
+
+func (t *Turtle) record(s shape) {
+	t.display = append(t.display, s)
+}
+
+// changed returns the pixels of after that differ from before, with the
+// rest transparent, cut down to the area that changed. It returns nil if
+// nothing did.
+func changed(before, after *image.RGBA) *image.RGBA {
+	var area image.Rectangle
+	b := after.Rect
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if before.RGBAAt(x, y) != after.RGBAAt(x, y) {
+				area = area.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if area.Empty() {
+		return nil
+	}
+	img := image.NewRGBA(area)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			if c := after.RGBAAt(x, y); before.RGBAAt(x, y) != c {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+	return img
+}
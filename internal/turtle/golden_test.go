@@ -0,0 +1,240 @@
+package turtle
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gortle/internal/eval"
+)
+
+// Golden images are compared a channel at a time; a pixel matches if no
+// channel differs by more than tolerance. Run
+//
+//	go test ./internal/turtle -update
+//
+// to write the images afresh after a deliberate change in drawing, and
+// look at them before checking them in.
+var update = flag.Bool("update", false, "rewrite golden images in testdata/golden")
+
+const (
+	goldenDir    = "testdata/golden"
+	goldenWidth  = 200
+	goldenHeight = 200
+	tolerance    = 2
+)
+
+// drawGolden runs draw on a turtle with a fresh raster, cleared to black
+// with a white pen down, and checks the result against name's golden
+// image.
+func drawGolden(t *testing.T, name string, draw func(*Turtle) error) {
+	t.Helper()
+	turtle, r := newTestTurtle(goldenWidth, goldenHeight)
+	turtle.SetForegroundColor(255, 255, 255, 255)
+	if err := draw(turtle); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	checkGolden(t, name, r.Image())
+}
+
+// checkGolden compares img with testdata/golden/name.png. On a mismatch
+// it writes what was drawn and a diff image, in which the golden image
+// is faded and the pixels that differ are red, and says where.
+func checkGolden(t *testing.T, name string, img *image.RGBA) {
+	t.Helper()
+	path := filepath.Join(goldenDir, name+".png")
+	if *update {
+		if err := writePNG(path, img); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return
+	}
+
+	want, err := readPNG(path)
+	if err != nil {
+		t.Fatalf("%s: %v (run with -update to create it)", name, err)
+	}
+	if want.Rect.Size() != img.Rect.Size() {
+		t.Errorf("%s: expected a %v image, got %v", name, want.Rect.Size(), img.Rect.Size())
+		return
+	}
+	diff, n := diffImages(want, img)
+	if n == 0 {
+		return
+	}
+
+	dir := filepath.Join(os.TempDir(), "gortle-golden")
+	gotPath := filepath.Join(dir, name+".got.png")
+	diffPath := filepath.Join(dir, name+".diff.png")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if err := writePNG(gotPath, img); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if err := writePNG(diffPath, diff); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	t.Errorf("%s: %d pixels differ from %s; got %s, diff %s", name, n, path, gotPath, diffPath)
+}
+
+// diffImages returns an image of the differences between two images of
+// the same size and the number of pixels that differ.
+func diffImages(want, got *image.RGBA) (*image.RGBA, int) {
+	diff := image.NewRGBA(image.Rect(0, 0, want.Rect.Dx(), want.Rect.Dy()))
+	n := 0
+	for y := 0; y < diff.Rect.Dy(); y++ {
+		for x := 0; x < diff.Rect.Dx(); x++ {
+			w := want.RGBAAt(want.Rect.Min.X+x, want.Rect.Min.Y+y)
+			g := got.RGBAAt(got.Rect.Min.X+x, got.Rect.Min.Y+y)
+			if !within(w, g) {
+				n++
+				diff.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+				continue
+			}
+			gray := uint8((uint32(w.R) + uint32(w.G) + uint32(w.B)) / 3 / 4)
+			diff.SetRGBA(x, y, color.RGBA{gray, gray, gray, 255})
+		}
+	}
+	return diff, n
+}
+
+func within(a, b color.RGBA) bool {
+	d := func(x, y uint8) bool {
+		if x > y {
+			x, y = y, x
+		}
+		return y-x <= tolerance
+	}
+	return d(a.R, b.R) && d(a.G, b.G) && d(a.B, b.B) && d(a.A, b.A)
+}
+
+func readPNG(path string) (*image.RGBA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	src, err := png.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	if img, ok := src.(*image.RGBA); ok {
+		return img, nil
+	}
+	img := image.NewRGBA(src.Bounds())
+	draw.Draw(img, img.Rect, src, img.Rect.Min, draw.Src)
+	return img, nil
+}
+
+func writePNG(path string, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// TestGoldenTurtle draws with Turtle calls and compares the results with
+// golden images
+func TestGoldenTurtle(t *testing.T) {
+	tests := []struct {
+		name string
+		draw func(*Turtle) error
+	}{
+		{"square", func(tt *Turtle) error {
+			for i := 0; i < 4; i++ {
+				if err := tt.Forward(80); err != nil {
+					return err
+				}
+				tt.Left(90)
+			}
+			return nil
+		}},
+		{"pensize", func(tt *Turtle) error {
+			tt.SetPenSize(6)
+			tt.SetPosition(-80, -60)
+			tt.Left(30)
+			return tt.Forward(150)
+		}},
+		{"arc", func(tt *Turtle) error {
+			tt.SetForegroundColor(255, 255, 0, 255)
+			return tt.DrawArc(270, 60)
+		}},
+		{"filled", func(tt *Turtle) error {
+			tt.SetForegroundColor(0, 0, 255, 255)
+			tt.Filled(255, 0, 0, 255, func() {
+				for i := 0; i < 5; i++ {
+					tt.Forward(90)
+					tt.Right(144)
+				}
+			})
+			return nil
+		}},
+		{"bucketfill", func(tt *Turtle) error {
+			tt.SetPosition(-50, -50)
+			for i := 0; i < 3; i++ {
+				if err := tt.Forward(100); err != nil {
+					return err
+				}
+				tt.Left(120)
+			}
+			tt.PenUp()
+			tt.SetPosition(0, -30)
+			tt.SetForegroundColor(0, 255, 0, 255)
+			return tt.BucketFill()
+		}},
+		{"label", func(tt *Turtle) error {
+			tt.SetPosition(0, 40)
+			if err := tt.PrintLabel("Gortle!"); err != nil {
+				return err
+			}
+			tt.SetFontSize(24)
+			tt.SetPosition(0, -20)
+			return tt.PrintLabel("Logo 123")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drawGolden(t, tt.name, tt.draw)
+		})
+	}
+}
+
+// TestGoldenPrograms runs each testdata/golden/*.lg program and compares
+// what it draws with the .png of the same name. The turtle is set up
+// only as the command line sets it up, so that the programs draw from
+// the state the interpreter starts in.
+func TestGoldenPrograms(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(goldenDir, "*.lg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("No programs in %s", goldenDir)
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".lg")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := NewRaster(goldenWidth, goldenHeight)
+			turtle := NewTurtle(r)
+			turtle.Clear()
+			var out bytes.Buffer
+			if err := eval.New(&out, turtle).Run(file, bytes.NewReader(src)); err != nil {
+				t.Fatalf("%s: %v\n%s", name, err, out.String())
+			}
+			checkGolden(t, name, r.Image())
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package turtle
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strings"
+
+	"gortle/internal/logoerr"
+)
+
+func init() {
+	image.RegisterFormat("bmp", "BM", decodeBMP, decodeBMPConfig)
+	image.RegisterFormat("ppm", "P6", decodePPM, decodePPMConfig)
+}
+
+// SavePicture writes what has been drawn as a "png", "bmp" or "ppm"
+// image, or drawn from the display list as "svg", or as "eps" or "pdf"
+// fitted to the paper. BMP and PPM have no alpha channel, so the picture
+// is written as if it were opaque.
+func (t *Turtle) SavePicture(w io.Writer, format string) error {
+	format = strings.ToLower(format)
+	var write func(io.Writer) error
+	switch format {
+	case "svg":
+		write = t.writeSVG
+	case "eps":
+		write = t.writeEPS
+	case "pdf":
+		write = t.writePDF
+	}
+	if write != nil {
+		if err := write(w); err != nil {
+			return logoerr.Errorf(logoerr.ERR_FileSystem, "savepict: %v", err)
+		}
+		return nil
+	}
+	img, err := t.canvas.ReadPixels()
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "png":
+		err = png.Encode(w, img)
+	case "bmp":
+		err = encodeBMP(w, img)
+	case "ppm":
+		err = encodePPM(w, img)
+	default:
+		return logoerr.Errorf(logoerr.ERR_Graphics, "savepict: unknown picture format %s", format)
+	}
+	if err != nil {
+		return logoerr.Errorf(logoerr.ERR_FileSystem, "savepict: %v", err)
+	}
+	return nil
+}
+
+// LoadPicture reads a PNG, BMP or PPM image and draws it in the middle
+// of the canvas, replacing what is under it. A picture bigger than the
+// canvas is cut down to fit.
+func (t *Turtle) LoadPicture(r io.Reader) error {
+	src, _, err := image.Decode(bufio.NewReader(r))
+	if err != nil {
+		return logoerr.Errorf(logoerr.ERR_FileSystem, "loadpict: %v", err)
+	}
+	dst, err := t.canvas.ReadPixels()
+	if err != nil {
+		return err
+	}
+	before := image.NewRGBA(dst.Rect)
+	copy(before.Pix, dst.Pix)
+	b := src.Bounds()
+	at := image.Pt((t.width-b.Dx())/2, (t.height-b.Dy())/2)
+	draw.Draw(dst, image.Rectangle{at, at.Add(b.Size())}, src, b.Min, draw.Src)
+	if err := t.canvas.WritePixels(dst); err != nil {
+		return err
+	}
+	if patch := changed(before, dst); patch != nil {
+		t.record(bitmap{patch})
+	}
+	t.canvas.Present()
+	return nil
+}
+
+// encodeBMP writes img as an uncompressed 24-bit BMP.
+func encodeBMP(w io.Writer, img *image.RGBA) error {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	stride := (width*3 + 3) &^ 3
+	const headerSize = 14 + 40
+	bw := bufio.NewWriter(w)
+
+	header := []any{
+		[2]byte{'B', 'M'},
+		uint32(headerSize + stride*height), // file size
+		uint32(0),                          // reserved
+		uint32(headerSize),                 // offset to the pixels
+		uint32(40),                         // info header size
+		int32(width),
+		int32(height), // positive: rows run bottom to top
+		uint16(1),     // planes
+		uint16(24),    // bits per pixel
+		uint32(0),     // no compression
+		uint32(stride * height),
+		int32(2835), // 72 dpi
+		int32(2835),
+		uint32(0), // colors used
+		uint32(0), // important colors
+	}
+	for _, v := range header {
+		if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	row := make([]byte, stride)
+	for y := height - 1; y >= 0; y-- {
+		for x := 0; x < width; x++ {
+			c := img.RGBAAt(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+			row[x*3], row[x*3+1], row[x*3+2] = c.B, c.G, c.R
+		}
+		if _, err := bw.Write(row); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+type bmpHeader struct {
+	Magic      [2]byte
+	FileSize   uint32
+	Reserved   uint32
+	Offset     uint32
+	InfoSize   uint32
+	Width      int32
+	Height     int32
+	Planes     uint16
+	Bits       uint16
+	Compressed uint32
+}
+
+func readBMPHeader(r io.Reader) (bmpHeader, error) {
+	var h bmpHeader
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return h, err
+	}
+	switch {
+	case h.Magic != [2]byte{'B', 'M'}:
+		return h, errors.New("bmp: not a BMP file")
+	case h.InfoSize < 40:
+		return h, errors.New("bmp: unsupported header")
+	case h.Bits != 24 && h.Bits != 32 || h.Compressed != 0:
+		return h, fmt.Errorf("bmp: unsupported %d-bit or compressed image", h.Bits)
+	case h.Width <= 0 || h.Height == 0:
+		return h, errors.New("bmp: bad size")
+	}
+	return h, nil
+}
+
+func decodeBMPConfig(r io.Reader) (image.Config, error) {
+	h, err := readBMPHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	height := int(h.Height)
+	if height < 0 {
+		height = -height
+	}
+	return image.Config{ColorModel: color.RGBAModel, Width: int(h.Width), Height: height}, nil
+}
+
+// decodeBMP reads uncompressed 24- and 32-bit BMPs, the kind most
+// programs write.
+func decodeBMP(r io.Reader) (image.Image, error) {
+	h, err := readBMPHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	skip := int64(h.Offset) - int64(binary.Size(h))
+	if skip < 0 {
+		return nil, errors.New("bmp: bad pixel offset")
+	}
+	if _, err := io.CopyN(io.Discard, r, skip); err != nil {
+		return nil, err
+	}
+
+	width, height := int(h.Width), int(h.Height)
+	topDown := height < 0
+	if topDown {
+		height = -height
+	}
+	bytesPer := int(h.Bits) / 8
+	stride := (width*bytesPer + 3) &^ 3
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	row := make([]byte, stride)
+	for i := 0; i < height; i++ {
+		if _, err := io.ReadFull(r, row); err != nil {
+			return nil, err
+		}
+		y := height - 1 - i
+		if topDown {
+			y = i
+		}
+		for x := 0; x < width; x++ {
+			p := row[x*bytesPer:]
+			img.SetRGBA(x, y, color.RGBA{p[2], p[1], p[0], 255})
+		}
+	}
+	return img, nil
+}
+
+// encodePPM writes img as a binary (P6) PPM.
+func encodePPM(w io.Writer, img *image.RGBA) error {
+	bw := bufio.NewWriter(w)
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	fmt.Fprintf(bw, "P6\n%d %d\n255\n", width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := img.RGBAAt(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+			bw.Write([]byte{c.R, c.G, c.B})
+		}
+	}
+	return bw.Flush()
+}
+
+// readPPMHeader reads the magic number, size and maximum value of a P6
+// PPM, skipping comments, and the single space that ends the header.
+func readPPMHeader(r io.ByteReader) (width, height, maxval int, err error) {
+	var fields [4]int
+	magic := []byte{}
+	for i := 0; i < len(fields); {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		switch {
+		case b == '#':
+			for b != '\n' {
+				if b, err = r.ReadByte(); err != nil {
+					return 0, 0, 0, err
+				}
+			}
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+			if i == 0 && len(magic) > 0 || i > 0 && fields[i] > 0 {
+				i++
+			}
+		case i == 0:
+			magic = append(magic, b)
+		case b >= '0' && b <= '9' && fields[i] < 1<<20:
+			fields[i] = fields[i]*10 + int(b-'0')
+		default:
+			return 0, 0, 0, errors.New("ppm: bad header")
+		}
+	}
+	width, height, maxval = fields[1], fields[2], fields[3]
+	switch {
+	case string(magic) != "P6":
+		return 0, 0, 0, errors.New("ppm: not a binary PPM file")
+	case width == 0 || height == 0:
+		return 0, 0, 0, errors.New("ppm: bad size")
+	case maxval == 0 || maxval > 255:
+		return 0, 0, 0, fmt.Errorf("ppm: unsupported maximum value %d", maxval)
+	}
+	return width, height, maxval, nil
+}
+
+func decodePPMConfig(r io.Reader) (image.Config, error) {
+	width, height, _, err := readPPMHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.RGBAModel, Width: width, Height: height}, nil
+}
+
+func decodePPM(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	width, height, maxval, err := readPPMHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	row := make([]byte, width*3)
+	scale := func(v byte) uint8 { return uint8(min(int(v), maxval) * 255 / maxval) }
+	for y := 0; y < height; y++ {
+		if _, err := io.ReadFull(br, row); err != nil {
+			return nil, err
+		}
+		for x := 0; x < width; x++ {
+			p := row[x*3:]
+			img.SetRGBA(x, y, color.RGBA{scale(p[0]), scale(p[1]), scale(p[2]), 255})
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,157 @@
+package turtle
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// drawing makes a turtle on a small raster with something drawn on it.
+func drawing() (*Turtle, *Raster) {
+	turtle, r := newTestTurtle(31, 17)
+	turtle.SetPenSize(3)
+	turtle.Forward(12)
+	turtle.Left(90)
+	turtle.SetForegroundColor(10, 200, 30, 255)
+	turtle.Forward(6)
+	return turtle, r
+}
+
+// TestPictureRoundTrip tests that each format reads back as it was
+// written
+func TestPictureRoundTrip(t *testing.T) {
+	for _, format := range []string{"png", "bmp", "ppm", "PPM"} {
+		turtle, r := drawing()
+		var buf bytes.Buffer
+		if err := turtle.SavePicture(&buf, format); err != nil {
+			t.Fatalf("%s: SavePicture failed: %v", format, err)
+		}
+
+		loaded := NewRaster(31, 17)
+		if err := NewTurtle(loaded).LoadPicture(&buf); err != nil {
+			t.Fatalf("%s: LoadPicture failed: %v", format, err)
+		}
+		if _, n := diffImages(r.Image(), loaded.Image()); n != 0 {
+			t.Errorf("%s: %d pixels differ after loading", format, n)
+		}
+	}
+
+	turtle, _ := drawing()
+	if err := turtle.SavePicture(&bytes.Buffer{}, "gif"); err == nil {
+		t.Errorf("Expected an error saving a GIF")
+	}
+}
+
+// TestPictureHeaders tests the headers written for BMP and PPM
+func TestPictureHeaders(t *testing.T) {
+	turtle, _ := drawing()
+	var buf bytes.Buffer
+	turtle.SavePicture(&buf, "ppm")
+	if !strings.HasPrefix(buf.String(), "P6\n31 17\n255\n") || buf.Len() != 13+31*17*3 {
+		t.Errorf("Unexpected PPM: %q... (%d bytes)", buf.String()[:13], buf.Len())
+	}
+
+	buf.Reset()
+	turtle.SavePicture(&buf, "bmp")
+	data := buf.Bytes()
+	stride := 96 // 31*3 rounded up to a multiple of 4
+	if string(data[:2]) != "BM" || len(data) != 54+stride*17 ||
+		binary.LittleEndian.Uint32(data[2:]) != uint32(len(data)) {
+		t.Errorf("Unexpected BMP header % x", data[:14])
+	}
+	// The last row in the file is the top of the picture, which is
+	// black; the turtle drew across the middle.
+	if data[len(data)-stride] != 0 {
+		t.Errorf("Expected rows from the bottom up")
+	}
+}
+
+// TestLoadPicture tests that a picture is centred, replaces what is under
+// it and is clipped to the canvas
+func TestLoadPicture(t *testing.T) {
+	small := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for i := range small.Pix {
+		small.Pix[i] = 255
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, small)
+
+	turtle, r := newTestTurtle(10, 6)
+	if err := turtle.LoadPicture(&buf); err != nil {
+		t.Fatalf("LoadPicture failed: %v", err)
+	}
+	white := color.RGBA{255, 255, 255, 255}
+	if n := count(r.Image(), image.Rect(3, 2, 7, 4), white); n != 8 {
+		t.Errorf("Expected the picture in the middle, got %d pixels", n)
+	}
+	if n := count(r.Image(), r.Image().Rect, black); n != 60-8 {
+		t.Errorf("Expected the rest left alone")
+	}
+
+	big := image.NewRGBA(image.Rect(0, 0, 30, 30))
+	buf.Reset()
+	png.Encode(&buf, big)
+	if err := turtle.LoadPicture(&buf); err != nil {
+		t.Fatalf("LoadPicture failed: %v", err)
+	}
+	if n := count(r.Image(), r.Image().Rect, color.RGBA{}); n != 60 {
+		t.Errorf("Expected a big picture to cover the canvas, got %d pixels", n)
+	}
+
+	if err := turtle.LoadPicture(strings.NewReader("not a picture")); err == nil {
+		t.Errorf("Expected an error loading garbage")
+	}
+}
+
+// TestDecodePPM tests comments and maximum values other than 255
+func TestDecodePPM(t *testing.T) {
+	src := "P6 # made by hand\n2 1\n# max\n15\n" + string([]byte{15, 0, 5, 0, 15, 0})
+	img, format, err := image.Decode(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if format != "ppm" {
+		t.Errorf("Expected ppm, got %s", format)
+	}
+	want := []color.RGBA{{255, 0, 85, 255}, {0, 255, 0, 255}}
+	for x, c := range want {
+		if got := img.(*image.RGBA).RGBAAt(x, 0); got != c {
+			t.Errorf("Expected %v at %d, got %v", c, x, got)
+		}
+	}
+
+	for _, bad := range []string{"P6\n2 1\n65535\n", "P6\n0 1\n255\n", "P6\n2 x\n255\n", "P6\n2 1\n255\n\x01"} {
+		if _, _, err := image.Decode(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+// TestDecodeBMP tests 32-bit pictures stored top down, as some programs
+// write them
+func TestDecodeBMP(t *testing.T) {
+	var buf bytes.Buffer
+	for _, v := range []any{
+		[2]byte{'B', 'M'}, uint32(54 + 8), uint32(0), uint32(54),
+		uint32(40), int32(1), int32(-2), uint16(1), uint16(32), uint32(0),
+		uint32(8), int32(0), int32(0), uint32(0), uint32(0),
+		[]byte{1, 2, 3, 0, 4, 5, 6, 0},
+	} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	img, _, err := image.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	rgba := img.(*image.RGBA)
+	if got := rgba.RGBAAt(0, 0); got != (color.RGBA{3, 2, 1, 255}) {
+		t.Errorf("Expected the first row at the top, got %v", got)
+	}
+	if got := rgba.RGBAAt(0, 1); got != (color.RGBA{6, 5, 4, 255}) {
+		t.Errorf("Expected the second row below, got %v", got)
+	}
+}
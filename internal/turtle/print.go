@@ -0,0 +1,377 @@
+package turtle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Paper is the page an EPS or PDF picture is printed on, in PostScript
+// points of 1/72 inch, and the margin left clear on each side of it.
+type Paper struct {
+	Width, Height, Margin float64
+}
+
+// DefaultMargin is the margin of a paper named without one: half an inch.
+const DefaultMargin = 36
+
+var papers = map[string]Paper{
+	"a3":      {842, 1191, DefaultMargin},
+	"a4":      {595, 842, DefaultMargin},
+	"a5":      {420, 595, DefaultMargin},
+	"letter":  {612, 792, DefaultMargin},
+	"legal":   {612, 1008, DefaultMargin},
+	"tabloid": {792, 1224, DefaultMargin},
+}
+
+// ParsePaper reads a paper size: a name such as "a4" or "letter", or a
+// width and height in points, either followed by a margin in points.
+func ParsePaper(spec string) (Paper, error) {
+	fields := strings.Fields(strings.ToLower(spec))
+	var p Paper
+	var rest []string
+	if len(fields) > 0 {
+		if named, ok := papers[fields[0]]; ok {
+			p, rest = named, fields[1:]
+		}
+	}
+	if rest == nil {
+		if len(fields) < 2 {
+			return p, fmt.Errorf("unknown paper %q", spec)
+		}
+		p.Margin, rest = DefaultMargin, fields[2:]
+		var err1, err2 error
+		p.Width, err1 = strconv.ParseFloat(fields[0], 64)
+		p.Height, err2 = strconv.ParseFloat(fields[1], 64)
+		if err1 != nil || err2 != nil {
+			return p, fmt.Errorf("unknown paper %q", spec)
+		}
+	}
+	switch len(rest) {
+	case 0:
+	case 1:
+		m, err := strconv.ParseFloat(rest[0], 64)
+		if err != nil {
+			return p, fmt.Errorf("bad margin %q", rest[0])
+		}
+		p.Margin = m
+	default:
+		return p, fmt.Errorf("unknown paper %q", spec)
+	}
+	if p.Margin < 0 || p.Width-2*p.Margin <= 0 || p.Height-2*p.Margin <= 0 {
+		return p, errors.New("no room on the paper inside the margins")
+	}
+	return p, nil
+}
+
+// SetPaper sets the paper EPS and PDF pictures are printed on, as
+// ParsePaper reads it.
+func (t *Turtle) SetPaper(spec string) error {
+	p, err := ParsePaper(spec)
+	if err != nil {
+		return err
+	}
+	t.paper = p
+	return nil
+}
+
+// Labels are printed in Courier, whose letters are all 0.6 of the font
+// size wide, so that they can be centred without the font's metrics.
+// Their middle is about 0.3 of the font size above the baseline.
+const (
+	courierAdvance = 0.6
+	courierMiddle  = 0.3
+)
+
+// printer draws the display list on a page, as PostScript or as a PDF
+// content stream, whose operators differ but whose model is the same.
+// The drawing is scaled to fit inside the margins and centred, with
+// canvas pixels mapped to squares of scale points.
+type printer struct {
+	buf       bytes.Buffer
+	pdf       bool
+	scale     float64
+	left, top float64
+	// alphas names the graphics states that set the opacity for PDF, as
+	// PostScript has none.
+	alphas map[uint8]string
+}
+
+func (t *Turtle) newPrinter(pdf bool) *printer {
+	pw := t.paper.Width - 2*t.paper.Margin
+	ph := t.paper.Height - 2*t.paper.Margin
+	scale := min(pw/float64(t.width), ph/float64(t.height))
+	return &printer{
+		pdf:    pdf,
+		scale:  scale,
+		left:   (t.paper.Width - float64(t.width)*scale) / 2,
+		top:    (t.paper.Height + float64(t.height)*scale) / 2,
+		alphas: map[uint8]string{},
+	}
+}
+
+// page draws the background and everything on the display list.
+func (p *printer) page(t *Turtle) {
+	if bg := t.background; bg != nil && bg.A > 0 {
+		p.rect(0, 0, t.width, t.height, *bg)
+	}
+	for _, s := range t.display {
+		s.print(p)
+	}
+}
+
+// onPage is where the canvas position x, y falls on the page.
+func (p *printer) onPage(x, y float64) (float64, float64) {
+	return p.left + x*p.scale, p.top - y*p.scale
+}
+
+// point is onPage written as PostScript or PDF operands.
+func (p *printer) point(x, y float64) string {
+	px, py := p.onPage(x, y)
+	return num(round2(px)) + " " + num(round2(py))
+}
+
+// round2 rounds to a hundredth of a point, well under what a printer
+// can show, to keep the numbers short.
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func (p *printer) printf(format string, args ...any) {
+	fmt.Fprintf(&p.buf, format, args...)
+}
+
+// begin saves the graphics state and sets c as the color to paint with.
+func (p *printer) begin(c color.RGBA, fill bool) {
+	r, g, b := num(round2(float64(c.R)/255)), num(round2(float64(c.G)/255)), num(round2(float64(c.B)/255))
+	if !p.pdf {
+		p.printf("gsave %s %s %s setrgbcolor\n", r, g, b)
+		return
+	}
+	p.printf("q")
+	if c.A < 255 {
+		name, ok := p.alphas[c.A]
+		if !ok {
+			name = fmt.Sprintf("GS%d", len(p.alphas)+1)
+			p.alphas[c.A] = name
+		}
+		p.printf(" /%s gs", name)
+	}
+	op := "RG"
+	if fill {
+		op = "rg"
+	}
+	p.printf(" %s %s %s %s\n", r, g, b, op)
+}
+
+func (p *printer) end() {
+	if p.pdf {
+		p.printf("Q\n")
+	} else {
+		p.printf("grestore\n")
+	}
+}
+
+// path traces a line through pts, given as pixels, closing it if asked.
+func (p *printer) path(pts [][2]int, closed bool) {
+	for i, pt := range pts {
+		at := p.point(float64(pt[0])+0.5, float64(pt[1])+0.5)
+		switch {
+		case p.pdf && i == 0:
+			p.printf("%s m\n", at)
+		case p.pdf:
+			p.printf("%s l\n", at)
+		case i == 0:
+			p.printf("newpath %s moveto\n", at)
+		default:
+			p.printf("%s lineto\n", at)
+		}
+	}
+	if closed {
+		if p.pdf {
+			p.printf("h\n")
+		} else {
+			p.printf("closepath\n")
+		}
+	}
+}
+
+// stroke paints the current path with a pen width pixels wide, and
+// square ends if asked.
+func (p *printer) stroke(width int, square bool) {
+	linecap := 0
+	if square {
+		linecap = 2
+	}
+	w := num(round2(float64(max(width, 1)) * p.scale))
+	if p.pdf {
+		p.printf("%s w %d J 1 j S\n", w, linecap)
+	} else {
+		p.printf("%s setlinewidth %d setlinecap 1 setlinejoin stroke\n", w, linecap)
+	}
+}
+
+func (p *printer) fill() {
+	if p.pdf {
+		p.printf("f\n")
+	} else {
+		p.printf("fill\n")
+	}
+}
+
+// rect fills w by h pixels from x, y.
+func (p *printer) rect(x, y, w, h int, c color.RGBA) {
+	p.begin(c, true)
+	at := p.point(float64(x), float64(y+h))
+	size := num(round2(float64(w)*p.scale)) + " " + num(round2(float64(h)*p.scale))
+	if p.pdf {
+		p.printf("%s %s re f\n", at, size)
+	} else {
+		p.printf("%s %s rectfill\n", at, size)
+	}
+	p.end()
+}
+
+// text writes s centred on the middle of pixel x, y.
+func (p *printer) text(x, y int, s string, size float64, c color.RGBA) {
+	size *= p.scale
+	width := float64(utf8.RuneCountInString(s)) * size * courierAdvance
+	px, py := p.onPage(float64(x)+0.5, float64(y)+0.5)
+	at := num(round2(px-width/2)) + " " + num(round2(py-size*courierMiddle))
+	p.begin(c, true)
+	if p.pdf {
+		p.printf("BT /F1 %s Tf %s Td %s Tj ET\n", num(round2(size)), at, literal(s))
+	} else {
+		p.printf("/Courier findfont %s scalefont setfont %s moveto %s show\n", num(round2(size)), at, literal(s))
+	}
+	p.end()
+}
+
+// literal quotes s as a PostScript or PDF string. Only ASCII is kept, as
+// the standard fonts are not encoded for more.
+func literal(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ' || r > '~':
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+func (s stroke) print(p *printer) {
+	p.begin(s.color, false)
+	p.path([][2]int{{s.x1, s.y1}, {s.x2, s.y2}}, false)
+	p.stroke(s.width, s.squareEnds())
+	p.end()
+}
+
+func (pg polygon) print(p *printer) {
+	pts := make([][2]int, len(pg.pts))
+	for i, pt := range pg.pts {
+		pts[i] = [2]int{pt.X, pt.Y}
+	}
+	p.begin(pg.fill, true)
+	p.path(pts, true)
+	p.fill()
+	p.end()
+	p.begin(pg.outline, false)
+	p.path(pts, true)
+	p.stroke(pg.width, false)
+	p.end()
+}
+
+func (c caption) print(p *printer) {
+	p.text(c.x, c.y, c.text, float64(c.font.Size), c.color)
+}
+
+// A bitmap is printed as runs of pixels of one color, so that the pixels
+// it leaves transparent stay so.
+func (b bitmap) print(p *printer) {
+	r := b.img.Rect
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; {
+			c := b.img.RGBAAt(x, y)
+			run := 1
+			for x+run < r.Max.X && b.img.RGBAAt(x+run, y) == c {
+				run++
+			}
+			if c.A > 0 {
+				p.rect(x, y, run, 1, c)
+			}
+			x += run
+		}
+	}
+}
+
+// writeEPS writes the drawing as Encapsulated PostScript the size of the
+// paper. PostScript has no transparency, so colors are painted opaque.
+func (t *Turtle) writeEPS(w io.Writer) error {
+	p := t.newPrinter(false)
+	p.page(t)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%%!PS-Adobe-3.0 EPSF-3.0\n")
+	fmt.Fprintf(bw, "%%%%BoundingBox: 0 0 %d %d\n", int(math.Ceil(t.paper.Width)), int(math.Ceil(t.paper.Height)))
+	fmt.Fprintf(bw, "%%%%HiResBoundingBox: 0 0 %s %s\n", num(t.paper.Width), num(t.paper.Height))
+	fmt.Fprintf(bw, "%%%%Creator: gortle\n")
+	fmt.Fprintf(bw, "%%%%LanguageLevel: 2\n")
+	fmt.Fprintf(bw, "%%%%EndComments\n")
+	bw.Write(p.buf.Bytes())
+	fmt.Fprintf(bw, "showpage\n%%%%EOF\n")
+	return bw.Flush()
+}
+
+// writePDF writes the drawing as a PDF of one page the size of the
+// paper. The content is left uncompressed.
+func (t *Turtle) writePDF(w io.Writer) error {
+	p := t.newPrinter(true)
+	p.page(t)
+
+	var states strings.Builder
+	for a := 0; a < 255; a++ {
+		if name, ok := p.alphas[uint8(a)]; ok {
+			alpha := num(round2(float64(a) / 255))
+			fmt.Fprintf(&states, " /%s << /CA %s /ca %s >>", name, alpha, alpha)
+		}
+	}
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] "+
+			"/Resources << /Font << /F1 5 0 R >> /ExtGState <<%s >> >> /Contents 4 0 R >>",
+			num(t.paper.Width), num(t.paper.Height), states.String()),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.buf.Len(), p.buf.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>",
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	_, err := w.Write(out.Bytes())
+	return err
+}
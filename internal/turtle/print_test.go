@@ -0,0 +1,193 @@
+package turtle
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestParsePaper tests paper names, sizes and margins
+func TestParsePaper(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Paper
+	}{
+		{"a4", Paper{595, 842, 36}},
+		{"Letter", Paper{612, 792, 36}},
+		{"a3 0", Paper{842, 1191, 0}},
+		{"400 300", Paper{400, 300, 36}},
+		{" 400  300 10.5 ", Paper{400, 300, 10.5}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePaper(tt.spec)
+		if err != nil || got != tt.want {
+			t.Errorf("%q: expected %v, got %v (%v)", tt.spec, tt.want, got, err)
+		}
+	}
+	for _, bad := range []string{"", "a2", "400", "400 x", "a4 x", "a4 36 1", "100 100 50", "a4 -1"} {
+		if _, err := ParsePaper(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+// printed draws on a 100 by 50 canvas, cleared to dark blue, on a 300 by
+// 400 point paper with a 50 point margin, so that the drawing is 200 by
+// 100 points with its top left corner at 50, 250.
+func printed(t *testing.T, format string) string {
+	t.Helper()
+	turtle := NewTurtle(NewRaster(100, 50))
+	if err := turtle.SetPaper("300 400 50"); err != nil {
+		t.Fatal(err)
+	}
+	turtle.SetBackgroundColor(0, 0, 51, 255)
+	turtle.Clear()
+	turtle.HideTurtle()
+	turtle.SetPenSize(4)
+	turtle.SetForegroundColor(255, 0, 0, 255)
+	turtle.Forward(20)
+	turtle.SetForegroundColor(255, 255, 255, 51)
+	turtle.Filled(0, 255, 0, 255, func() {
+		turtle.Left(90)
+		turtle.Forward(10)
+		turtle.Left(90)
+		turtle.Forward(10)
+	})
+	turtle.SetFontSize(10)
+	turtle.PrintLabel("(hi)")
+	var buf bytes.Buffer
+	if err := turtle.SavePicture(&buf, format); err != nil {
+		t.Fatalf("SavePicture failed: %v", err)
+	}
+	return buf.String()
+}
+
+// TestEPS tests the EPS header, the scaling and what is drawn
+func TestEPS(t *testing.T) {
+	eps := printed(t, "eps")
+	if !strings.HasPrefix(eps, "%!PS-Adobe-3.0 EPSF-3.0\n") || !strings.HasSuffix(eps, "showpage\n%%EOF\n") {
+		t.Errorf("Expected an EPS file, got\n%s", eps)
+	}
+	if !strings.Contains(eps, "\n%%BoundingBox: 0 0 300 400\n") {
+		t.Errorf("Expected the paper as the bounding box")
+	}
+
+	want := []string{
+		// The background covers the drawing.
+		"gsave 0 0 0.2 setrgbcolor\n50 150 200 100 rectfill\ngrestore\n",
+		// The canvas centre is pixel 50, 25, whose middle is 101, 199.
+		"gsave 1 0 0 setrgbcolor\nnewpath 151 199 moveto\n191 199 lineto\n" +
+			"8 setlinewidth 0 setlinecap 1 setlinejoin stroke\ngrestore\n",
+		"gsave 0 1 0 setrgbcolor\nnewpath 191 199 moveto\n191 219 lineto\n171 221 lineto\nclosepath\nfill\ngrestore\n",
+		"gsave 1 1 1 setrgbcolor\nnewpath 191 199 moveto\n",
+		"/Courier findfont 20 scalefont setfont 147 215 moveto (\\(hi\\)) show\n",
+	}
+	for _, w := range want {
+		if !strings.Contains(eps, w) {
+			t.Errorf("Expected %q in\n%s", w, eps)
+		}
+	}
+
+	// Everything stays inside the margins.
+	for _, m := range regexp.MustCompile(`([\d.]+) ([\d.]+) (moveto|lineto)`).FindAllStringSubmatch(eps, -1) {
+		x, _ := strconv.ParseFloat(m[1], 64)
+		y, _ := strconv.ParseFloat(m[2], 64)
+		if x < 50 || x > 250 || y < 50 || y > 350 {
+			t.Errorf("%s is outside the margins", m[0])
+		}
+	}
+}
+
+// TestPDF tests the structure of the PDF and what is drawn
+func TestPDF(t *testing.T) {
+	pdf := printed(t, "pdf")
+	if !strings.HasPrefix(pdf, "%PDF-1.4\n") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatalf("Expected a PDF file, got\n%s", pdf)
+	}
+
+	// The cross-reference table gives where each object starts.
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	if m == nil {
+		t.Fatalf("No startxref")
+	}
+	xref, _ := strconv.Atoi(m[1])
+	if !strings.HasPrefix(pdf[xref:], "xref\n0 6\n") {
+		t.Fatalf("startxref %d does not point at the table", xref)
+	}
+	entries := strings.Split(pdf[xref:], "\n")[3:8]
+	for i, e := range entries {
+		off, err := strconv.Atoi(e[:10])
+		if err != nil || !strings.HasPrefix(pdf[off:], strconv.Itoa(i+1)+" 0 obj\n") {
+			t.Errorf("Object %d is not at %q", i+1, e)
+		}
+	}
+
+	if !strings.Contains(pdf, "/MediaBox [0 0 300 400]") {
+		t.Errorf("Expected the paper as the media box")
+	}
+	if !strings.Contains(pdf, "/ExtGState << /GS1 << /CA 0.2 /ca 0.2 >> >>") {
+		t.Errorf("Expected a graphics state for the outline's alpha")
+	}
+	if !strings.Contains(pdf, "/BaseFont /Courier") {
+		t.Errorf("Expected the Courier font")
+	}
+
+	m = regexp.MustCompile(`<< /Length (\d+) >>\nstream\n`).FindStringSubmatch(pdf)
+	if m == nil {
+		t.Fatalf("No content stream")
+	}
+	length, _ := strconv.Atoi(m[1])
+	start := strings.Index(pdf, m[0]) + len(m[0])
+	content := pdf[start : start+length]
+	if !strings.HasPrefix(pdf[start+length:], "endstream") {
+		t.Errorf("Content stream is not %d bytes long", length)
+	}
+
+	want := []string{
+		"q 0 0 0.2 rg\n50 150 200 100 re f\nQ\n",
+		"q 1 0 0 RG\n151 199 m\n191 199 l\n8 w 0 J 1 j S\nQ\n",
+		"q 0 1 0 rg\n191 199 m\n191 219 l\n171 221 l\nh\nf\nQ\n",
+		"q /GS1 gs 1 1 1 RG\n191 199 m\n",
+		"BT /F1 20 Tf 147 215 Td (\\(hi\\)) Tj ET\n",
+	}
+	for _, w := range want {
+		if !strings.Contains(content, w) {
+			t.Errorf("Expected %q in\n%s", w, content)
+		}
+	}
+}
+
+// TestPrintBitmap tests that a flood fill is printed as runs of the
+// pixels it changed
+func TestPrintBitmap(t *testing.T) {
+	turtle := NewTurtle(NewRaster(4, 2))
+	turtle.SetBackgroundColor(0, 0, 0, 255)
+	turtle.Clear()
+	turtle.HideTurtle()
+	turtle.SetPaper("8 4 0")
+	turtle.SetForegroundColor(255, 0, 0, 255)
+	turtle.SetAngle(180)
+	turtle.Forward(2)
+	turtle.PenUp()
+	turtle.SetAngle(0)
+	turtle.Forward(3)
+	turtle.SetForegroundColor(0, 0, 255, 255)
+	if err := turtle.BucketFill(); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	turtle.SavePicture(&buf, "eps")
+	rects := regexp.MustCompile(`setrgbcolor\n([\d.]+ [\d.]+ [\d.]+ [\d.]+) rectfill`).FindAllStringSubmatch(buf.String(), -1)
+	var got []string
+	for _, r := range rects {
+		got = append(got, r[1])
+	}
+	// The line leaves the two pixels on the left of the top row and three
+	// of the bottom row out of the fill.
+	want := []string{"0 0 8 4", "4 2 4 2", "6 0 2 2"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected rectangles %q, got %q in\n%s", want, got, buf.String())
+	}
+}
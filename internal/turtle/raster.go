@@ -0,0 +1,269 @@
+package turtle
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"sort"
+
+	"gortle/internal/logoerr"
+)
+
+// Raster is a Canvas kept in memory, for drawing without a display. It
+// draws labels in a built-in 5x7 font whatever font is asked for.
+type Raster struct {
+	img    *image.RGBA
+	sprite *image.RGBA
+}
+
+var _ Canvas = (*Raster)(nil)
+
+// NewRaster makes a w by h raster, cleared to transparent black.
+func NewRaster(w, h int) *Raster {
+	return &Raster{img: image.NewRGBA(image.Rect(0, 0, w, h))}
+}
+
+// Image returns the raster's pixels. Later drawing changes them.
+func (r *Raster) Image() *image.RGBA {
+	return r.img
+}
+
+func (r *Raster) Size() (int, int) {
+	return r.img.Rect.Dx(), r.img.Rect.Dy()
+}
+
+func (r *Raster) Clear(c color.RGBA) {
+	pix := r.img.Pix
+	for i := 0; i < len(pix); i += 4 {
+		pix[i], pix[i+1], pix[i+2], pix[i+3] = c.R, c.G, c.B, c.A
+	}
+}
+
+func (r *Raster) set(x, y int, c color.RGBA) {
+	if (image.Point{x, y}).In(r.img.Rect) {
+		r.img.SetRGBA(x, y, c)
+	}
+}
+
+func (r *Raster) FillSpan(y, x1, x2 int, c color.RGBA) {
+	if y < 0 || y >= r.img.Rect.Dy() {
+		return
+	}
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	x1 = max(x1, 0)
+	x2 = min(x2, r.img.Rect.Dx()-1)
+	for x := x1; x <= x2; x++ {
+		r.img.SetRGBA(x, y, c)
+	}
+}
+
+// DrawLine draws thin lines with Bresenham's algorithm and thick ones as
+// a filled rectangle around the line, the way the SDL canvas does.
+func (r *Raster) DrawLine(x1, y1, x2, y2, width int, c color.RGBA) {
+	if width <= 1 {
+		r.thinLine(x1, y1, x2, y2, c)
+		return
+	}
+
+	dx, dy := float64(x2-x1), float64(y2-y1)
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		half := width / 2
+		for y := y1 - half; y < y1-half+width; y++ {
+			r.FillSpan(y, x1-half, x1-half+width-1, c)
+		}
+		return
+	}
+
+	nx := -dy / length * float64(width) / 2
+	ny := dx / length * float64(width) / 2
+	fx1, fy1, fx2, fy2 := float64(x1), float64(y1), float64(x2), float64(y2)
+	r.fillPolygon([]point{
+		{fx1 + nx, fy1 + ny},
+		{fx1 - nx, fy1 - ny},
+		{fx2 - nx, fy2 - ny},
+		{fx2 + nx, fy2 + ny},
+	}, c)
+}
+
+func (r *Raster) thinLine(x1, y1, x2, y2 int, c color.RGBA) {
+	dx, dy := x2-x1, -(y2 - y1)
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy > 0 {
+		dy = -dy
+	}
+	sx, sy := 1, 1
+	if x1 > x2 {
+		sx = -1
+	}
+	if y1 > y2 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		r.set(x1, y1, c)
+		if x1 == x2 && y1 == y2 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x1 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y1 += sy
+		}
+	}
+}
+
+// fillPolygon fills the pixels whose centres lie inside pts.
+func (r *Raster) fillPolygon(pts []point, c color.RGBA) {
+	minY, maxY := pts[0].Y, pts[0].Y
+	for _, p := range pts[1:] {
+		minY = math.Min(minY, p.Y)
+		maxY = math.Max(maxY, p.Y)
+	}
+
+	xs := make([]float64, 0, len(pts))
+	for y := int(math.Floor(minY)); y <= int(math.Ceil(maxY)); y++ {
+		cy := float64(y) + 0.5
+		xs = xs[:0]
+		for i, p1 := range pts {
+			p2 := pts[(i+1)%len(pts)]
+			if (p1.Y <= cy && p2.Y > cy) || (p2.Y <= cy && p1.Y > cy) {
+				xs = append(xs, p1.X+(cy-p1.Y)/(p2.Y-p1.Y)*(p2.X-p1.X))
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			x1 := int(math.Ceil(xs[i] - 0.5))
+			x2 := int(math.Ceil(xs[i+1]-0.5)) - 1
+			if x1 <= x2 {
+				r.FillSpan(y, x1, x2, c)
+			}
+		}
+	}
+}
+
+func (r *Raster) ReadPixels() (*image.RGBA, error) {
+	img := image.NewRGBA(r.img.Rect)
+	copy(img.Pix, r.img.Pix)
+	return img, nil
+}
+
+func (r *Raster) WritePixels(img *image.RGBA) error {
+	if img.Rect.Size() != r.img.Rect.Size() {
+		return logoerr.Errorf(logoerr.ERR_Graphics, "image is %dx%d, canvas is %dx%d",
+			img.Rect.Dx(), img.Rect.Dy(), r.img.Rect.Dx(), r.img.Rect.Dy())
+	}
+	draw.Draw(r.img, r.img.Rect, img, img.Rect.Min, draw.Src)
+	return nil
+}
+
+// DrawText draws text in the built-in font, scaled to roughly the size
+// asked for. Characters outside printable ASCII are drawn as '?'.
+func (r *Raster) DrawText(x, y int, text string, font Font, c color.RGBA) error {
+	scale := max(1, int(math.Round(float64(font.Size)/8)))
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return nil
+	}
+	w := (len(runes)*6 - 1) * scale
+	h := 7 * scale
+	left, top := x-w/2, y-h/2
+
+	for i, ch := range runes {
+		if ch < ' ' || int(ch-' ') >= len(glyphs) {
+			ch = '?'
+		}
+		for col, bits := range glyphs[ch-' '] {
+			for row := 0; row < 7; row++ {
+				if bits&(1<<row) == 0 {
+					continue
+				}
+				px := left + (i*6+col)*scale
+				py := top + row*scale
+				for k := 0; k < scale; k++ {
+					r.FillSpan(py+k, px, px+scale-1, c)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Raster) LoadSprite(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return logoerr.Errorf(logoerr.ERR_FileSystem, "turtleimage: %v", err)
+	}
+	defer f.Close()
+
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return logoerr.Errorf(logoerr.ERR_FileSystem, "turtleimage: %v", err)
+	}
+	b := src.Bounds()
+	r.sprite = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(r.sprite, r.sprite.Rect, src, b.Min, draw.Src)
+	return nil
+}
+
+// DrawSprite samples the sprite for each pixel it covers once turned,
+// and blends it over what is there.
+func (r *Raster) DrawSprite(x, y int, angle float64) {
+	if r.sprite == nil {
+		return
+	}
+	w, h := r.sprite.Rect.Dx(), r.sprite.Rect.Dy()
+	sw, sh := float64(w), float64(h)
+	// The sprite's top left corner goes at x-w/2, y-h/2, as in the SDL
+	// canvas, and it turns about its middle.
+	cx, cy := float64(x-w/2)+sw/2, float64(y-h/2)+sh/2
+	rad := angle * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+	reach := int(math.Ceil(math.Hypot(sw, sh)/2)) + 1
+
+	for py := y - reach; py <= y+reach; py++ {
+		for px := x - reach; px <= x+reach; px++ {
+			if !(image.Point{px, py}).In(r.img.Rect) {
+				continue
+			}
+			// Turn the offset back to find where it came from in the
+			// sprite. Screen y points down, so counterclockwise is a
+			// negative turn.
+			u, v := float64(px)+0.5-cx, float64(py)+0.5-cy
+			su := u*cos - v*sin + sw/2
+			sv := u*sin + v*cos + sh/2
+			if su < 0 || sv < 0 || su >= sw || sv >= sh {
+				continue
+			}
+			src := r.sprite.RGBAAt(int(su), int(sv))
+			if src.A == 0 {
+				continue
+			}
+			r.img.SetRGBA(px, py, over(src, r.img.RGBAAt(px, py)))
+		}
+	}
+}
+
+// over blends src over dst, src being premultiplied as image.RGBA is.
+func over(src, dst color.RGBA) color.RGBA {
+	k := 255 - uint32(src.A)
+	blend := func(s, d uint8) uint8 {
+		return uint8(uint32(s) + (uint32(d)*k+127)/255)
+	}
+	return color.RGBA{blend(src.R, dst.R), blend(src.G, dst.G), blend(src.B, dst.B), blend(src.A, dst.A)}
+}
+
+func (r *Raster) Present() {}
@@ -0,0 +1,143 @@
+package turtle
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRasterLines tests thin and thick lines and their clipping
+func TestRasterLines(t *testing.T) {
+	r := NewRaster(20, 20)
+	r.DrawLine(2, 3, 12, 8, 1, red)
+	img := r.Image()
+	for _, p := range []image.Point{{2, 3}, {12, 8}, {6, 5}} {
+		if img.RGBAAt(p.X, p.Y) != red {
+			t.Errorf("Expected %v on the line", p)
+		}
+	}
+	if n := count(img, img.Rect, red); n != 11 {
+		t.Errorf("Expected 11 pixels in the line, got %d", n)
+	}
+
+	r.Clear(black)
+	r.DrawLine(5, 10, 15, 10, 4, red)
+	if n := count(img, img.Rect, red); n != 40 {
+		t.Errorf("Expected a 10x4 line, got %d pixels", n)
+	}
+	r.DrawLine(5, 5, 5, 5, 3, green)
+	if n := count(img, image.Rect(4, 4, 7, 7), green); n != 9 {
+		t.Errorf("Expected a 3x3 dot, got %d pixels", n)
+	}
+
+	r.DrawLine(-50, -50, 50, 50, 1, green)
+	r.DrawLine(-5, 25, 30, 25, 9, green)
+}
+
+// TestRasterSpans tests that spans are clipped and may run either way
+func TestRasterSpans(t *testing.T) {
+	r := NewRaster(10, 10)
+	r.FillSpan(4, 7, -3, red)
+	r.FillSpan(5, 8, 20, red)
+	r.FillSpan(-1, 0, 9, red)
+	r.FillSpan(10, 0, 9, red)
+	img := r.Image()
+	if n := count(img, image.Rect(0, 4, 10, 5), red); n != 8 {
+		t.Errorf("Expected 8 pixels in row 4, got %d", n)
+	}
+	if n := count(img, image.Rect(0, 5, 10, 6), red); n != 2 {
+		t.Errorf("Expected 2 pixels in row 5, got %d", n)
+	}
+	if n := count(img, img.Rect, red); n != 10 {
+		t.Errorf("Expected nothing drawn outside, got %d pixels", n-10)
+	}
+}
+
+// TestRasterPixels tests that pixels read back can be written again, and
+// only at the same size
+func TestRasterPixels(t *testing.T) {
+	r := NewRaster(8, 6)
+	r.Clear(black)
+	img, err := r.ReadPixels()
+	if err != nil {
+		t.Fatalf("ReadPixels failed: %v", err)
+	}
+	img.SetRGBA(3, 2, red)
+	if r.Image().RGBAAt(3, 2) != black {
+		t.Errorf("Expected ReadPixels to return a copy")
+	}
+	if err := r.WritePixels(img); err != nil {
+		t.Fatalf("WritePixels failed: %v", err)
+	}
+	if r.Image().RGBAAt(3, 2) != red {
+		t.Errorf("Expected WritePixels to change the raster")
+	}
+	if err := r.WritePixels(image.NewRGBA(image.Rect(0, 0, 6, 8))); err == nil {
+		t.Errorf("Expected an error writing pixels of another size")
+	}
+}
+
+// TestRasterText tests that text is centred and scaled with its size
+func TestRasterText(t *testing.T) {
+	r := NewRaster(40, 20)
+	r.DrawText(20, 10, "|", Font{Size: 8}, red)
+	img := r.Image()
+	if n := count(img, image.Rect(20, 7, 21, 14), red); n != 7 {
+		t.Errorf("Expected a bar 7 pixels high at the centre, got %d", n)
+	}
+
+	r.Clear(black)
+	r.DrawText(20, 10, "|", Font{Size: 16}, red)
+	if n := count(img, img.Rect, red); n != 7*4 {
+		t.Errorf("Expected a bar twice the size, got %d pixels", n)
+	}
+}
+
+// TestRasterSprite tests that the sprite is loaded, turned and blended
+func TestRasterSprite(t *testing.T) {
+	// A 5x3 sprite, transparent but for its rightmost column.
+	sprite := image.NewRGBA(image.Rect(0, 0, 5, 3))
+	for y := 0; y < 3; y++ {
+		sprite.SetRGBA(4, y, red)
+	}
+	path := filepath.Join(t.TempDir(), "sprite.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, sprite); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	r := NewRaster(20, 20)
+	r.Clear(black)
+	r.DrawSprite(10, 10, 0)
+	if n := count(r.Image(), r.Image().Rect, black); n != 400 {
+		t.Errorf("Expected nothing drawn before a sprite is loaded")
+	}
+	if err := r.LoadSprite(path); err != nil {
+		t.Fatalf("LoadSprite failed: %v", err)
+	}
+
+	r.DrawSprite(10, 10, 0)
+	img := r.Image()
+	if n := count(img, image.Rect(12, 9, 13, 12), red); n != 3 {
+		t.Errorf("Expected the sprite's edge to the right, got %d pixels", n)
+	}
+	if n := count(img, img.Rect, black); n != 400-3 {
+		t.Errorf("Expected the transparent part to leave the canvas alone")
+	}
+
+	r.Clear(black)
+	r.DrawSprite(10, 10, 90)
+	if n := count(img, image.Rect(9, 8, 12, 9), red); n != 3 {
+		t.Errorf("Expected the sprite's edge turned to the top, got %d pixels", n)
+	}
+
+	if err := r.LoadSprite(filepath.Join(t.TempDir(), "missing.png")); err == nil {
+		t.Errorf("Expected an error loading a missing sprite")
+	}
+}
@@ -0,0 +1,235 @@
+// Package sdlcanvas draws turtle graphics with an SDL renderer.
+package sdlcanvas
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"unsafe"
+
+	"gortle/internal/logoerr"
+	"gortle/internal/turtle"
+
+	"github.com/veandco/go-sdl2/img"
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+// Canvas is a turtle.Canvas drawn by an SDL renderer, on a window or on
+// a surface.
+type Canvas struct {
+	renderer *sdl.Renderer
+	w, h     int
+	sprite   *sdl.Texture
+	spriteW  int32
+	spriteH  int32
+	font     *ttf.Font
+	fontName turtle.Font
+}
+
+var _ turtle.Canvas = (*Canvas)(nil)
+
+// New makes a w by h canvas drawn by r.
+func New(r *sdl.Renderer, w, h int) *Canvas {
+	return &Canvas{renderer: r, w: w, h: h}
+}
+
+// Close frees the sprite and font. The renderer is left to its owner.
+func (c *Canvas) Close() {
+	if c.sprite != nil {
+		c.sprite.Destroy()
+		c.sprite = nil
+	}
+	c.closeFont()
+}
+
+func (c *Canvas) Size() (int, int) {
+	return c.w, c.h
+}
+
+func (c *Canvas) setColor(col color.RGBA) {
+	c.renderer.SetDrawColor(col.R, col.G, col.B, col.A)
+}
+
+func (c *Canvas) Clear(col color.RGBA) {
+	c.setColor(col)
+	c.renderer.Clear()
+}
+
+func (c *Canvas) DrawLine(x1, y1, x2, y2, width int, col color.RGBA) {
+	c.setColor(col)
+	if width <= 1 {
+		c.renderer.DrawLine(int32(x1), int32(y1), int32(x2), int32(y2))
+		return
+	}
+
+	dx, dy := float64(x2-x1), float64(y2-y1)
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		half := int32(width / 2)
+		c.renderer.FillRect(&sdl.Rect{X: int32(x1) - half, Y: int32(y1) - half, W: int32(width), H: int32(width)})
+		return
+	}
+
+	nx := float32(-dy / length * float64(width) / 2)
+	ny := float32(dx / length * float64(width) / 2)
+	sc := sdl.Color{R: col.R, G: col.G, B: col.B, A: col.A}
+	fx1, fy1, fx2, fy2 := float32(x1), float32(y1), float32(x2), float32(y2)
+	vertices := []sdl.Vertex{
+		{Position: sdl.FPoint{X: fx1 + nx, Y: fy1 + ny}, Color: sc},
+		{Position: sdl.FPoint{X: fx1 - nx, Y: fy1 - ny}, Color: sc},
+		{Position: sdl.FPoint{X: fx2 - nx, Y: fy2 - ny}, Color: sc},
+		{Position: sdl.FPoint{X: fx2 + nx, Y: fy2 + ny}, Color: sc},
+	}
+	c.renderer.RenderGeometry(nil, vertices, []int32{0, 1, 2, 0, 2, 3})
+}
+
+func (c *Canvas) FillSpan(y, x1, x2 int, col color.RGBA) {
+	c.setColor(col)
+	c.renderer.DrawLine(int32(x1), int32(y), int32(x2), int32(y))
+}
+
+func (c *Canvas) ReadPixels() (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, c.w, c.h))
+	if err := c.renderer.ReadPixels(
+		nil,
+		uint32(sdl.PIXELFORMAT_RGBA32),
+		unsafe.Pointer(&img.Pix[0]),
+		img.Stride,
+	); err != nil {
+		return nil, logoerr.Errorf(logoerr.ERR_Graphics, "ReadPixels failed: %v", err)
+	}
+	return img, nil
+}
+
+func (c *Canvas) WritePixels(pix *image.RGBA) error {
+	if pix.Rect.Dx() != c.w || pix.Rect.Dy() != c.h {
+		return logoerr.Errorf(logoerr.ERR_Graphics, "image is %dx%d, canvas is %dx%d",
+			pix.Rect.Dx(), pix.Rect.Dy(), c.w, c.h)
+	}
+	tex, err := c.renderer.CreateTexture(
+		uint32(sdl.PIXELFORMAT_RGBA32),
+		sdl.TEXTUREACCESS_STATIC,
+		int32(c.w),
+		int32(c.h),
+	)
+	if err != nil {
+		return logoerr.Errorf(logoerr.ERR_Graphics, "CreateTexture failed: %v", err)
+	}
+	defer tex.Destroy()
+
+	if err := tex.Update(nil, unsafe.Pointer(&pix.Pix[0]), pix.Stride); err != nil {
+		return logoerr.Errorf(logoerr.ERR_Graphics, "Texture.Update failed: %v", err)
+	}
+	if err := c.renderer.Copy(tex, nil, nil); err != nil {
+		return logoerr.Errorf(logoerr.ERR_Graphics, "Copy failed: %v", err)
+	}
+	return nil
+}
+
+// loadFont opens the font asked for, keeping it open for the next
+// label.
+func (c *Canvas) loadFont(font turtle.Font) error {
+	if c.font != nil && c.fontName == font {
+		return nil
+	}
+	c.closeFont()
+
+	if err := ttf.Init(); err != nil {
+		return logoerr.Errorf(logoerr.ERR_FileSystem, "setlabelfont: ttf.Init failed: %v", err)
+	}
+
+	f, err := ttf.OpenFont(font.Path, int(font.Size))
+	if err != nil {
+		return logoerr.Errorf(logoerr.ERR_FileSystem, "setlabelfont: ttf.OpenFont failed: %v", err)
+	}
+	c.font, c.fontName = f, font
+	return nil
+}
+
+func (c *Canvas) closeFont() {
+	if c.font != nil {
+		c.font.Close()
+		c.font = nil
+	}
+}
+
+func (c *Canvas) DrawText(x, y int, text string, font turtle.Font, col color.RGBA) error {
+	if err := c.loadFont(font); err != nil {
+		return err
+	}
+
+	surf, err := c.font.RenderUTF8Blended(text, sdl.Color{R: col.R, G: col.G, B: col.B, A: col.A})
+	if err != nil {
+		return logoerr.Errorf(logoerr.ERR_Graphics, "printlabel: ttf.RenderUTF8Blended failed: %v", err)
+	}
+	defer surf.Free()
+
+	tex, err := c.renderer.CreateTextureFromSurface(surf)
+	if err != nil {
+		return logoerr.Errorf(logoerr.ERR_Graphics, "printlabel: sdl.CreateTextureFromSurface failed: %v", err)
+	}
+	defer tex.Destroy()
+
+	w, h := surf.W, surf.H
+	dst := sdl.Rect{
+		X: int32(x) - w/2,
+		Y: int32(y) - h/2,
+		W: w,
+		H: h,
+	}
+
+	if err := c.renderer.Copy(tex, nil, &dst); err != nil {
+		return logoerr.Errorf(logoerr.ERR_Graphics, "printlabel: sdl.Copy failed: %v", err)
+	}
+	return nil
+}
+
+func (c *Canvas) LoadSprite(path string) error {
+	tex, err := img.LoadTexture(c.renderer, path)
+	if err != nil {
+		return logoerr.Errorf(logoerr.ERR_FileSystem, "turtleimage: img.LoadTexture failed: %v", err)
+	}
+
+	_, _, w, h, err := tex.Query()
+	if err != nil {
+		tex.Destroy()
+		return logoerr.Errorf(logoerr.ERR_FileSystem, "turtleimage: tex.Query failed: %v", err)
+	}
+
+	if c.sprite != nil {
+		c.sprite.Destroy()
+	}
+	c.sprite = tex
+	c.spriteW = w
+	c.spriteH = h
+	return nil
+}
+
+func (c *Canvas) DrawSprite(x, y int, angle float64) {
+	if c.sprite == nil {
+		return
+	}
+
+	w, h := c.spriteW, c.spriteH
+	dst := sdl.Rect{
+		X: int32(x) - w/2,
+		Y: int32(y) - h/2,
+		W: w,
+		H: h,
+	}
+
+	center := sdl.Point{X: w / 2, Y: h / 2}
+	c.renderer.CopyEx(
+		c.sprite,
+		nil,
+		&dst,
+		-angle,
+		&center,
+		sdl.FLIP_NONE,
+	)
+}
+
+func (c *Canvas) Present() {
+	c.renderer.Present()
+}
@@ -0,0 +1,136 @@
+package turtle
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// svgWriter writes the display list as SVG. Canvas pixels are one unit
+// square, so a pixel's centre is half a unit in from its corner.
+type svgWriter struct {
+	w       *bufio.Writer
+	sprites map[*sprite]string
+	err     error
+}
+
+// writeSVG writes what has been drawn since the screen was last cleared
+// as an SVG document the size of the canvas.
+func (t *Turtle) writeSVG(w io.Writer) error {
+	sw := &svgWriter{w: bufio.NewWriter(w), sprites: map[*sprite]string{}}
+	sw.printf("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	sw.printf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		t.width, t.height, t.width, t.height)
+	if bg := t.background; bg != nil && bg.A > 0 {
+		sw.printf("<rect width=\"100%%\" height=\"100%%\" fill=\"%s\"%s/>\n", rgb(*bg), opacity("fill", *bg))
+	}
+	for _, s := range t.display {
+		s.svg(sw)
+	}
+	if t.stamp != nil {
+		t.stamp.svg(sw)
+	}
+	sw.printf("</svg>\n")
+	if sw.err != nil {
+		return sw.err
+	}
+	return sw.w.Flush()
+}
+
+func (sw *svgWriter) printf(format string, args ...any) {
+	if sw.err == nil {
+		_, sw.err = fmt.Fprintf(sw.w, format, args...)
+	}
+}
+
+// centre is the SVG coordinate of the middle of pixel v.
+func centre(v int) string {
+	return strconv.FormatFloat(float64(v)+0.5, 'f', -1, 64)
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func rgb(c color.RGBA) string {
+	return fmt.Sprintf("rgb(%d,%d,%d)", c.R, c.G, c.B)
+}
+
+// opacity is the attribute that gives c's alpha to the property named,
+// or nothing if c is opaque.
+func opacity(property string, c color.RGBA) string {
+	if c.A == 255 {
+		return ""
+	}
+	return fmt.Sprintf(" %s-opacity=\"%s\"", property, num(float64(c.A)/255))
+}
+
+func (s stroke) svg(sw *svgWriter) {
+	linecap := "butt"
+	if s.squareEnds() {
+		linecap = "square"
+	}
+	sw.printf("<line x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\" stroke=\"%s\"%s stroke-width=\"%d\" stroke-linecap=\"%s\"/>\n",
+		centre(s.x1), centre(s.y1), centre(s.x2), centre(s.y2),
+		rgb(s.color), opacity("stroke", s.color), max(s.width, 1), linecap)
+}
+
+func (p polygon) svg(sw *svgWriter) {
+	pts := make([]string, len(p.pts))
+	for i, pt := range p.pts {
+		pts[i] = centre(pt.X) + "," + centre(pt.Y)
+	}
+	sw.printf("<polygon points=\"%s\" fill=\"%s\"%s stroke=\"%s\"%s stroke-width=\"%d\" stroke-linejoin=\"round\"/>\n",
+		strings.Join(pts, " "), rgb(p.fill), opacity("fill", p.fill),
+		rgb(p.outline), opacity("stroke", p.outline), max(p.width, 1))
+}
+
+func (c caption) svg(sw *svgWriter) {
+	family := "monospace"
+	if c.font.Path != "" {
+		name := strings.TrimSuffix(filepath.Base(c.font.Path), filepath.Ext(c.font.Path))
+		family = fmt.Sprintf("'%s', monospace", strings.ReplaceAll(name, "'", ""))
+	}
+	var text strings.Builder
+	xml.EscapeText(&text, []byte(c.text))
+	var fam strings.Builder
+	xml.EscapeText(&fam, []byte(family))
+	sw.printf("<text x=\"%s\" y=\"%s\" font-family=\"%s\" font-size=\"%d\" fill=\"%s\"%s text-anchor=\"middle\" dominant-baseline=\"central\">%s</text>\n",
+		centre(c.x), centre(c.y), fam.String(), c.font.Size,
+		rgb(c.color), opacity("fill", c.color), text.String())
+}
+
+func (s stamp) svg(sw *svgWriter) {
+	id, ok := sw.sprites[s.sprite]
+	if !ok {
+		id = fmt.Sprintf("sprite%d", len(sw.sprites)+1)
+		sw.sprites[s.sprite] = id
+		sw.printf("<defs><image id=\"%s\" width=\"%d\" height=\"%d\" href=\"data:image/%s;base64,%s\"/></defs>\n",
+			id, s.sprite.w, s.sprite.h, s.sprite.format, base64.StdEncoding.EncodeToString(s.sprite.data))
+	}
+	// Sprites turn anticlockwise about their middle as the turtle turns
+	// left.
+	sw.printf("<use href=\"#%s\" x=\"%d\" y=\"%d\" transform=\"rotate(%s %d %d)\"/>\n",
+		id, s.x-s.sprite.w/2, s.y-s.sprite.h/2, num(-s.angle), s.x, s.y)
+}
+
+func (b bitmap) svg(sw *svgWriter) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, b.img); err != nil {
+		if sw.err == nil {
+			sw.err = err
+		}
+		return
+	}
+	r := b.img.Rect
+	sw.printf("<image x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" style=\"image-rendering:pixelated\" href=\"data:image/png;base64,%s\"/>\n",
+		r.Min.X, r.Min.Y, r.Dx(), r.Dy(), base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
@@ -0,0 +1,220 @@
+package turtle
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// element is a parsed SVG element.
+type element struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []element  `xml:",any"`
+	Text     string     `xml:",chardata"`
+}
+
+func (e element) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// find returns the elements named, in document order.
+func (e element) find(name string) []element {
+	var found []element
+	for _, c := range e.Children {
+		if c.XMLName.Local == name {
+			found = append(found, c)
+		}
+		found = append(found, c.find(name)...)
+	}
+	return found
+}
+
+func parseSVG(t *testing.T, turtle *Turtle) element {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := turtle.SavePicture(&buf, "SVG"); err != nil {
+		t.Fatalf("SavePicture failed: %v", err)
+	}
+	var root element
+	if err := xml.Unmarshal(buf.Bytes(), &root); err != nil {
+		t.Fatalf("Bad SVG: %v\n%s", err, buf.String())
+	}
+	return root
+}
+
+// TestSVGStrokes tests the size of the document and the lines in it
+func TestSVGStrokes(t *testing.T) {
+	turtle, _ := drawing()
+	turtle.SetForegroundColor(0, 0, 255, 128)
+	turtle.SetPenSize(1)
+	turtle.Forward(2)
+	turtle.PenUp()
+	turtle.Forward(5)
+
+	root := parseSVG(t, turtle)
+	if root.XMLName.Local != "svg" || root.attr("width") != "31" || root.attr("height") != "17" ||
+		root.attr("viewBox") != "0 0 31 17" {
+		t.Errorf("Unexpected document %v", root.Attrs)
+	}
+	if rects := root.find("rect"); len(rects) != 0 {
+		t.Errorf("Expected no background before the screen is cleared")
+	}
+
+	lines := root.find("line")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %d", len(lines))
+	}
+	want := []map[string]string{
+		{"x1": "15.5", "y1": "8.5", "x2": "27.5", "y2": "8.5", "stroke": "rgb(255,0,0)", "stroke-width": "3", "stroke-linecap": "butt"},
+		{"x1": "27.5", "y1": "8.5", "x2": "27.5", "y2": "2.5", "stroke": "rgb(10,200,30)", "stroke-width": "3"},
+		{"stroke": "rgb(0,0,255)", "stroke-width": "1", "stroke-opacity": "0.5019607843137255", "stroke-linecap": "square"},
+	}
+	for i, attrs := range want {
+		for name, v := range attrs {
+			if got := lines[i].attr(name); got != v {
+				t.Errorf("line %d: expected %s=%q, got %q", i, name, v, got)
+			}
+		}
+	}
+	if lines[0].attr("stroke-opacity") != "" {
+		t.Errorf("Expected no opacity on an opaque line")
+	}
+}
+
+// TestSVGShapes tests filled shapes, labels, fills and clearing
+func TestSVGShapes(t *testing.T) {
+	r := NewRaster(40, 40)
+	turtle := NewTurtle(r)
+	turtle.SetBackgroundColor(0, 0, 64, 255)
+	turtle.Clear()
+	turtle.HideTurtle()
+	turtle.SetForegroundColor(255, 255, 0, 255)
+	turtle.Filled(0, 255, 0, 255, func() {
+		for i := 0; i < 3; i++ {
+			turtle.Forward(10)
+			turtle.Left(120)
+		}
+	})
+	turtle.SetFontPath("/fonts/DejaVuSans.ttf")
+	turtle.SetFontSize(9)
+	if err := turtle.PrintLabel("a<b & c"); err != nil {
+		t.Fatalf("PrintLabel failed: %v", err)
+	}
+	turtle.PenUp()
+	turtle.SetPosition(10, -10)
+	turtle.SetForegroundColor(255, 0, 255, 255)
+	if err := turtle.BucketFill(); err != nil {
+		t.Fatalf("BucketFill failed: %v", err)
+	}
+
+	root := parseSVG(t, turtle)
+	rects := root.find("rect")
+	if len(rects) != 1 || rects[0].attr("fill") != "rgb(0,0,64)" {
+		t.Errorf("Expected the background, got %v", rects)
+	}
+
+	polygons := root.find("polygon")
+	if len(polygons) != 1 {
+		t.Fatalf("Expected a polygon, got %d", len(polygons))
+	}
+	p := polygons[0]
+	if len(strings.Fields(p.attr("points"))) != 4 || p.attr("fill") != "rgb(0,255,0)" ||
+		p.attr("stroke") != "rgb(255,255,0)" || p.attr("stroke-width") != "1" {
+		t.Errorf("Unexpected polygon %v", p.Attrs)
+	}
+	if len(root.find("line")) != 0 {
+		t.Errorf("Expected the outline to be part of the polygon")
+	}
+
+	texts := root.find("text")
+	if len(texts) != 1 {
+		t.Fatalf("Expected a label, got %d", len(texts))
+	}
+	if texts[0].Text != "a<b & c" || texts[0].attr("font-size") != "9" ||
+		texts[0].attr("font-family") != "'DejaVuSans', monospace" || texts[0].attr("fill") != "rgb(255,255,0)" {
+		t.Errorf("Unexpected label %q %v", texts[0].Text, texts[0].Attrs)
+	}
+
+	images := root.find("image")
+	if len(images) != 1 || images[0].attr("x") != "0" || images[0].attr("width") != "40" {
+		t.Fatalf("Expected the fill as an image, got %v", images)
+	}
+	img := decodeDataURI(t, images[0].attr("href"))
+	if got := color.RGBAModel.Convert(img.At(39, 39)); got != (color.RGBA{255, 0, 255, 255}) {
+		t.Errorf("Expected the fill in the image, got %v", got)
+	}
+	if got := color.RGBAModel.Convert(img.At(20, 19)); got != (color.RGBA{}) {
+		t.Errorf("Expected the triangle left out of the image, got %v", got)
+	}
+
+	turtle.SetBackgroundColor(255, 0, 255, 255)
+	turtle.Clear()
+	root = parseSVG(t, turtle)
+	if len(root.Children) != 1 || root.Children[0].attr("fill") != "rgb(255,0,255)" {
+		t.Errorf("Expected only the background after clearing, got %v", root.Children)
+	}
+}
+
+// TestSVGStamps tests that the turtle's sprite is written once and used
+// only where the turtle is now
+func TestSVGStamps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "turtle.png")
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 6, 4)))
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	turtle := NewTurtle(NewRaster(50, 50))
+	if err := turtle.LoadTurtleImage(path); err != nil {
+		t.Fatalf("LoadTurtleImage failed: %v", err)
+	}
+	turtle.ShowTurtle()
+	turtle.Forward(10)
+	turtle.Left(90)
+	turtle.Forward(10)
+
+	root := parseSVG(t, turtle)
+	defs := root.find("defs")
+	if len(defs) != 1 || !strings.HasPrefix(defs[0].Children[0].attr("href"), "data:image/png;base64,") {
+		t.Fatalf("Expected the sprite defined once, got %v", defs)
+	}
+	uses := root.find("use")
+	if len(uses) != 1 {
+		t.Fatalf("Expected the sprite used once, got %d", len(uses))
+	}
+	if uses[0].attr("x") != "32" || uses[0].attr("y") != "13" || uses[0].attr("transform") != "rotate(-90 35 15)" {
+		t.Errorf("Unexpected stamp %v", uses[0].Attrs)
+	}
+
+	turtle.HideTurtle()
+	if uses := parseSVG(t, turtle).find("use"); len(uses) != 0 {
+		t.Errorf("Expected no sprite once the turtle is hidden, got %d", len(uses))
+	}
+}
+
+func decodeDataURI(t *testing.T, uri string) image.Image {
+	t.Helper()
+	const prefix = "data:image/png;base64,"
+	if !strings.HasPrefix(uri, prefix) {
+		t.Fatalf("Expected a PNG, got %.30s", uri)
+	}
+	img, err := png.Decode(base64.NewDecoder(base64.StdEncoding, strings.NewReader(strings.TrimPrefix(uri, prefix))))
+	if err != nil {
+		t.Fatalf("Bad PNG: %v", err)
+	}
+	return img
+}
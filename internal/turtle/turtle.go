@@ -1,34 +1,33 @@
 package turtle
 
 import (
-	"fmt"
-	"log"
+	"bytes"
+	"image"
+	"image/color"
 	"math"
 	"os"
 	"sort"
-	"strconv"
-	"strings"
-	"time"
-	"unsafe"
-
-	"github.com/veandco/go-sdl2/img"
-	"github.com/veandco/go-sdl2/sdl"
-	"github.com/veandco/go-sdl2/ttf"
+
+	"gortle/internal/logoerr"
 )
 
 type Wrapping int
 type PenMode int
 
 const (
-	WrappingWrap   Wrapping = iota
-	WrappingFence  Wrapping
-	WrappingWindow Wrapping
+	WrappingWrap Wrapping = iota
+	WrappingFence
+	WrappingWindow
+)
 
-	PenPaint   PenMode = iota
-	PenErase   PenMode
-	PenReverse PenMode
+const (
+	PenPaint PenMode = iota
+	PenErase
+	PenReverse
 )
 
+// WindowWidth and WindowHeight are the size of the canvas made for a
+// turtle by default.
 var (
 	WindowWidth  = 800
 	WindowHeight = 600
@@ -38,10 +37,6 @@ type point struct {
 	X, Y float64
 }
 
-type color struct {
-	R, G, B, A uint8
-}
-
 type Turtle struct {
 	x, y       float64
 	angle      float64
@@ -50,167 +45,110 @@ type Turtle struct {
 	recordPath bool
 	wrapMode   Wrapping
 	penMode    PenMode
-	bgColor    color
-	fgColor    color
+	bgColor    color.RGBA
+	fgColor    color.RGBA
 	scale      float64
+	width      int
+	height     int
 	minX, minY int32
 	maxX, maxY int32
-	spriteW    int32
-	spriteH    int32
 	penSize    int32
 	fontSize   uint
 	fontPath   string
 	path       []point
-	renderer   *sdl.Renderer
-	sprite     *sdl.Texture
-	font       *ttf.Font
-}
-
-func (c color) toSDLColor() {
-	return sdl.color{R: c.R, G: c.G, B: c.B, A: c.A}
-}
-
-func (c color) getFields() (uint8, uint8, uint8, uint8) {
-	return c.r, c.g, c.b, c.a
-}
-
-func (c color) getInverseFields() (uint8, uint8, uint8) {
-	return 255 - c.r, 255 - c.g, 255 - c.b, c.a
-}
-
-func (p point) toSDLpoint() {
-	return sdl.point{X: p.X, Y: p.Y}
-}
-
-func NewTurtle(r *sdl.Renderer, s *sdl.Texture) *Turtle {
+	canvas     Canvas
+	sprite     *sprite
+	paper      Paper
+	display    []shape
+	// stamp is where the sprite was last shown, or nil if the turtle
+	// has been hidden since.
+	stamp *stamp
+	// background is the color the screen was last cleared to, or nil
+	// if it has not been cleared.
+	background *color.RGBA
+}
+
+func inverse(c color.RGBA) color.RGBA {
+	return color.RGBA{255 - c.R, 255 - c.G, 255 - c.B, c.A}
+}
+
+// NewTurtle makes a turtle drawing on c, shown at home with its pen down,
+// drawing in white on black as UCBLogo starts. The canvas is left as it
+// is until the screen is cleared.
+func NewTurtle(c Canvas) *Turtle {
+	w, h := c.Size()
 	t := &Turtle{
 		x:          0,
 		y:          0,
 		angle:      0,
-		penDown:    false,
-		showTurtle: false,
+		penDown:    true,
+		showTurtle: true,
 		recordPath: false,
 		penMode:    PenPaint,
 		wrapMode:   WrappingWrap,
-		bgColor:    color{255, 255, 255, 255},
-		fgColor:    color{0, 0, 0, 0},
+		bgColor:    color.RGBA{0, 0, 0, 255},
+		fgColor:    color.RGBA{255, 255, 255, 255},
 		scale:      1.0,
+		width:      w,
+		height:     h,
 		minX:       0,
 		minY:       0,
-		maxX:       WindowWidth - 1,
-		maxY:       WindowHeight - 1,
-		spriteW:    -1,
-		spriteH:    -1,
+		maxX:       int32(w - 1),
+		maxY:       int32(h - 1),
 		penSize:    1,
 		fontSize:   12,
-		fontPath:   os.GetEnv("GORTLE_DEFAULT_FONTPATH"),
+		fontPath:   os.Getenv("GORTLE_DEFAULT_FONTPATH"),
 		path:       make([]point, 0, 1024),
-		renderer:   r,
-		sprite:     s,
-		font:       nil,
+		canvas:     c,
+		paper:      papers["a4"],
 	}
 	return t
 }
 
-func (t *Turtle) LoadTurtleImage(path string) error {
-	if tex, err := img.LoadTexture(t.renderer, path); err != nil {
-		return fmt.Errorf("turtleimage: img.LoadTexture failed: %v", err)
-	}
-
-	var query sdl.TextureInfo
-	if err := tex.Query(&query); err != nil {
-		tex.Destroy()
-		return fmt.Errorf("turtleimage: tex.Query failed: %v", err)
-	}
-
-	t.sprite = tex
-	t.spriteW = query.Width
-	t.spriteH = query.Height
-
-	return nil
+// Canvas returns what the turtle draws on.
+func (t *Turtle) Canvas() Canvas {
+	return t.canvas
 }
 
-func (t *Turtle) LoadFont() error {
-	t.CloseFont()
-
-	if err := ttf.Init(); err != nil {
-		return fmt.Errorf("setlabelfont: ttf.Init failed: %v", err)
+func (t *Turtle) LoadTurtleImage(path string) error {
+	if err := t.canvas.LoadSprite(path); err != nil {
+		return err
 	}
-
-	if f, err := ttf.OpenFont(t.fontPath, t.fontSize); err != nil {
-		return fmt.Errorf("setlabelfont: ttf.OpenFont failed: %v", err)
+	// The file is kept as it is for vector output. A sprite the canvas
+	// can show but the image package cannot read is left out of it.
+	t.sprite = nil
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
 	}
-	t.font = f
-	return nil
-}
-
-func (t *Turtle) CloseFont() {
-	if t.font != nil {
-		t.font.Close()
-		t.font = nil
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil
 	}
+	t.sprite = &sprite{data: data, format: format, w: cfg.Width, h: cfg.Height}
+	return nil
 }
 
 func (t *Turtle) drawSprite() {
-	if !t.showTurtle || t.sprite == nil {
+	if !t.showTurtle {
 		return
 	}
-
 	sx, sy := t.screenCoords(t.x, t.y)
-	w, h := t.spriteW, t.spriteH
-
-	dst := sdl.Rect{
-		X: sx - w/2,
-		Y: sy - h/2,
-		W: w,
-		H: h,
+	t.canvas.DrawSprite(int(sx), int(sy), t.angle)
+	if t.sprite != nil {
+		t.stamp = &stamp{int(sx), int(sy), t.angle, t.sprite}
 	}
-
-	center := sdl.point{X: w / 2, Y: h / 2}
-	t.renderer.CopyEx(
-		t.sprite,
-		nil,
-		&dst,
-		-t.angle,
-		&center,
-		sdl.FLIP_NONE,
-	)
 }
 
-func (t *Turtle) PrintLabel(label string) {
-	if t.font == nil {
-		t.LoadFont()
-	}
-
-	fg := t.fgColor.toSDLColor()
-	if surf, err := t.font.RenderUTF8_Blended(label, fg); err != nil {
-		log.Printf("printlabel: ttf.RenderUTF8_Blended failed: %v", err)
-		return
-	}
-	defer surf.Free()
-
-	if tex, err := t.renderer.CreateTextureFromSurface(surf); err != nil {
-		log.Printf("printlabel: sdl.CreateTextureFromSurface failed: %v", err)
-		return
-	}
-	defer tex.Destroy()
-
-	w, h := surf.W, surf.H
+func (t *Turtle) PrintLabel(label string) error {
 	sx, sy := t.screenCoords(t.x, t.y)
-	dst := sdl.Rect{
-		X: sx - w/2,
-		Y: sy - h/2,
-		W: w,
-		H: h,
-	}
-
-	if err := t.renderer.Copy(tex, nil, &dst); err != nil {
-		log.Printf("printlabel: sdl.Copy failed: %v", err)
-		return
+	font := Font{Path: t.fontPath, Size: t.fontSize}
+	if err := t.canvas.DrawText(int(sx), int(sy), label, font, t.fgColor); err != nil {
+		return err
 	}
-
-	t.renderer.Present()
-
+	t.record(caption{int(sx), int(sy), label, font, t.fgColor})
+	t.canvas.Present()
+	return nil
 }
 
 func (t *Turtle) Filled(fillR, fillG, fillB, fillA uint8, body func()) {
@@ -227,7 +165,7 @@ func (t *Turtle) Filled(fillR, fillG, fillB, fillA uint8, body func()) {
 	body()
 
 	t.recordPath = origRecordPath
-	outlineR, outlineG, outlineB, outlineA := t.currentDrawColor()
+	outline := t.currentDrawColor()
 	t.penDown = origPenDown
 	t.showTurtle = origShowTurtle
 
@@ -236,33 +174,26 @@ func (t *Turtle) Filled(fillR, fillG, fillB, fillA uint8, body func()) {
 		return
 	}
 
-	pts := make([]sdl.Point, n)
+	pts := make([]image.Point, n)
 	for i, v := range t.path {
 		sx, sy := t.screenCoords(v.X, v.Y)
-		pts[i] = sdl.Point{X: sx, Y: sy}
+		pts[i] = image.Point{X: int(sx), Y: int(sy)}
 	}
 
-	t.renderer.SetDrawColor(fillR, fillG, fillB, fillA)
+	fill := color.RGBA{fillR, fillG, fillB, fillA}
 	minX, minY, maxX, maxY := t.getPolygonBounds(pts)
-	t.fillPolygonScanline(pts, minX, minY, maxX, maxY, fillR, fillG, fillB, fillA)
-	t.renderer.SetDrawColor(outlineR, outlineG, outlineB, outlineA)
-	if t.penSize <= 1 {
-		t.renderer.DrawLines(pts)
-	} else {
-		t.renderer.DrawThickLines(pts, t.penSize)
-	}
-
-	if t.penSize <= 1 {
-		t.renderer.DrawLine(pts[n-1].X, pts[n-1].Y, pts[0].X, pts[0].Y)
-	} else {
-		t.renderer.DrawThickLine(pts[n-1].X, pts[n-1].Y, pts[0].X, pts[0].Y, t.penSize)
+	t.fillPolygonScanline(pts, minX, minY, maxX, maxY, fill)
+	for i := range pts {
+		next := pts[(i+1)%n]
+		t.canvas.DrawLine(pts[i].X, pts[i].Y, next.X, next.Y, int(t.penSize), outline)
 	}
+	t.record(polygon{pts, fill, outline, int(t.penSize)})
 
 	t.drawSprite()
-	t.renderer.Present()
+	t.canvas.Present()
 }
 
-func (t *Turtle) getPolygonBounds(pts []sdl.Point) (minX, minY, maxX, maxY int32) {
+func (t *Turtle) getPolygonBounds(pts []image.Point) (minX, minY, maxX, maxY int) {
 	if len(pts) == 0 {
 		return 0, 0, 0, 0
 	}
@@ -288,9 +219,9 @@ func (t *Turtle) getPolygonBounds(pts []sdl.Point) (minX, minY, maxX, maxY int32
 	return minX, minY, maxX, maxY
 }
 
-func (t *Turtle) fillPolygonScanline(pts []sdl.Point, minX, minY, maxX, maxY int32, r, g, b, a uint8) {
+func (t *Turtle) fillPolygonScanline(pts []image.Point, minX, minY, maxX, maxY int, c color.RGBA) {
 	for y := minY; y <= maxY; y++ {
-		intersections := make([]int32, 0, 16)
+		intersections := make([]int, 0, 16)
 
 		n := len(pts)
 		for i := 0; i < n; i++ {
@@ -301,14 +232,12 @@ func (t *Turtle) fillPolygonScanline(pts []sdl.Point, minX, minY, maxX, maxY int
 				if p1.Y != p2.Y {
 					tFrac := float64(y-p1.Y) / float64(p2.Y-p1.Y)
 					x := float64(p1.X) + tFrac*float64(p2.X-p1.X)
-					intersections = append(intersections, int32(math.Round(x)))
+					intersections = append(intersections, int(math.Round(x)))
 				}
 			}
 		}
 
-		sort.Slice(intersections, func(i, j int) bool {
-			return intersections[i] < intersections[j]
-		})
+		sort.Ints(intersections)
 
 		for i := 0; i+1 < len(intersections); i += 2 {
 			x1 := intersections[i]
@@ -322,112 +251,76 @@ func (t *Turtle) fillPolygonScanline(pts []sdl.Point, minX, minY, maxX, maxY int
 			}
 
 			if x1 <= x2 {
-				t.renderer.SetDrawColor(r, g, b, a)
-				t.renderer.DrawLine(x1, y, x2, y)
+				t.canvas.FillSpan(y, x1, x2, c)
 			}
 		}
 	}
 }
 
-func (t *Turtle) BucketFill() {
+func (t *Turtle) BucketFill() error {
 	sx, sy := t.screenCoords(t.x, t.y)
 
-	if sx < 0 || sx >= int32(WindowWidth) || sy < 0 || sy >= int32(WindowHeight) {
-		return
+	if sx < 0 || int(sx) >= t.width || sy < 0 || int(sy) >= t.height {
+		return nil
 	}
 
-	fillR, fillG, fillB, fillA := t.currentDrawColor()
-
-	pitch := WindowWidth * 4
-	pixels := make([]byte, WindowHeight*pitch)
+	fill := t.currentDrawColor()
 
-	if err := t.renderer.ReadPixels(
-		nil,
-		sdl.PIXELFORMAT_RGBA8888,
-		unsafe.Pointer(&pixels[0]),
-		pitch,
-	); err != nil {
-		log.Printf("bucketfill: ReadPixels failed: %v", err)
-		return
+	img, err := t.canvas.ReadPixels()
+	if err != nil {
+		return err
 	}
 
-	startIdx := int(sy)*pitch + int(sx)*4
-
-	targetR := pixels[startIdx]
-	targetG := pixels[startIdx+1]
-	targetB := pixels[startIdx+2]
-	targetA := pixels[startIdx+3]
-
-	if targetR == fillR && targetG == fillG && targetB == fillB && targetA == fillA {
-		return
+	target := img.RGBAAt(int(sx), int(sy))
+	if target == fill {
+		return nil
 	}
 
-	t.scanlineFloodFill(pixels, int(sx), int(sy),
-		targetR, targetG, targetB, targetA,
-		fillR, fillG, fillB, fillA,
-		pitch)
+	before := image.NewRGBA(img.Rect)
+	copy(before.Pix, img.Pix)
+	t.scanlineFloodFill(img, int(sx), int(sy), target, fill)
 
-	tex, err := t.renderer.CreateTexture(
-		sdl.PIXELFORMAT_RGBA8888,
-		sdl.TEXTUREACCESS_STATIC,
-		int32(WindowWidth),
-		int32(WindowHeight),
-	)
-	if err != nil {
-		log.Printf("bucketfill: CreateTexture failed: %v", err)
-		return
+	if err := t.canvas.WritePixels(img); err != nil {
+		return err
 	}
-	defer tex.Destroy()
-
-	if err := tex.Update(nil, pixels, pitch); err != nil {
-		log.Printf("bucketfill: Texture.Update failed: %v", err)
-		return
+	if patch := changed(before, img); patch != nil {
+		t.record(bitmap{patch})
 	}
-
-	t.renderer.Copy(tex, nil, nil)
-	t.renderer.Present()
+	t.canvas.Present()
+	return nil
 }
 
-func (t *Turtle) scanlineFloodFill(pixels []byte, x, y int,
-	targetR, targetG, targetB, targetA,
-	fillR, fillG, fillB, fillA uint8,
-	pitch int) {
-
+func (t *Turtle) scanlineFloodFill(img *image.RGBA, x, y int, target, fill color.RGBA) {
 	type segment struct {
 		y, x1, x2, dy int
 	}
 
 	stack := make([]segment, 0, 1024)
+	w, h := img.Rect.Dx(), img.Rect.Dy()
 
 	matchesTarget := func(px, py int) bool {
-		if px < 0 || px >= WindowWidth || py < 0 || py >= WindowHeight {
+		if px < 0 || px >= w || py < 0 || py >= h {
 			return false
 		}
-		idx := py*pitch + px*4
-		return pixels[idx] == targetR &&
-			pixels[idx+1] == targetG &&
-			pixels[idx+2] == targetB &&
-			pixels[idx+3] == targetA
+		return img.RGBAAt(px, py) == target
 	}
 
 	setPixel := func(px, py int) {
-		if px >= 0 && px < WindowWidth && py >= 0 && py < WindowHeight {
-			idx := py*pitch + px*4
-			pixels[idx] = fillR
-			pixels[idx+1] = fillG
-			pixels[idx+2] = fillB
-			pixels[idx+3] = fillA
+		if px >= 0 && px < w && py >= 0 && py < h {
+			img.SetRGBA(px, py, fill)
 		}
 	}
 
 	x1 := x
 	for x1 >= 0 && matchesTarget(x1, y) {
+		setPixel(x1, y)
 		x1--
 	}
 	x1++
 
-	x2 := x
-	for x2 < WindowWidth && matchesTarget(x2, y) {
+	x2 := x + 1
+	for x2 < w && matchesTarget(x2, y) {
+		setPixel(x2, y)
 		x2++
 	}
 	x2--
@@ -440,7 +333,7 @@ func (t *Turtle) scanlineFloodFill(pixels []byte, x, y int,
 		stack = stack[:len(stack)-1]
 
 		yNew := s.y + s.dy
-		if yNew < 0 || yNew >= WindowHeight {
+		if yNew < 0 || yNew >= h {
 			continue
 		}
 
@@ -457,16 +350,15 @@ func (t *Turtle) scanlineFloodFill(pixels []byte, x, y int,
 			xNew1++
 
 			xNew2 := x + 1
-			for xNew2 < WindowWidth && matchesTarget(xNew2, yNew) {
+			for xNew2 < w && matchesTarget(xNew2, yNew) {
 				setPixel(xNew2, yNew)
 				xNew2++
 			}
 			xNew2--
 
-			if s.dy > 0 {
-				stack = append(stack, segment{yNew, xNew1, xNew2, 1})
-			} else {
-				stack = append(stack, segment{yNew, xNew1, xNew2, -1})
+			stack = append(stack, segment{yNew, xNew1, xNew2, s.dy})
+			if xNew1 < s.x1 || xNew2 > s.x2 {
+				stack = append(stack, segment{yNew, xNew1, xNew2, -s.dy})
 			}
 
 			x = xNew2
@@ -474,24 +366,25 @@ func (t *Turtle) scanlineFloodFill(pixels []byte, x, y int,
 	}
 }
 
-func (t *Turtle) currentDrawColor() (uint8, uint8, uint8, uint8) {
+func (t *Turtle) currentDrawColor() color.RGBA {
 	switch t.penMode {
 	case PenPaint:
-		return t.fgColor.getFields()
-	case PenPaint:
-		return t.bgColor.getFields()
+		return t.fgColor
+	case PenErase:
+		return t.bgColor
 	case PenReverse:
-		return t.fgColor.getInverseFields()
+		return inverse(t.fgColor)
 	default:
-		return t.fgColor.getFields()
+		return t.fgColor
 	}
 }
 
 func (t *Turtle) screenCoords(x, y float64) (int32, int32) {
 	px := x * t.scale
 	py := y * t.scale
-	sx := int32(WindowWidth/2 + px)
-	sy := int32(WindowHeight/2 - py)
+	sx := int32(float64(t.width)/2 + px)
+	sy := int32(float64(t.height)/2 - py)
+	w, h := int32(t.width), int32(t.height)
 
 	if sx < t.minX {
 		sx = t.minX
@@ -508,19 +401,19 @@ func (t *Turtle) screenCoords(x, y float64) (int32, int32) {
 
 	switch t.wrapMode {
 	case WrappingWrap:
-		sx = ((sx % WindowWidth) + WindowWidth) % WindowWidth
-		sy = ((sy % WindowHeight) + WindowHeight) % WindowHeight
+		sx = ((sx % w) + w) % w
+		sy = ((sy % h) + h) % h
 	case WrappingFence:
 		if sx < 0 {
 			sx = 0
-		} else if sx >= WindowWidth {
-			sx = WindowWidth - 1
+		} else if sx >= w {
+			sx = w - 1
 		}
 
 		if sy < 0 {
 			sy = 0
-		} else if sy >= WindowHeight {
-			sy = WindowHeight - 1
+		} else if sy >= h {
+			sy = h - 1
 		}
 	case WrappingWindow:
 		break
@@ -529,7 +422,7 @@ func (t *Turtle) screenCoords(x, y float64) (int32, int32) {
 	return sx, sy
 }
 
-func (t *Turtle) Forward(dist float64) {
+func (t *Turtle) Forward(dist float64) error {
 	rad := t.angle * math.Pi / 180
 	dx := dist * math.Cos(rad)
 	dy := dist * math.Sin(rad)
@@ -537,19 +430,19 @@ func (t *Turtle) Forward(dist float64) {
 	newY := t.y + dy
 
 	if t.wrapMode == WrappingFence {
-		maxX := float64(WindowWidth) / t.scale / 2
-		maxY := float64(WindowHeight) / t.scale / 2
+		maxX := float64(t.width) / t.scale / 2
+		maxY := float64(t.height) / t.scale / 2
 		if newX > maxX || newX < -maxX || newY > maxY || newY < -maxY {
-			return
+			return logoerr.New(logoerr.ERR_TurtleBounds, "Turtle out of bounds")
 		}
 	}
 
 	if t.penDown {
-		r, g, b, a = t.currentDrawColor()
-		t.renderer.SetDrawColor(r, g, b, a)
 		x1, y1 := t.screenCoords(t.x, t.y)
 		x2, y2 := t.screenCoords(newX, newY)
-		t.renderer.DrawThickLine(x1, y1, x2, y2, t.penSize)
+		c := t.currentDrawColor()
+		t.canvas.DrawLine(int(x1), int(y1), int(x2), int(y2), int(t.penSize), c)
+		t.record(stroke{int(x1), int(y1), int(x2), int(y2), int(t.penSize), c})
 	}
 
 	if t.recordPath {
@@ -559,8 +452,8 @@ func (t *Turtle) Forward(dist float64) {
 	t.x, t.y = newX, newY
 
 	if t.wrapMode == WrappingWrap {
-		wUnits := float64(WindowWidth) / t.scale
-		hUnits := float64(WindowHeight) / t.scale
+		wUnits := float64(t.width) / t.scale
+		hUnits := float64(t.height) / t.scale
 
 		halfW, halfH := wUnits/2, hUnits/2
 
@@ -578,34 +471,38 @@ func (t *Turtle) Forward(dist float64) {
 	}
 
 	if t.recordPath {
-		return
+		return nil
 	}
 
 	t.drawSprite()
-	t.renderer.Present()
+	t.canvas.Present()
+	return nil
 }
 
-func (t *Turtle) DrawArc(deg, rad float64) {
+func (t *Turtle) DrawArc(deg, rad float64) error {
 	steps := math.Floor(math.Abs(deg))
 	if steps == 0 {
-		return
+		return nil
 	}
 
-	stepAngle := math.Abs(deg) / float64(steps)
+	stepAngle := math.Abs(deg) / steps
 	stepLen := rad * (stepAngle * math.Pi / 180.0)
 
-	for i := 0; i < steps; i++ {
+	for i := 0; i < int(steps); i++ {
 		if deg > 0 {
 			t.Left(stepAngle)
 		} else {
 			t.Right(stepAngle)
 		}
-		t.Forward(stepLen)
+		if err := t.Forward(stepLen); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func (t *Turtle) Back(dist float64) {
-	t.Forward(-dist)
+func (t *Turtle) Back(dist float64) error {
+	return t.Forward(-dist)
 }
 
 func (t *Turtle) Right(angle float64) {
@@ -630,6 +527,7 @@ func (t *Turtle) ShowTurtle() {
 
 func (t *Turtle) HideTurtle() {
 	t.showTurtle = false
+	t.stamp = nil
 }
 
 func (t *Turtle) Home() {
@@ -637,28 +535,30 @@ func (t *Turtle) Home() {
 	t.angle = 0
 }
 
+// Clear clears the canvas to the background color, forgetting what was
+// drawn on it, and sends the turtle home with its pen down. The pen and
+// background colors are kept.
 func (t *Turtle) Clear() {
-	r, g, b, a = t.bgColor.getFields()
-	t.renderer.SetDrawColor(r, g, b, a)
-	t.renderer.Clear()
-	t.renderer.Present()
+	t.canvas.Clear(t.bgColor)
+	t.canvas.Present()
+	bg := t.bgColor
+	t.background = &bg
+	t.display = nil
+	t.stamp = nil
 	t.Home()
-	t.penDown = true
-	t.fgColor = color{255, 255, 255, 255}
-	t.bgColor = color{0, 0, 0, 0}
 	t.scale = 1.0
 	t.minX, t.minY = 0, 0
-	t.maxX, t.maxY = screenWidth-1, screenHeight-1
+	t.maxX, t.maxY = int32(t.width-1), int32(t.height-1)
 	t.ShowTurtle()
 	t.PenDown()
 }
 
 func (t *Turtle) SetForegroundColor(r, g, b, a uint8) {
-	t.fgColor = color{r, g, b, a}
+	t.fgColor = color.RGBA{r, g, b, a}
 }
 
 func (t *Turtle) SetBackgroundColor(r, g, b, a uint8) {
-	t.bgColor = color{r, g, b, a}
+	t.bgColor = color.RGBA{r, g, b, a}
 }
 
 func (t *Turtle) SetPosition(x, y float64) {
@@ -688,15 +588,14 @@ func (t *Turtle) SetBounds(minX, minY, maxX, maxY int32) {
 	if minY < 0 {
 		minY = 0
 	}
-	if maxX >= WindowWidth {
-		maxX = WindowWidth - 1
+	if maxX >= int32(t.width) {
+		maxX = int32(t.width - 1)
 	}
-	if maxY >= WindowHeight {
-		maxY = WindowHeight - 1
+	if maxY >= int32(t.height) {
+		maxY = int32(t.height - 1)
 	}
 	if minX > maxX {
-		minX, maxX = maxX, maxY
-
+		minX, maxX = maxX, minX
 	}
 	if minY > maxY {
 		minY, maxY = maxY, minY
@@ -713,7 +612,7 @@ func (t *Turtle) SetPenMode(penMode PenMode) {
 }
 
 func (t *Turtle) SetPenSize(penSize uint) {
-	t.penSize = penSize
+	t.penSize = int32(penSize)
 }
 
 func (t *Turtle) SetFontSize(fontSize uint) {
@@ -729,11 +628,11 @@ func (t *Turtle) GetPosition() (float64, float64) {
 }
 
 func (t *Turtle) GetForegroundColor() (uint8, uint8, uint8, uint8) {
-	return t.fgColor.getFields()
+	return t.fgColor.R, t.fgColor.G, t.fgColor.B, t.fgColor.A
 }
 
 func (t *Turtle) GetBackgroundColor() (uint8, uint8, uint8, uint8) {
-	return t.bgColor.getFields()
+	return t.bgColor.R, t.bgColor.G, t.bgColor.B, t.bgColor.A
 }
 
 func (t *Turtle) GetX() float64 {
@@ -765,7 +664,7 @@ func (t *Turtle) GetWrapMode() Wrapping {
 }
 
 func (t *Turtle) GetPenSize() uint {
-	return t.penSize
+	return uint(t.penSize)
 }
 
 func (t *Turtle) GetFontSize() uint {
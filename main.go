@@ -1,219 +1,359 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
-	"math"
-	"strconv"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"gortle/internal/eval"
+	"gortle/internal/repl"
+	"gortle/internal/turtle"
+	"gortle/internal/turtle/sdlcanvas"
+
 	"github.com/veandco/go-sdl2/sdl"
 )
 
+// Exit codes, so that scripts running programs can tell how they
+// failed.
 const (
-	WINDOW_Width   = 800
-	WINDOW_Height = 600
+	exitOK      = 0
+	exitError   = 1 // the program stopped with a Logo error
+	exitUsage   = 2 // bad arguments, or the program could not be read
+	exitFailure = 3 // the window could not be made or the output saved
 )
 
-type Turtle struct {
-	x, y       float64
-	angle      float64
-	penDown    bool
-	r, g, b, a uint8
-	renderer   *sdl.Renderer
-}
+const usage = `usage: gortle [options]                  start the interactive REPL
+       gortle [options] run file.lg [options]  run a program
+       gortle [options] -e instructions       run instructions
 
-func NewTurtle(r *sdl.Renderer) *Turtle {
-	t := &Turtle{
-		x:        0,
-		y:        0,
-		angle:    0,
-		r:        255,
-		g:        255,
-		b:        255,
-		a:        255,
-		renderer: r,
-	}
-	return t
+options:
+`
+
+// frameInterval is how often a window's events are handled while a
+// program runs.
+const frameInterval = 16 * time.Millisecond
+
+// outputFormats are the picture formats --output can write.
+var outputFormats = map[string]bool{
+	"png": true, "bmp": true, "ppm": true, "svg": true, "eps": true, "pdf": true,
 }
 
-func (t *Turtle) screenCoords(x, y float64) (int32, int32) {
-	sx := int32(WINDOW_Width/2 + x)
-	sy := int32(WINDOW_Height/2 - y)
-	return sx, sy
+// errClosed stops a program when its window is closed.
+var errClosed = errors.New("window closed")
+
+type options struct {
+	file     string
+	expr     string
+	width    int
+	height   int
+	speed    float64
+	noWindow bool
+	output   string
+	paper    string
 }
 
-func (t *Turtle) Forward(dist float64) {
-	rad := t.angle * math.Pi / 180
-	dx := dist * math.Cos(rad)
-	dy := dist * math.Sin(rad)
-	newX := t.x + dx
-	newY := t.y + dy
+// parseArgs reads the command line. Options may come before or after
+// run and its file. Usage is shown for any error, and for -h, which
+// returns flag.ErrHelp.
+func parseArgs(args []string) (*options, error) {
+	opts := &options{}
+	fs := flag.NewFlagSet("gortle", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+	fs.StringVar(&opts.expr, "e", "", "run `instructions` and exit")
+	fs.IntVar(&opts.width, "width", turtle.WindowWidth, "canvas width in `pixels`")
+	fs.IntVar(&opts.height, "height", turtle.WindowHeight, "canvas height in `pixels`")
+	fs.Float64Var(&opts.speed, "speed", 0, "run at most `n` instructions a second; 0 runs at full speed")
+	fs.BoolVar(&opts.noWindow, "no-window", false, "draw off screen without opening a window")
+	fs.StringVar(&opts.output, "output", "", "save the final canvas to `file`.png, .bmp, .ppm, .svg, .eps or .pdf")
+	fs.StringVar(&opts.paper, "paper", "a4", "fit .eps and .pdf output to `paper`: a4, letter, ..., or \"width height [margin]\" in points")
+
+	err := opts.parse(fs, args)
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "gortle: %v\n", err)
+		}
+		fs.SetOutput(os.Stderr)
+		fmt.Fprint(os.Stderr, usage)
+		fs.PrintDefaults()
+		return nil, err
+	}
+	return opts, nil
+}
 
-	if t.penDown {
-		t.renderer.SetDrawColor(t.r, t.g, t.b, t.a)
-		x1, y1 := t.screenCoords(t.x, t.y)
-		x2, y2 := t.screenCoords(newX, newY)
-		t.renderer.DrawLine(x1, y1, x2, y2)
-		t.renderer.Present()
+func (o *options) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if rest := fs.Args(); len(rest) > 0 {
+		if rest[0] != "run" {
+			return fmt.Errorf("unknown command %q", rest[0])
+		}
+		if len(rest) < 2 {
+			return errors.New("run needs a file")
+		}
+		o.file = rest[1]
+		if err := fs.Parse(rest[2:]); err != nil {
+			return err
+		}
+		if len(fs.Args()) > 0 {
+			return fmt.Errorf("unexpected argument %q", fs.Args()[0])
+		}
 	}
 
-	t.x = newX
-	t.y = newY
+	switch {
+	case o.width <= 0 || o.height <= 0:
+		return errors.New("width and height must be positive")
+	case o.speed < 0:
+		return errors.New("speed must not be negative")
+	case o.output != "" && !outputFormats[outputFormat(o.output)]:
+		return fmt.Errorf("cannot save %s: use .png, .bmp, .ppm, .svg, .eps or .pdf", o.output)
+	}
+	if _, err := turtle.ParsePaper(o.paper); err != nil {
+		return fmt.Errorf("paper: %v", err)
+	}
+	return nil
 }
 
-func (t *Turtle) Back(dist float64) {
-	t.Forward(-dist)
+// batch reports whether the options ask for a program to be run rather
+// than the REPL.
+func (o *options) batch() bool {
+	return o.file != "" || o.expr != ""
 }
 
-func (t *Turtle) Right(angle float64) {
-	t.angle -= angle
+// delay is the pause before each instruction that --speed asks for.
+func (o *options) delay() time.Duration {
+	if o.speed == 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / o.speed)
 }
 
-func (t *Turtle) Left(angle float64) {
-	t.angle += angle
+// display is what the turtle draws on: a window, or with --no-window a
+// raster in memory, which needs no SDL at all.
+type display struct {
+	sdl      bool
+	window   *sdl.Window
+	renderer *sdl.Renderer
+	canvas   turtle.Canvas
 }
 
-func (t *Turtle) PenUp() {
-	t.penDown = false
+func newDisplay(opts *options) (*display, error) {
+	d := &display{}
+	w, h := turtle.WindowWidth, turtle.WindowHeight
+	if opts.noWindow {
+		d.canvas = turtle.NewRaster(w, h)
+	} else {
+		if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
+			return nil, fmt.Errorf("could not initialize SDL: %v", err)
+		}
+		d.sdl = true
+		var err error
+		d.window, err = sdl.CreateWindow(
+			"Gortle",
+			sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED,
+			int32(w), int32(h),
+			sdl.WINDOW_SHOWN,
+		)
+		if err != nil {
+			d.close()
+			return nil, fmt.Errorf("could not create window: %v", err)
+		}
+		if d.renderer, err = sdl.CreateRenderer(d.window, -1, sdl.RENDERER_ACCELERATED); err != nil {
+			d.close()
+			return nil, fmt.Errorf("could not create renderer: %v", err)
+		}
+		d.canvas = sdlcanvas.New(d.renderer, w, h)
+	}
+
+	return d, nil
 }
 
-func (t *Turtle) PenDown() {
-	t.penDown = true
+func (d *display) close() {
+	if c, ok := d.canvas.(*sdlcanvas.Canvas); ok {
+		c.Close()
+	}
+	if d.renderer != nil {
+		d.renderer.Destroy()
+	}
+	if d.window != nil {
+		d.window.Destroy()
+	}
+	if d.sdl {
+		sdl.Quit()
+	}
 }
 
-func (t *Turtle) SetColor(r, g, b, a uint8) {
-	t.r, t.g, t.b, t.a = r, g, b, a
+// pollEvents handles the window's events, returning false once the
+// window is closed or Escape is pressed.
+func pollEvents() bool {
+	for ev := sdl.PollEvent(); ev != nil; ev = sdl.PollEvent() {
+		switch e := ev.(type) {
+		case *sdl.QuitEvent:
+			return false
+		case *sdl.KeyboardEvent:
+			if e.Keysym.Sym == sdl.K_ESCAPE && e.State == sdl.PRESSED {
+				return false
+			}
+		}
+	}
+	return true
 }
 
-func (t *Turtle) Home() {
-	t.x, t.y = 0, 0
-	t.angle = 0
+// waitClosed keeps the window up until it is closed.
+func waitClosed() {
+	for pollEvents() {
+		time.Sleep(frameInterval)
+	}
 }
 
-func (t *Turtle) Clear() {
-	t.renderer.SetDrawColor(0, 0, 0, 255)
-	t.renderer.Clear()
-	t.renderer.Present()
-	t.Home()
-	t.penDown = true
-	t.r, t.g, t.b, t.a = 255, 255, 255, 255
+// historyFile is where the REPL keeps the lines typed at it.
+func historyFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gortle_history")
 }
 
-func interpret(t *Turtle, script []string) {
-	for _, line := range script {
-		tokens := strings.Fields(line)
-		if len(tokens) == 0 {
-			continue
+// runBatch runs the file and then the instructions given on the command
+// line, stopping at the first error.
+func runBatch(in *eval.Interp, opts *options, d *display) int {
+	delay := opts.delay()
+	var last time.Time
+	in.SetPoll(func() error {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		if d.window != nil && time.Since(last) >= frameInterval {
+			last = time.Now()
+			if !pollEvents() {
+				return errClosed
+			}
+		}
+		return nil
+	})
+	defer in.SetPoll(nil)
+
+	if opts.file != "" {
+		f, err := os.Open(opts.file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		err = in.Run(opts.file, f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+	if opts.expr != "" {
+		if err := in.RunString("-e", opts.expr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
 		}
+	}
+	return exitOK
+}
 
-		cmd := strings.ToLower(tokens[0])
+func runRepl(in *eval.Interp, opts *options, d *display) int {
+	ed := repl.NewEditor(os.Stdin, os.Stdout)
+	defer ed.Close()
+	history := historyFile()
+	if history != "" {
+		if err := ed.LoadHistory(history); err != nil {
+			log.Printf("Could not read history: %v", err)
+		}
+	}
+	r := repl.New(in, ed, os.Stdout)
+	r.Delay = opts.delay()
+	if d.window != nil {
+		r.Idle = pollEvents
+	}
+	code := exitOK
+	if err := r.Run(); err != nil {
+		log.Print(err)
+		code = exitFailure
+	}
+	if history != "" {
+		if err := ed.SaveHistory(history); err != nil {
+			log.Printf("Could not save history: %v", err)
+		}
+	}
+	return code
+}
 
-		fmt.Println(cmd)
+// outputFormat is the picture format named by a file's extension.
+func outputFormat(path string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+}
 
-		switch cmd {
-		case "forward", "fd":
-			d, err := strconv.ParseFloat(tokens[1], 64)
-			if err != nil {
-				fmt.Println("Bad number: ", tokens[1])
-				continue
-			}
-			t.Forward(d)
-		case "back", "bk":
-			a, _ := strconv.ParseFloat(tokens[1], 64)
-			t.Right(a)
-		case "left", "lt":
-			a, _ := strconv.ParseFloat(tokens[1], 64)
-			t.Left(a)
-		case "right":
-			a, _ := strconv.ParseFloat(tokens[1], 64)
-			t.Right(a)
-		case "setcolor":
-			r, _ := strconv.Atoi(tokens[1])
-			g, _ := strconv.Atoi(tokens[2])
-			b, _ := strconv.Atoi(tokens[3])
-			t.SetColor(uint8(r), uint8(g), uint8(b), 255)
-		case "penup", "pu":
-			t.PenUp()
-		case "pendown", "pd":
-			t.PenDown()
-		case "clearscreen":
-			t.Clear()
-		case "home":
-			t.Home()
-		default:
-			fmt.Println("Unknown command:", cmd)
-		}
-
-		time.Sleep(100 * time.Millisecond)
+// saveOutput writes the canvas to a file in the format its extension
+// names.
+func saveOutput(t *turtle.Turtle, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := t.SavePicture(f, outputFormat(path)); err != nil {
+		f.Close()
+		return err
 	}
+	return f.Close()
 }
 
-func main() {
-	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
-		log.Fatalf("Could not initialize SDL: %v", err)
+func run(args []string) int {
+	opts, err := parseArgs(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
 	}
-	defer sdl.Quit()
-
-	window, err := sdl.CreateWindow(
-		"Gortle",
-		sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED,
-		WINDOW_Width, WINDOW_Height,
-		sdl.WINDOW_SHOWN,
-	)
 	if err != nil {
-		log.Fatalf("Could not create window: %v", err)
+		return exitUsage
 	}
-	defer window.Destroy()
+	turtle.WindowWidth, turtle.WindowHeight = opts.width, opts.height
 
-	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED)
+	d, err := newDisplay(opts)
 	if err != nil {
-		log.Fatalf("Could not create window: %v", err)
-	}
-	defer renderer.Destroy()
-
-	renderer.SetDrawColor(0, 0, 0, 255)
-	renderer.Clear()
-	renderer.Present()
-
-	turtle := NewTurtle(renderer)
-
-	script := []string{
-		"clearscreen",
-		"setcolor 255 0 0",
-		"pendown",
-		"forward 200",
-		"right 90",
-		"forward 200",
-		"right 90",
-		"forward 200",
-		"right 90",
-		"forward 200",
-		"right 90",
-		"setcolor 0 255 0",
-		"penup",
-		"forward 100",
-		"pendown",
-		"left 45",
-		"forward 141.4", // diagonal back to center
-	}
-
-	interpret(turtle, script)
-
-	for {
-		for ev := sdl.PollEvent(); ev != nil; ev = sdl.PollEvent() {
-			switch e := ev.(type) {
-			case *sdl.QuitEvent:
-				return
-			case *sdl.KeyboardEvent:
-				if e.Keysym.Sym == sdl.K_ESCAPE && e.State == sdl.PRESSED {
-					return
-				}
+		fmt.Fprintf(os.Stderr, "gortle: %v\n", err)
+		return exitFailure
+	}
+	defer d.close()
+
+	t := turtle.NewTurtle(d.canvas)
+	t.Clear()
+	t.SetPaper(opts.paper)
+	in := eval.New(os.Stdout, t)
+
+	var code int
+	if opts.batch() {
+		code = runBatch(in, opts, d)
+	} else {
+		code = runRepl(in, opts, d)
+	}
+
+	if opts.output != "" {
+		if err := saveOutput(t, opts.output); err != nil {
+			fmt.Fprintf(os.Stderr, "gortle: could not save %s: %v\n", opts.output, err)
+			if code == exitOK {
+				code = exitFailure
 			}
 		}
-
-		sdl.Delay(16)
 	}
+	// A program run in a window is left on screen to be looked at,
+	// unless its drawing has gone to a file.
+	if code == exitOK && opts.batch() && d.window != nil && opts.output == "" {
+		waitClosed()
+	}
+	return code
+}
 
+func main() {
+	os.Exit(run(os.Args[1:]))
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestHeadlessOutput runs instructions with no window, as in
+//
+//	gortle -e 'fd 100' --no-window --output out.png
+//
+// and checks that the saved picture shows what was drawn
+func TestHeadlessOutput(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.png")
+	code := run([]string{"-e", "fd 100", "--no-window", "--width", "120", "--height", "240", "--output", out})
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("Bad PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 120 || b.Dy() != 240 {
+		t.Errorf("Expected a 120x240 picture, got %v", b.Size())
+	}
+
+	black := color.RGBA{0, 0, 0, 255}
+	drawn := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			switch {
+			case c == black:
+			case c.A != 255:
+				t.Fatalf("Expected an opaque picture, got %v at %d, %d", c, x, y)
+			default:
+				drawn++
+			}
+		}
+	}
+	// FD 100 goes straight up from the middle, a pixel wide.
+	if drawn < 100 {
+		t.Errorf("Expected a line of at least 100 pixels, got %d", drawn)
+	}
+}
+
+// TestHeadlessErrors tests the exit codes for a Logo error and for bad
+// options
+func TestHeadlessErrors(t *testing.T) {
+	if code := run([]string{"-e", "nosuchprocedure", "--no-window"}); code != exitError {
+		t.Errorf("Expected exit code %d for a Logo error, got %d", exitError, code)
+	}
+	if code := run([]string{"--no-window", "--output", "out.gif"}); code != exitUsage {
+		t.Errorf("Expected exit code %d for a bad output, got %d", exitUsage, code)
+	}
+}